@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"main/internal/backfill"
+	"main/internal/mailbox"
+	"main/internal/model"
+	"main/internal/server"
+	"time"
+)
+
+// backfillCommand imports a user's mail since a given day. Interrupting it
+// with Ctrl-C saves a checkpoint; running it again resumes.
+func backfillCommand() *command {
+	var email, sinceFlag string
+
+	return &command{
+		name:  "backfill",
+		short: "Import and summarize a user's mail since a given day",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&email, "user", "", "email of the user whose mailbox is imported")
+			fs.StringVar(&sinceFlag, "since", "", "import mail received since this day (YYYY-MM-DD)")
+		},
+		run: func(ctx context.Context, a *app, args []string) error {
+			if email == "" || sinceFlag == "" {
+				return errors.New("--user and --since are required")
+			}
+			since, err := time.Parse(time.DateOnly, sinceFlag)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+
+			user, err := a.authorizedUser(ctx, email)
+			if err != nil {
+				return err
+			}
+			store := a.store
+
+			client, err := mailbox.NewClientFunc(a.cfg.OAuth2(), a.store, mailbox.NewLimiter(0, 0), mailbox.DefaultBackoff)(ctx, user)
+			if err != nil {
+				return err
+			}
+
+			runner := backfill.NewRunner(store, store, a.cfg.FetchWorkers, backfill.Summarize(server.NewSummarizer(a.cfg, store), store, store))
+			b, err := runner.Run(ctx, client, user.ID, since, func(b *model.Backfill) {
+				fmt.Fprintf(a.out, "Backfill of %s: %d processed, %d failed\n", email, b.Processed, b.Failed)
+			})
+			if err != nil {
+				if ctx.Err() != nil && b != nil {
+					fmt.Fprintf(a.out, "Backfill interrupted after %d messages, run the same command again to resume\n", b.Processed)
+					return nil
+				}
+				return err
+			}
+
+			fmt.Fprintf(a.out, "Backfill of %s done: %d processed, %d failed\n", email, b.Processed, b.Failed)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func cacheCommand() *command {
+	var modelName string
+
+	return &command{
+		name:  "cache",
+		short: "Manage the shared summary cache",
+		children: []*command{
+			{
+				name:  "clear",
+				short: "Delete cached summaries of every user. Running servers keep the ones in memory until they restart",
+				flags: func(fs *flag.FlagSet) {
+					fs.StringVar(&modelName, "model", "", "only delete summaries of this model, e.g. openai:gpt-4o-mini")
+				},
+				run: exactArgs(0, func(ctx context.Context, a *app, args []string) error {
+					store, err := a.open()
+					if err != nil {
+						return err
+					}
+
+					removed, err := store.DeleteCachedSummaries(ctx, modelName)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(a.out, "Deleted %d cached summaries\n", removed)
+					return nil
+				}),
+			},
+		},
+	}
+}
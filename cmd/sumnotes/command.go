@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"main/internal/config"
+	"strings"
+	"text/tabwriter"
+)
+
+// command is a node of the CLI tree. Commands with children dispatch on
+// their first argument; leaf commands parse their flags and run.
+type command struct {
+	name string
+	// args describes the positional arguments in usage output.
+	args  string
+	short string
+	// flags registers the command's flags.
+	flags    func(fs *flag.FlagSet)
+	run      func(ctx context.Context, a *app, args []string) error
+	children []*command
+}
+
+// errUsage reports invalid arguments after usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+func (c *command) execute(ctx context.Context, a *app, path string, args []string) error {
+	if len(c.children) > 0 {
+		switch {
+		case len(args) == 0 && c.run == nil, len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help"):
+			c.usage(a.out, path)
+			return nil
+		case len(args) > 0 && !strings.HasPrefix(args[0], "-"):
+			for _, child := range c.children {
+				if child.name == args[0] {
+					return child.execute(ctx, a, path+" "+child.name, args[1:])
+				}
+			}
+			c.usage(a.err, path)
+			return fmt.Errorf("unknown command %q for %q", args[0], path)
+		case c.run == nil:
+			c.usage(a.err, path)
+			return errUsage
+		}
+	}
+
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(a.err)
+	fs.Usage = func() {
+		fmt.Fprintf(a.err, "Usage: %s [flags] %s\n\n%s\n", path, c.args, c.short)
+		fs.PrintDefaults()
+	}
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	// Every command can override the configuration.
+	a.configOptions = config.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+
+	return c.run(ctx, a, fs.Args())
+}
+
+func (c *command) usage(w io.Writer, path string) {
+	fmt.Fprintf(w, "Usage: %s <command>\n\n", path)
+	if c.short != "" {
+		fmt.Fprintf(w, "%s\n\n", c.short)
+	}
+	fmt.Fprintln(w, "Commands:")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, child := range c.children {
+		fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimSpace(child.name+" "+child.args), child.short)
+	}
+	tw.Flush()
+}
+
+// exactArgs wraps run so that it only runs with n positional arguments.
+func exactArgs(n int, run func(ctx context.Context, a *app, args []string) error) func(ctx context.Context, a *app, args []string) error {
+	return func(ctx context.Context, a *app, args []string) error {
+		if len(args) != n {
+			return fmt.Errorf("expected %d argument(s), got %d: %w", n, len(args), errUsage)
+		}
+		return run(ctx, a, args)
+	}
+}
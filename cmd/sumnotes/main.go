@@ -1,33 +1,121 @@
 package main
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
 	"main/internal/config"
 	"main/internal/database"
-	"main/internal/server"
+	"main/internal/logging"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-func main() {
-	cfg, err := config.Load()
+// app holds what commands share: output, configuration and the database,
+// which are loaded on first use.
+type app struct {
+	out io.Writer
+	err io.Writer
+
+	// configOptions returns the config file and flags of the command.
+	configOptions func() config.Options
+
+	cfg *config.Config
+	// db is nil with the memory store.
+	db    *sql.DB
+	store database.Store
+}
+
+// config loads the configuration once.
+func (a *app) config() (*config.Config, error) {
+	if a.cfg == nil {
+		var opts config.Options
+		if a.configOptions != nil {
+			opts = a.configOptions()
+		}
+
+		cfg, err := config.Load(opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		a.cfg = cfg
+		slog.SetDefault(logging.New(a.err, cfg.Level()))
+	}
+	return a.cfg, nil
+}
+
+// open connects to the database once, or creates the memory store.
+func (a *app) open() (database.Store, error) {
+	if a.store != nil {
+		return a.store, nil
+	}
+
+	cfg, err := a.config()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		return nil, err
+	}
+
+	if cfg.Store == config.StoreMemory {
+		a.store = database.NewMemory()
+		return a.store, nil
 	}
 
 	db, err := database.New(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	defer db.Close()
+	a.db = db
+	a.store = database.NewUserStore(db)
+	return a.store, nil
+}
 
-	userStore := database.NewUserStore(db)
+// close closes the database, if it was opened. It is safe to call twice.
+func (a *app) close() error {
+	if a.db == nil {
+		a.store = nil
+		return nil
+	}
+	err := a.db.Close()
+	a.db, a.store = nil, nil
+	return err
+}
 
-	srv, err := server.New(cfg, userStore)
-	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+func newRoot() *command {
+	serve := serveCommand()
+	return &command{
+		name:  "sumnotes",
+		short: "Summarize and manage your Gmail inbox. Runs serve when no command is given.",
+		run:   serve.run,
+		flags: serve.flags,
+		children: []*command{
+			serve,
+			migrateCommand(),
+			userCommand(),
+			tokenCommand(),
+			summarizeCommand(),
+			backfillCommand(),
+			cacheCommand(),
+		},
 	}
+}
 
-	log.Println("Starting server on :9999")
-	if err := srv.Run(":9999"); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	a := &app{out: os.Stdout, err: os.Stderr}
+	err := newRoot().execute(ctx, a, "sumnotes", os.Args[1:])
+
+	a.close()
+	stop()
+
+	if err != nil {
+		// Bare usage errors were already explained by the usage output.
+		if err != errUsage {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
 	}
 }
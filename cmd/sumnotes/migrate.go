@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/internal/migrate"
+	"main/migrations"
+	"text/tabwriter"
+	"time"
+)
+
+func migrateCommand() *command {
+	return &command{
+		name:  "migrate",
+		short: "Manage the database schema with the embedded migrations",
+		children: []*command{
+			{
+				name:  "up",
+				short: "Apply every pending migration",
+				run: func(ctx context.Context, a *app, args []string) error {
+					m, err := a.migrator()
+					if err != nil {
+						return err
+					}
+
+					// Like startup, refuse a newer schema and hold the
+					// lock so a starting replica does not migrate too.
+					applied, err := m.Apply(ctx)
+					for _, mig := range applied {
+						fmt.Fprintf(a.out, "Applied %d_%s\n", mig.Version, mig.Name)
+					}
+					if err != nil {
+						return err
+					}
+					if len(applied) == 0 {
+						fmt.Fprintln(a.out, "No pending migrations")
+					}
+					return nil
+				},
+			},
+			{
+				name:  "down",
+				short: "Roll back the latest applied migration",
+				run: func(ctx context.Context, a *app, args []string) error {
+					m, err := a.migrator()
+					if err != nil {
+						return err
+					}
+
+					mig, err := m.Down(ctx)
+					if err != nil {
+						return err
+					}
+					if mig == nil {
+						fmt.Fprintln(a.out, "No applied migrations")
+						return nil
+					}
+					fmt.Fprintf(a.out, "Rolled back %d_%s\n", mig.Version, mig.Name)
+					return nil
+				},
+			},
+			{
+				name:  "status",
+				short: "List migrations and whether they are applied",
+				run: func(ctx context.Context, a *app, args []string) error {
+					m, err := a.migrator()
+					if err != nil {
+						return err
+					}
+
+					statuses, err := m.Status(ctx)
+					if err != nil {
+						return err
+					}
+
+					tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+					fmt.Fprintln(tw, "APPLIED AT\tMIGRATION")
+					for _, s := range statuses {
+						at := "Pending"
+						if s.Applied {
+							at = s.AppliedAt.Format(time.DateTime)
+						}
+						fmt.Fprintf(tw, "%s\t%d_%s\n", at, s.Version, s.Name)
+					}
+					return tw.Flush()
+				},
+			},
+		},
+	}
+}
+
+// migrator returns a Migrator for the embedded migrations.
+func (a *app) migrator() (*migrate.Migrator, error) {
+	if _, err := a.open(); err != nil {
+		return nil, err
+	}
+	if a.db == nil {
+		return nil, errors.New("migrations need store postgres")
+	}
+	return migrate.New(a.db, migrations.FS)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"main/internal/config"
+	"main/internal/lifecycle"
+	"main/internal/server"
+	"main/internal/tracing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveCommand() *command {
+	var skipMigrations bool
+
+	return &command{
+		name:  "serve",
+		short: "Run the HTTP server until interrupted, then drain it",
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&skipMigrations, "skip-migrations", false, "do not apply pending migrations on startup")
+		},
+		run: func(ctx context.Context, a *app, args []string) error {
+			store, err := a.open()
+			if err != nil {
+				return err
+			}
+
+			// The memory store starts empty and has no schema.
+			if a.cfg.Store != config.StoreMemory {
+				if err := a.migrateOnStartup(ctx, skipMigrations); err != nil {
+					return err
+				}
+			}
+
+			// Requests are logged as JSON; gin's own debug output is
+			// only useful when debugging.
+			if a.cfg.Level() > slog.LevelDebug {
+				gin.SetMode(gin.ReleaseMode)
+			}
+
+			srv, err := server.New(a.cfg, store)
+			if err != nil {
+				return err
+			}
+
+			flush, err := tracing.Setup(ctx, a.cfg.TraceExporter, a.out)
+			if err != nil {
+				return err
+			}
+
+			m := lifecycle.New(a.cfg.ShutdownTimeout)
+			for _, c := range srv.Components() {
+				m.Add(c)
+			}
+			// The session store has its own pool; close it before ours.
+			m.OnClose("session store", srv.Close)
+			m.OnClose("database", a.close)
+			m.OnClose("tracing", func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				return flush(ctx)
+			})
+			return m.Run(ctx)
+		},
+	}
+}
+
+// migrateOnStartup applies pending migrations, or only checks that the
+// schema is not newer than the binary when they are skipped.
+func (a *app) migrateOnStartup(ctx context.Context, skip bool) error {
+	m, err := a.migrator()
+	if err != nil {
+		return err
+	}
+
+	if skip {
+		return m.Check(ctx)
+	}
+
+	applied, err := m.Apply(ctx)
+	for _, mig := range applied {
+		slog.Info("applied migration", slog.Int64("version", mig.Version), slog.String("name", mig.Name))
+	}
+	return err
+}
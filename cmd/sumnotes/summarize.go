@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"main/internal/mailbox"
+	"main/internal/model"
+	"main/internal/server"
+	"main/internal/summarizer"
+)
+
+func summarizeCommand() *command {
+	var email, profileName string
+
+	return &command{
+		name:  "summarize",
+		args:  "<message-id>",
+		short: "Summarize a message and print the summary as it is written",
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&email, "user", "", "email of the mailbox owner")
+			fs.StringVar(&profileName, "profile", "", "prompt profile, defaults to the user's default profile")
+		},
+		run: exactArgs(1, func(ctx context.Context, a *app, args []string) error {
+			if email == "" {
+				return errors.New("--user is required")
+			}
+			user, err := a.authorizedUser(ctx, email)
+			if err != nil {
+				return err
+			}
+
+			prefs, err := a.store.GetPreferences(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+			if prefs == nil {
+				prefs = summarizer.DefaultPreferences(user.ID)
+			}
+			profile, err := summarizer.FindProfile(prefs, profileName)
+			if err != nil {
+				return err
+			}
+
+			client, err := mailbox.NewClientFunc(a.cfg.OAuth2(), a.store, mailbox.NewLimiter(0, 0), mailbox.DefaultBackoff)(ctx, user)
+			if err != nil {
+				return err
+			}
+			res := mailbox.Fetch(ctx, client, user.ID, args, 1)[0]
+			if res.Err != nil {
+				return res.Err
+			}
+			msg := res.Message
+			if err := a.store.SaveMessage(ctx, msg); err != nil {
+				return err
+			}
+
+			instructions, err := summarizer.Render(profile, prefs, msg.Subject, msg.Sender)
+			if err != nil {
+				return err
+			}
+
+			s := server.NewSummarizer(a.cfg, a.store)
+			out, err := summarizer.Stream(ctx, s, summarizer.Request{Instructions: instructions, Content: msg.Markdown}, func(token string) error {
+				_, err := fmt.Fprint(a.out, token)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(a.out)
+
+			_, err = a.store.SaveSummary(ctx, &model.Summary{
+				UserID:    user.ID,
+				MessageID: msg.ID,
+				Text:      out.Text,
+				Model:     s.Model(),
+				Profile:   profile.Name,
+			})
+			return err
+		}),
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"main/internal/auth"
+	"main/internal/model"
+	"main/internal/server"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func userCommand() *command {
+	var yes bool
+
+	return &command{
+		name:  "user",
+		short: "Inspect and manage users",
+		children: []*command{
+			{
+				name:  "list",
+				short: "List every user",
+				run: func(ctx context.Context, a *app, args []string) error {
+					store, err := a.open()
+					if err != nil {
+						return err
+					}
+
+					users, err := store.ListUsers(ctx)
+					if err != nil {
+						return err
+					}
+
+					tw := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+					fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tCREATED AT\tTOKEN EXPIRY")
+					for _, u := range users {
+						fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.ID, u.Email, u.Name, u.CreatedAt.Format(time.DateTime), formatExpiry(u.TokenExpiry))
+					}
+					return tw.Flush()
+				},
+			},
+			{
+				name:  "show",
+				args:  "<email|id>",
+				short: "Show a user, without their tokens",
+				run: exactArgs(1, func(ctx context.Context, a *app, args []string) error {
+					user, err := a.findUser(ctx, args[0])
+					if err != nil {
+						return err
+					}
+
+					enc := json.NewEncoder(a.out)
+					enc.SetIndent("", "  ")
+					return enc.Encode(struct {
+						ID              string
+						Email           string
+						Name            string
+						AvatarURL       string
+						HasRefreshToken bool
+						TokenExpiry     string
+						CreatedAt       time.Time
+						UpdatedAt       time.Time
+					}{user.ID, user.Email, user.Name, user.AvatarURL, user.RefreshToken != "", formatExpiry(user.TokenExpiry), user.CreatedAt, user.UpdatedAt})
+				}),
+			},
+			{
+				name:  "delete",
+				args:  "<email|id>",
+				short: "Delete a user and all their data",
+				flags: func(fs *flag.FlagSet) {
+					fs.BoolVar(&yes, "yes", false, "confirm the deletion")
+				},
+				run: exactArgs(1, func(ctx context.Context, a *app, args []string) error {
+					user, err := a.findUser(ctx, args[0])
+					if err != nil {
+						return err
+					}
+					if !yes {
+						return fmt.Errorf("refusing to delete %s without --yes", user.Email)
+					}
+
+					if err := a.store.DeleteUser(ctx, user.ID); err != nil {
+						return err
+					}
+					fmt.Fprintf(a.out, "Deleted %s (%s)\n", user.Email, user.ID)
+					return nil
+				}),
+			},
+		},
+	}
+}
+
+func tokenCommand() *command {
+	return &command{
+		name:  "token",
+		short: "Manage OAuth tokens",
+		children: []*command{
+			{
+				name:  "refresh",
+				args:  "<email|id>",
+				short: "Refresh a user's Google access token",
+				run: exactArgs(1, func(ctx context.Context, a *app, args []string) error {
+					user, err := a.findUser(ctx, args[0])
+					if err != nil {
+						return err
+					}
+
+					if err := auth.RefreshToken(ctx, user, a.store, server.NewProvider(a.cfg)); err != nil {
+						return err
+					}
+
+					user, err = a.store.FindUserByID(ctx, user.ID)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(a.out, "Refreshed token of %s, valid until %s\n", user.Email, formatExpiry(user.TokenExpiry))
+					return nil
+				}),
+			},
+		},
+	}
+}
+
+// findUser looks a user up by email, or by id when ref is not an email.
+func (a *app) findUser(ctx context.Context, ref string) (*model.User, error) {
+	store, err := a.open()
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	if strings.Contains(ref, "@") {
+		user, err = store.FindUserByEmail(ctx, ref)
+	} else {
+		user, err = store.FindUserByID(ctx, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("no user %q", ref)
+	}
+	return user, nil
+}
+
+// authorizedUser returns the user with a valid access token, refreshing it
+// when it expired.
+func (a *app) authorizedUser(ctx context.Context, ref string) (*model.User, error) {
+	user, err := a.findUser(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Before(user.TokenExpiry) {
+		return user, nil
+	}
+
+	if err := auth.RefreshToken(ctx, user, a.store, server.NewProvider(a.cfg)); err != nil {
+		if errors.Is(err, auth.ErrRefreshFailed) {
+			return nil, fmt.Errorf("%w: %s has to sign in again", err, user.Email)
+		}
+		return nil, err
+	}
+	return a.store.FindUserByID(ctx, user.ID)
+}
+
+func formatExpiry(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
@@ -1,25 +1,10 @@
 import { Link } from '@tanstack/react-router'
 import { useQuery } from '@tanstack/react-query'
 
-export type User = {
-  ID: string
-  Name: string
-  Email: string
-  AvatarURL: string
-  CreatedAt: string
-}
-
-export const fetchMe = async (): Promise<User> => {
-  const response = await fetch('http://localhost:9999/api/me', {
-    credentials: 'include',
-  })
+import { api } from '../lib/api'
+import type { User } from '../lib/api'
 
-  if (!response.ok) {
-    throw new Error('Failed to fetch user')
-  }
-
-  return response.json()
-}
+export const fetchMe = (): Promise<User> => api.getMe()
 
 export default function Header() {
   const { data, error, isLoading } = useQuery({
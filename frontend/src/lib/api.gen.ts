@@ -0,0 +1,350 @@
+// Code generated by internal/openapi from openapi.json. DO NOT EDIT.
+
+export interface ActionBatch {
+  createdAt: string
+  id: string
+  operations: LabelOperation[] | null
+  undoneAt?: string
+}
+
+/** The mailbox actions applied after a message is summarized. */
+export interface ActionSettings {
+  addSummarizedLabel: boolean
+  applyCategoryLabels: boolean
+  archive: boolean
+  markRead: boolean
+  updatedAt?: string
+}
+
+/** The progress of an import of older mail. */
+export interface Backfill {
+  error?: string
+  failed: number
+  lastMessageId: string
+  processed: number
+  since: string
+  startedAt: string
+  status: 'running' | 'done' | 'failed' | 'stopped'
+  updatedAt: string
+}
+
+export interface DraftReply {
+  body: string
+  draftId: string
+  subject: string
+  threadId: string
+  to: string
+}
+
+/** The body of every failed API response. */
+export interface ErrorResponse {
+  error: {
+    code: 'bad_request' | 'unauthenticated' | 'token_expired' | 'token_revoked' | 'forbidden' | 'not_found' | 'conflict' | 'unprocessable' | 'gmail_quota' | 'upstream_error' | 'unavailable' | 'internal'
+    details?: Record<string, unknown>
+    /** Safe to show to the user. */
+    message: string
+    /** The X-Request-ID of the request, to find it in the logs. */
+    requestId?: string
+  }
+}
+
+export interface FetchMessageError {
+  error: string
+  id: string
+  stage: 'fetch' | 'convert'
+}
+
+export interface FetchMessagesRequest {
+  ids: string[]
+}
+
+export interface FetchMessagesResponse {
+  errors: FetchMessageError[]
+  messages: Message[]
+}
+
+export interface Home {
+  Message: string
+}
+
+export interface LabelOperation {
+  addLabelIds?: string[]
+  messageIds: string[]
+  removeLabelIds?: string[]
+}
+
+export interface Message {
+  createdAt: string
+  id: string
+  labelIds: string[] | null
+  markdown?: string
+  receivedAt: string
+  sender: string
+  snippet: string
+  subject: string
+  threadId: string
+  updatedAt: string
+}
+
+/** How summaries are written for the user. */
+export interface Preferences {
+  defaultProfile: string
+  focusActionItems: boolean
+  format: 'bullets' | 'prose'
+  language: string
+  length: 'line' | 'paragraph' | 'detailed'
+  profiles: PromptProfile[]
+  updatedAt?: string
+}
+
+export interface PreferencesResponse {
+  builtinProfiles: PromptProfile[]
+  preferences: Preferences
+}
+
+export interface PromptProfile {
+  name: string
+  /** A Go template rendering the summarizer instructions. */
+  template: string
+}
+
+export interface ReplyOptions {
+  length?: 'short' | 'medium' | 'long'
+  /** Points the reply should make. */
+  notes?: string
+  tone?: 'neutral' | 'friendly' | 'formal' | 'concise'
+}
+
+export interface SearchResult {
+  id: string
+  labelIds: string[] | null
+  rank: number
+  receivedAt: string
+  sender: string
+  snippet: string
+  subject: string
+  summarySnippet?: string
+  threadId: string
+}
+
+export interface SemanticResult {
+  id: string
+  passage: string
+  receivedAt: string
+  score: number
+  sender: string
+  subject: string
+  threadId: string
+}
+
+export interface StartBackfillRequest {
+  /** Import mail received since this past day. */
+  since: string
+}
+
+/** A mailing list the user receives. */
+export interface Subscription {
+  id: string
+  lastSeenAt: string
+  messageCount: number
+  oneClick: boolean
+  readCount: number
+  sender: string
+  senderName: string
+  unsubscribeMailto?: string
+  unsubscribeUrl?: string
+  unsubscribedAt?: string
+  updatedAt: string
+}
+
+export interface Summary {
+  createdAt: string
+  id: string
+  messageId: string
+  model: string
+  profile: string
+  text: string
+}
+
+export interface UnsubscribeResponse {
+  method: 'one-click' | 'mailto'
+  subscription: Subscription
+}
+
+/** The signed in user. */
+export interface User {
+  AvatarURL: string
+  CreatedAt: string
+  Email: string
+  ID: string
+  Name: string
+}
+
+export class ApiError extends Error {
+  readonly status: number
+  readonly error?: ErrorResponse['error']
+
+  constructor(status: number, error?: ErrorResponse['error']) {
+    super(error?.message ?? `request failed with status ${status}`)
+    this.status = status
+    this.error = error
+  }
+}
+
+type Query = Record<string, string | number | boolean | undefined>
+
+type Options = {
+  query?: Query
+  body?: unknown
+  init?: RequestInit
+}
+
+/**
+ * createClient returns the API methods, sending requests to baseUrl with
+ * defaults. Failed requests reject with an ApiError.
+ */
+export function createClient(baseUrl = '', defaults: RequestInit = {}) {
+  async function request(
+    method: string,
+    path: string,
+    { query, body, init }: Options,
+  ): Promise<Response> {
+    const params = new URLSearchParams()
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined) params.set(key, String(value))
+    }
+    const search = params.toString() ? `?${params}` : ''
+
+    const headers = new Headers(defaults.headers)
+    new Headers(init?.headers).forEach((value, key) => headers.set(key, value))
+    if (body !== undefined) headers.set('Content-Type', 'application/json')
+
+    const response = await fetch(baseUrl + path + search, {
+      ...defaults,
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    })
+    if (!response.ok) {
+      let error: ErrorResponse['error'] | undefined
+      try {
+        error = ((await response.json()) as ErrorResponse).error
+      } catch {
+        // Not an API error, such as a proxy failure.
+      }
+      throw new ApiError(response.status, error)
+    }
+    return response
+  }
+
+  return {
+    /** Identifies the API. */
+    getHome: async (init?: RequestInit): Promise<Home> => {
+      const response = await request('GET', `/api/`, { init })
+      return (await response.json()) as Home
+    },
+    /** Lists the latest mailbox action batches. */
+    getActionLog: async (init?: RequestInit): Promise<ActionBatch[] | null> => {
+      const response = await request('GET', `/api/actions/log`, { init })
+      return (await response.json()) as ActionBatch[] | null
+    },
+    /** Returns the mailbox actions applied after summaries. */
+    getActionSettings: async (init?: RequestInit): Promise<ActionSettings> => {
+      const response = await request('GET', `/api/actions/settings`, { init })
+      return (await response.json()) as ActionSettings
+    },
+    /** Replaces the mailbox actions applied after summaries. */
+    updateActionSettings: async (body: ActionSettings, init?: RequestInit): Promise<ActionSettings> => {
+      const response = await request('PUT', `/api/actions/settings`, { body, init })
+      return (await response.json()) as ActionSettings
+    },
+    /** Reverts the latest action batch. */
+    undoActions: async (init?: RequestInit): Promise<ActionBatch> => {
+      const response = await request('POST', `/api/actions/undo`, { init })
+      return (await response.json()) as ActionBatch
+    },
+    /** Refreshes the Google access token of the session. */
+    refreshToken: async (init?: RequestInit): Promise<User> => {
+      const response = await request('GET', `/api/auth/refresh`, { init })
+      return (await response.json()) as User
+    },
+    /** Returns the progress of the latest import of older mail. */
+    getBackfill: async (init?: RequestInit): Promise<Backfill> => {
+      const response = await request('GET', `/api/backfill`, { init })
+      return (await response.json()) as Backfill
+    },
+    /** Imports older mail in the background, resuming an interrupted import. */
+    startBackfill: async (body: StartBackfillRequest, init?: RequestInit): Promise<void> => {
+      await request('POST', `/api/backfill`, { body, init })
+    },
+    /** Interrupts the running import. */
+    stopBackfill: async (init?: RequestInit): Promise<void> => {
+      await request('DELETE', `/api/backfill`, { init })
+    },
+    /** Returns the signed in user. */
+    getMe: async (init?: RequestInit): Promise<User> => {
+      const response = await request('GET', `/api/me`, { init })
+      return (await response.json()) as User
+    },
+    /** Returns the summary preferences and built-in prompt profiles. */
+    getPreferences: async (init?: RequestInit): Promise<PreferencesResponse> => {
+      const response = await request('GET', `/api/me/preferences`, { init })
+      return (await response.json()) as PreferencesResponse
+    },
+    /** Replaces the summary preferences. */
+    updatePreferences: async (body: Preferences, init?: RequestInit): Promise<Preferences> => {
+      const response = await request('PUT', `/api/me/preferences`, { body, init })
+      return (await response.json()) as Preferences
+    },
+    /** Fetches and stores messages; failed ones are listed in errors. */
+    fetchMessages: async (body: FetchMessagesRequest, init?: RequestInit): Promise<FetchMessagesResponse> => {
+      const response = await request('POST', `/api/messages/fetch`, { body, init })
+      return (await response.json()) as FetchMessagesResponse
+    },
+    /** Proposes a reply and saves it as a Gmail draft. */
+    draftReply: async (id: string, body?: ReplyOptions, init?: RequestInit): Promise<DraftReply> => {
+      const response = await request('POST', `/api/messages/${encodeURIComponent(id)}/draft-reply`, { body, init })
+      return (await response.json()) as DraftReply
+    },
+    /** Summarizes a message. */
+    summarizeMessage: async (id: string, query?: { profile?: string }, init?: RequestInit): Promise<Summary> => {
+      const response = await request('GET', `/api/messages/${encodeURIComponent(id)}/summary`, { query, init })
+      return (await response.json()) as Summary
+    },
+    /** Drops the cached summary of a message so it is regenerated. */
+    invalidateMessageSummary: async (id: string, query?: { profile?: string }, init?: RequestInit): Promise<void> => {
+      await request('DELETE', `/api/messages/${encodeURIComponent(id)}/summary/cache`, { query, init })
+    },
+    /** This document. */
+    getOpenAPI: async (init?: RequestInit): Promise<Record<string, unknown>> => {
+      const response = await request('GET', `/api/openapi.json`, { init })
+      return (await response.json()) as Record<string, unknown>
+    },
+    /** Full-text search of stored messages. */
+    search: async (query: { q: string; sender?: string; label?: string; after?: string; before?: string; limit?: number; offset?: number }, init?: RequestInit): Promise<SearchResult[] | null> => {
+      const response = await request('GET', `/api/search`, { query, init })
+      return (await response.json()) as SearchResult[] | null
+    },
+    /** Finds messages by meaning. */
+    semanticSearch: async (query: { q: string; limit?: number }, init?: RequestInit): Promise<SemanticResult[] | null> => {
+      const response = await request('GET', `/api/search/semantic`, { query, init })
+      return (await response.json()) as SemanticResult[] | null
+    },
+    /** Scans recent mail for mailing lists. */
+    listSubscriptions: async (query?: { limit?: number }, init?: RequestInit): Promise<Subscription[] | null> => {
+      const response = await request('GET', `/api/subscriptions`, { query, init })
+      return (await response.json()) as Subscription[] | null
+    },
+    /** Unsubscribes from a mailing list, once. */
+    unsubscribe: async (id: string, init?: RequestInit): Promise<UnsubscribeResponse> => {
+      const response = await request('POST', `/api/subscriptions/${encodeURIComponent(id)}/unsubscribe`, { init })
+      return (await response.json()) as UnsubscribeResponse
+    },
+    /** Converts the latest message to Markdown. */
+    summarizeLatest: async (init?: RequestInit): Promise<string> => {
+      const response = await request('GET', `/api/summaries`, { init })
+      return response.text()
+    },
+  }
+}
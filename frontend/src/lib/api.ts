@@ -1,13 +1,34 @@
+import { createClient } from './api.gen'
+
+export * from './api.gen'
+
+// api calls the backend with the session cookie. Its methods are generated
+// from the backend's OpenAPI document by `go generate ./internal/openapi`.
+export const api = createClient('http://localhost:9999', {
+  credentials: 'include',
+})
+
 const orignalFetch = window.fetch
 
 let refreshPromise: Promise<Response> | null = null
 
+// Failed API responses are {"error":{"code","message","requestId"}}.
+async function errorCode(response: Response): Promise<string | undefined> {
+  try {
+    const body = await response.clone().json()
+    return body?.error?.code
+  } catch {
+    return undefined
+  }
+}
+
 window.fetch = async (...args) => {
   const [url, config] = args
 
   let response = await orignalFetch(url, config)
 
-  if (response.status === 404) {
+  if (response.status === 401 && (await errorCode(response)) !== 'token_expired') {
+    // unauthenticated or token_revoked: only signing in again helps.
     window.location.href = 'http://localhost:9999/api/auth/google'
     return new Promise(() => {})
   }
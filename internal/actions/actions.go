@@ -0,0 +1,279 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/internal/database"
+	"main/internal/mailbox"
+	"main/internal/model"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const (
+	// SummarizedLabel marks messages that sumnotes has already summarized.
+	SummarizedLabel = "sumnotes/summarized"
+
+	// maxModifyIDs is the most message ids Gmail accepts per BatchModify.
+	maxModifyIDs = 1000
+
+	categoryPrefix = "CATEGORY_"
+	labelUnread    = "UNREAD"
+	labelInbox     = "INBOX"
+)
+
+var (
+	ErrNothingToUndo = errors.New("no action batch to undo")
+)
+
+// ModifyError reports that Gmail failed to change the labels of messages,
+// as opposed to the action log failing.
+type ModifyError struct {
+	Err error
+}
+
+func (e *ModifyError) Error() string {
+	return fmt.Sprintf("failed to modify messages: %v", e.Err)
+}
+
+func (e *ModifyError) Unwrap() error {
+	return e.Err
+}
+
+// Runner applies post-summary actions to a Gmail mailbox and records them
+// in the user's action log.
+type Runner struct {
+	store database.ActionStore
+}
+
+// NewRunner creates a new Runner.
+func NewRunner(store database.ActionStore) *Runner {
+	return &Runner{store}
+}
+
+// Apply modifies the labels of msgs according to settings. Messages must be
+// fetched with their label ids so only real changes are recorded. It returns
+// nil if nothing had to change. When a modification fails, the ones already
+// made are still recorded so they can be undone.
+func (r *Runner) Apply(ctx context.Context, c *mailbox.Client, userID string, settings *model.ActionSettings, msgs []*gmail.Message) (*model.ActionBatch, error) {
+	if !settings.Enabled() || len(msgs) == 0 {
+		return nil, nil
+	}
+
+	labels := newLabelCache(c)
+
+	groups := map[string]*model.LabelOperation{}
+	var keys []string
+
+	for _, m := range msgs {
+		wanted, err := r.wantedLabels(ctx, labels, settings, m)
+		if err != nil {
+			return nil, err
+		}
+
+		add, remove := diff(m.LabelIds, wanted, settings)
+		if len(add) == 0 && len(remove) == 0 {
+			continue
+		}
+
+		key := strings.Join(add, ",") + "|" + strings.Join(remove, ",")
+		op, ok := groups[key]
+		if !ok {
+			op = &model.LabelOperation{AddLabelIDs: add, RemoveLabelIDs: remove}
+			groups[key] = op
+			keys = append(keys, key)
+		}
+		op.MessageIDs = append(op.MessageIDs, m.Id)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	batch := &model.ActionBatch{UserID: userID}
+	for _, k := range keys {
+		op := groups[k]
+		done, err := modify(ctx, c, op.MessageIDs, op.AddLabelIDs, op.RemoveLabelIDs)
+		if done > 0 {
+			applied := *op
+			applied.MessageIDs = op.MessageIDs[:done]
+			batch.Operations = append(batch.Operations, applied)
+		}
+		if err != nil {
+			if len(batch.Operations) > 0 {
+				if _, saveErr := r.store.CreateActionBatch(ctx, batch); saveErr != nil {
+					return nil, errors.Join(err, saveErr)
+				}
+			}
+			return nil, err
+		}
+	}
+
+	return r.store.CreateActionBatch(ctx, batch)
+}
+
+// Undo reverses the user's most recent batch that has not been undone yet.
+// When a modification fails, the batch is replaced by what is left to revert
+// so a retry does not revert the same messages again.
+func (r *Runner) Undo(ctx context.Context, c *mailbox.Client, userID string) (*model.ActionBatch, error) {
+	batch, err := r.store.LastActionBatch(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrNothingToUndo
+	}
+
+	for i, op := range batch.Operations {
+		done, err := modify(ctx, c, op.MessageIDs, op.RemoveLabelIDs, op.AddLabelIDs)
+		if err != nil {
+			if i > 0 || done > 0 {
+				if saveErr := r.replaceRemaining(ctx, batch, i, done); saveErr != nil {
+					return nil, errors.Join(err, saveErr)
+				}
+			}
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if err := r.store.MarkActionBatchUndone(ctx, batch.ID, now); err != nil {
+		return nil, err
+	}
+	batch.UndoneAt = &now
+
+	return batch, nil
+}
+
+// replaceRemaining records the operations of batch from the done-th message
+// of operation i on as a new batch and marks batch undone.
+func (r *Runner) replaceRemaining(ctx context.Context, batch *model.ActionBatch, i, done int) error {
+	op := batch.Operations[i]
+	op.MessageIDs = op.MessageIDs[done:]
+	remaining := &model.ActionBatch{
+		UserID:     batch.UserID,
+		Operations: append([]model.LabelOperation{op}, batch.Operations[i+1:]...),
+	}
+	if _, err := r.store.CreateActionBatch(ctx, remaining); err != nil {
+		return err
+	}
+	return r.store.MarkActionBatchUndone(ctx, batch.ID, time.Now())
+}
+
+func (r *Runner) wantedLabels(ctx context.Context, labels *labelCache, settings *model.ActionSettings, m *gmail.Message) ([]string, error) {
+	var names []string
+	if settings.AddSummarizedLabel {
+		names = append(names, SummarizedLabel)
+	}
+	if settings.ApplyCategoryLabels {
+		for _, id := range m.LabelIds {
+			if strings.HasPrefix(id, categoryPrefix) {
+				names = append(names, "sumnotes/"+strings.ToLower(strings.TrimPrefix(id, categoryPrefix)))
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, err := labels.ensure(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// diff returns the label ids that need to be added to and removed from a
+// message currently carrying current.
+func diff(current, wanted []string, settings *model.ActionSettings) (add, remove []string) {
+	for _, id := range wanted {
+		if !slices.Contains(current, id) && !slices.Contains(add, id) {
+			add = append(add, id)
+		}
+	}
+	if settings.MarkRead && slices.Contains(current, labelUnread) {
+		remove = append(remove, labelUnread)
+	}
+	if settings.Archive && slices.Contains(current, labelInbox) {
+		remove = append(remove, labelInbox)
+	}
+
+	sort.Strings(add)
+	sort.Strings(remove)
+	return add, remove
+}
+
+// modify changes the labels of ids, maxModifyIDs at a time. It returns how
+// many of ids were modified, which is less than all of them on error.
+func modify(ctx context.Context, c *mailbox.Client, ids, add, remove []string) (int, error) {
+	for start := 0; start < len(ids); start += maxModifyIDs {
+		end := min(start+maxModifyIDs, len(ids))
+		err := c.Call(ctx, "messages.batchModify", func() error {
+			return c.Service.Users.Messages.BatchModify(mailbox.User, &gmail.BatchModifyMessagesRequest{
+				Ids:            ids[start:end],
+				AddLabelIds:    add,
+				RemoveLabelIds: remove,
+			}).Context(ctx).Do()
+		})
+		if err != nil {
+			return start, &ModifyError{Err: err}
+		}
+	}
+	return len(ids), nil
+}
+
+// labelCache resolves label names to ids, creating missing labels on demand.
+type labelCache struct {
+	c      *mailbox.Client
+	byName map[string]string
+}
+
+func newLabelCache(c *mailbox.Client) *labelCache {
+	return &labelCache{c: c}
+}
+
+func (l *labelCache) ensure(ctx context.Context, name string) (string, error) {
+	if l.byName == nil {
+		var res *gmail.ListLabelsResponse
+		err := l.c.Call(ctx, "labels.list", func() error {
+			var err error
+			res, err = l.c.Service.Users.Labels.List(mailbox.User).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to list labels: %w", err)
+		}
+		l.byName = map[string]string{}
+		for _, lb := range res.Labels {
+			l.byName[lb.Name] = lb.Id
+		}
+	}
+
+	if id, ok := l.byName[name]; ok {
+		return id, nil
+	}
+
+	var lb *gmail.Label
+	err := l.c.Call(ctx, "labels.create", func() error {
+		var err error
+		lb, err = l.c.Service.Users.Labels.Create(mailbox.User, &gmail.Label{
+			Name:                  name,
+			LabelListVisibility:   "labelShow",
+			MessageListVisibility: "show",
+		}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create label %q: %w", name, err)
+	}
+	l.byName[name] = lb.Id
+
+	return lb.Id, nil
+}
@@ -0,0 +1,213 @@
+// Package apierr defines the errors the API reports to clients. An Error has
+// a stable code clients can switch on and a message safe to show; the error
+// that caused it is kept for logs and never rendered.
+package apierr
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Code identifies the kind of failure.
+type Code string
+
+const (
+	BadRequest Code = "bad_request"
+	// Unauthenticated means there is no session; the client signs in.
+	Unauthenticated Code = "unauthenticated"
+	// TokenExpired means the Google access token expired; the client
+	// refreshes it and retries.
+	TokenExpired Code = "token_expired"
+	// TokenRevoked means Google no longer accepts the user's tokens; the
+	// client signs in again.
+	TokenRevoked  Code = "token_revoked"
+	Forbidden     Code = "forbidden"
+	NotFound      Code = "not_found"
+	Conflict      Code = "conflict"
+	Unprocessable Code = "unprocessable"
+	// GmailQuota means Gmail rate limited the user; the client retries later.
+	GmailQuota Code = "gmail_quota"
+	// Upstream means Gmail, the summarizer or another dependency failed.
+	Upstream    Code = "upstream_error"
+	Unavailable Code = "unavailable"
+	Internal    Code = "internal"
+)
+
+var statuses = map[Code]int{
+	BadRequest:      http.StatusBadRequest,
+	Unauthenticated: http.StatusUnauthorized,
+	TokenExpired:    http.StatusUnauthorized,
+	TokenRevoked:    http.StatusUnauthorized,
+	Forbidden:       http.StatusForbidden,
+	NotFound:        http.StatusNotFound,
+	Conflict:        http.StatusConflict,
+	Unprocessable:   http.StatusUnprocessableEntity,
+	GmailQuota:      http.StatusTooManyRequests,
+	Upstream:        http.StatusBadGateway,
+	Unavailable:     http.StatusServiceUnavailable,
+	Internal:        http.StatusInternalServerError,
+}
+
+var messages = map[Code]string{
+	BadRequest:      "invalid request",
+	Unauthenticated: "not signed in",
+	TokenExpired:    "access token expired",
+	TokenRevoked:    "Google access was revoked, sign in again",
+	Forbidden:       "access denied",
+	NotFound:        "not found",
+	Conflict:        "conflicts with the current state",
+	Unprocessable:   "cannot be processed",
+	GmailQuota:      "Gmail rate limit exceeded, try again later",
+	Upstream:        "an upstream service failed",
+	Unavailable:     "service unavailable, try again later",
+	Internal:        "internal server error",
+}
+
+// Status returns the HTTP status of the code, 500 for unknown codes.
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an API failure.
+type Error struct {
+	Code Code
+	// Message is shown to the client.
+	Message string
+	// Details are rendered along with the message, such as the URL to
+	// unsubscribe manually.
+	Details any
+	// Err is the cause, only logged.
+	Err error
+}
+
+// New creates an Error. An empty message uses the default of the code.
+func New(code Code, message string) *Error {
+	if message == "" {
+		message = messages[code]
+	}
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap creates an Error caused by err.
+func Wrap(err error, code Code, message string) *Error {
+	e := New(code, message)
+	e.Err = err
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code) + ": " + e.Message
+	if e.Err != nil && e.Err.Error() != e.Message {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status of the error's code.
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// WithDetails sets the details rendered with the error.
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// From returns err as an Error. Errors of Google APIs and OAuth token
+// refreshes are mapped to their codes and anything else is internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if e := fromGoogle(err); e != nil {
+		return e
+	}
+	return Wrap(err, Internal, "")
+}
+
+// Gmail returns the failure of a Gmail call as an Error. Errors that Gmail
+// did not classify, such as network failures, are upstream errors.
+func Gmail(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if e := fromGoogle(err); e != nil {
+		return e
+	}
+	return Wrap(err, Upstream, "Gmail request failed")
+}
+
+// FromStatus returns an Error for a response status set without an error,
+// such as 404 for unknown routes.
+func FromStatus(status int) *Error {
+	switch {
+	case status == http.StatusUnauthorized:
+		return New(Unauthenticated, "")
+	case status == http.StatusForbidden:
+		return New(Forbidden, "")
+	case status == http.StatusNotFound:
+		return New(NotFound, "")
+	case status >= http.StatusInternalServerError:
+		return New(Internal, "")
+	default:
+		return New(BadRequest, http.StatusText(status))
+	}
+}
+
+func fromGoogle(err error) *Error {
+	var rerr *oauth2.RetrieveError
+	if errors.As(err, &rerr) {
+		// invalid_grant is how Google reports a revoked or expired
+		// refresh token.
+		if rerr.ErrorCode == "invalid_grant" || rerr.Response != nil && rerr.Response.StatusCode == http.StatusUnauthorized {
+			return Wrap(err, TokenRevoked, "")
+		}
+		return Wrap(err, Upstream, "Google sign in failed")
+	}
+
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return nil
+	}
+	switch {
+	case gerr.Code == http.StatusUnauthorized:
+		return Wrap(err, TokenRevoked, "")
+	case gerr.Code == http.StatusTooManyRequests, rateLimited(gerr):
+		return Wrap(err, GmailQuota, "")
+	case gerr.Code == http.StatusForbidden:
+		return Wrap(err, Forbidden, "Gmail denied access")
+	case gerr.Code == http.StatusNotFound:
+		return Wrap(err, NotFound, "not found in Gmail")
+	case gerr.Code == http.StatusBadRequest:
+		return Wrap(err, BadRequest, "Gmail rejected the request")
+	default:
+		return Wrap(err, Upstream, "Gmail request failed")
+	}
+}
+
+// rateLimited reports whether a 403 is a per-user rate limit or quota.
+func rateLimited(gerr *googleapi.Error) bool {
+	if gerr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, item := range gerr.Errors {
+		switch item.Reason {
+		case "rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded":
+			return true
+		}
+	}
+	return false
+}
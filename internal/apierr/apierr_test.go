@@ -0,0 +1,68 @@
+package apierr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+func TestFrom(t *testing.T) {
+	quota := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}
+
+	tests := []struct {
+		name   string
+		err    error
+		code   Code
+		status int
+	}{
+		{"api error", New(Conflict, "already running"), Conflict, http.StatusConflict},
+		{"wrapped api error", fmt.Errorf("start: %w", New(NotFound, "")), NotFound, http.StatusNotFound},
+		{"unauthorized", &googleapi.Error{Code: http.StatusUnauthorized}, TokenRevoked, http.StatusUnauthorized},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, GmailQuota, http.StatusTooManyRequests},
+		{"rate limit reason", quota, GmailQuota, http.StatusTooManyRequests},
+		{"forbidden", &googleapi.Error{Code: http.StatusForbidden}, Forbidden, http.StatusForbidden},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound}, NotFound, http.StatusNotFound},
+		{"gmail server error", &googleapi.Error{Code: http.StatusServiceUnavailable}, Upstream, http.StatusBadGateway},
+		{"revoked refresh token", &url.Error{Op: "Post", URL: "https://oauth2.googleapis.com/token", Err: &oauth2.RetrieveError{ErrorCode: "invalid_grant"}}, TokenRevoked, http.StatusUnauthorized},
+		{"other error", errors.New("pq: connection refused"), Internal, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := From(tt.err)
+			assert.Equal(t, tt.code, e.Code)
+			assert.Equal(t, tt.status, e.Status())
+			assert.NotEmpty(t, e.Message)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("pq: connection refused")
+	e := From(cause)
+
+	assert.Equal(t, "internal server error", e.Message)
+	assert.Equal(t, "internal: internal server error: pq: connection refused", e.Error())
+	assert.ErrorIs(t, e, cause)
+
+	assert.Equal(t, "bad_request: q is required", Wrap(errors.New("q is required"), BadRequest, "q is required").Error())
+}
+
+func TestGmail(t *testing.T) {
+	assert.Equal(t, Upstream, Gmail(errors.New("connection reset")).Code)
+	assert.Equal(t, GmailQuota, Gmail(&googleapi.Error{Code: http.StatusTooManyRequests}).Code)
+}
+
+func TestFromStatus(t *testing.T) {
+	assert.Equal(t, NotFound, FromStatus(http.StatusNotFound).Code)
+	assert.Equal(t, Internal, FromStatus(http.StatusBadGateway).Code)
+
+	e := FromStatus(http.StatusMethodNotAllowed)
+	assert.Equal(t, BadRequest, e.Code)
+	assert.Equal(t, "Method Not Allowed", e.Message)
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"encoding/base32"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// MemoryStore is a sessions.Store keeping session values in memory, for
+// local development without Postgres. Like pgstore, the cookie only holds
+// the signed session id. Sessions are lost when the process exits.
+type MemoryStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+
+	mu       sync.Mutex
+	sessions map[string]memorySession
+}
+
+type memorySession struct {
+	values    map[any]any
+	expiresAt time.Time
+}
+
+var _ sessions.Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a MemoryStore signing its cookies with keyPairs.
+func NewMemoryStore(opts *sessions.Options, keyPairs ...[]byte) *MemoryStore {
+	return &MemoryStore{
+		Codecs:   securecookie.CodecsFromPairs(keyPairs...),
+		Options:  opts,
+		sessions: map[string]memorySession{},
+	}
+}
+
+func (s *MemoryStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session of the request's cookie, or a new session when
+// there is none or it expired.
+func (s *MemoryStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.sessions[session.ID]
+	if !ok {
+		return session, nil
+	}
+	if time.Now().After(stored.expiresAt) {
+		delete(s.sessions, session.ID)
+		return session, nil
+	}
+	session.Values = maps.Clone(stored.values)
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session and sets its cookie, or deletes both when the
+// session's MaxAge is negative. Expired sessions are dropped on the way.
+func (s *MemoryStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, stored := range s.sessions {
+		if now.After(stored.expiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+
+	if session.Options.MaxAge < 0 {
+		delete(s.sessions, session.ID)
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	s.sessions[session.ID] = memorySession{
+		values:    maps.Clone(session.Values),
+		expiresAt: now.Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
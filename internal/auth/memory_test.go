@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(CookieOptions(false, http.SameSiteLaxMode), []byte("0123456789abcdef0123456789abcdef"))
+
+	// request carries the cookies of a previous response.
+	request := func(prev *httptest.ResponseRecorder) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if prev != nil {
+			for _, c := range prev.Result().Cookies() {
+				req.AddCookie(c)
+			}
+		}
+		return req
+	}
+
+	req := request(nil)
+	session, err := GetSession(store, req)
+	require.NoError(t, err)
+	assert.True(t, session.IsNew)
+
+	session.Values["user_id"] = "u1"
+	login := httptest.NewRecorder()
+	require.NoError(t, session.Save(req, login))
+	assert.NotContains(t, login.Header().Get("Set-Cookie"), "u1", "the cookie only holds the session id")
+
+	session, err = GetSession(store, request(login))
+	require.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, "u1", session.Values["user_id"])
+
+	// A cookie signed with another key is rejected.
+	other := NewMemoryStore(CookieOptions(false, http.SameSiteLaxMode), []byte("fedcba9876543210fedcba9876543210"))
+	_, err = GetSession(other, request(login))
+	assert.Error(t, err)
+
+	req = request(login)
+	session, err = GetSession(store, req)
+	require.NoError(t, err)
+	session.Options.MaxAge = -1
+	logout := httptest.NewRecorder()
+	require.NoError(t, session.Save(req, logout))
+
+	session, err = GetSession(store, request(login))
+	require.NoError(t, err)
+	assert.True(t, session.IsNew, "deleted sessions are gone even with the old cookie")
+	assert.Empty(t, session.Values)
+}
@@ -11,23 +11,35 @@ const (
 	SessionName = "sumnotes_session"
 )
 
-func NewStore(dbURL string, keyPairs ...[]byte) (*pgstore.PGStore, error) {
+// CookieOptions returns the options of the session and OAuth state cookies.
+func CookieOptions(secure bool, sameSite http.SameSite) *sessions.Options {
+	return &sessions.Options{
+		Path:     "/",
+		MaxAge:   86400 * 7, // 7 days
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	}
+}
+
+func NewStore(dbURL string, opts *sessions.Options, keyPairs ...[]byte) (*pgstore.PGStore, error) {
 	store, err := pgstore.NewPGStore(dbURL, keyPairs...)
 	if err != nil {
 		return nil, err
 	}
 
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   86400 * 7, // 7 days
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	}
+	store.Options = opts
 
 	return store, nil
 }
 
+// NewStateStore creates the cookie store gothic keeps the OAuth state in.
+func NewStateStore(opts *sessions.Options, keyPairs ...[]byte) *sessions.CookieStore {
+	store := sessions.NewCookieStore(keyPairs...)
+	store.Options = opts
+	return store
+}
+
 func GetSession(store sessions.Store, r *http.Request) (*sessions.Session, error) {
 	return store.Get(r, SessionName)
 }
@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"main/internal/database"
+	"main/internal/metrics"
 	"main/internal/model"
 
 	"github.com/markbates/goth"
@@ -13,15 +15,18 @@ var (
 	ErrRefreshFailed = errors.New("failed to refresh token with provider")
 )
 
-func RefreshToken(u *model.User, db database.UserStore, p goth.Provider) error {
+func RefreshToken(ctx context.Context, u *model.User, db database.UserStore, p goth.Provider) error {
 	newToken, err := p.RefreshToken(u.RefreshToken)
 	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("provider_error").Inc()
 		return ErrRefreshFailed
 	}
 
-	err = db.UpdateUserTokens(u.ID, newToken.AccessToken, newToken.RefreshToken, newToken.Expiry)
+	err = db.UpdateUserTokens(ctx, u.ID, newToken.AccessToken, newToken.RefreshToken, newToken.Expiry)
 	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("store_error").Inc()
 		return fmt.Errorf("failed to update user tokens in database: %w", err)
 	}
+	metrics.TokenRefreshes.WithLabelValues("success").Inc()
 	return nil
 }
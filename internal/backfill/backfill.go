@@ -0,0 +1,353 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"main/internal/database"
+	"main/internal/mailbox"
+	"main/internal/metrics"
+	"main/internal/model"
+	"main/internal/summarizer"
+	"sync"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// pageSize is the number of message ids listed per Messages.List call.
+const pageSize = 100
+
+var (
+	ErrRunning    = errors.New("a backfill is already running for this user")
+	ErrNotRunning = errors.New("no backfill is running for this user")
+	ErrClosed     = errors.New("backfills are shutting down")
+	ErrNotOpen    = errors.New("backfills are not accepting work yet")
+)
+
+// MessageFunc is called for every message stored by a backfill.
+type MessageFunc func(ctx context.Context, msg *model.Message) error
+
+// ProgressFunc receives the checkpoint after every page.
+type ProgressFunc func(b *model.Backfill)
+
+// Runner imports a user's historical mail page by page, recording a
+// checkpoint after every page so an interrupted run resumes where it
+// stopped.
+type Runner struct {
+	store     database.BackfillStore
+	messages  database.MessageStore
+	workers   int
+	onMessage MessageFunc
+
+	mu      sync.Mutex
+	ctx     context.Context
+	running map[string]*job
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewRunner creates a new Runner fetching with up to workers goroutines.
+// onMessage may be nil.
+func NewRunner(store database.BackfillStore, messages database.MessageStore, workers int, onMessage MessageFunc) *Runner {
+	return &Runner{
+		store:     store,
+		messages:  messages,
+		workers:   workers,
+		onMessage: onMessage,
+		running:   map[string]*job{},
+	}
+}
+
+// Run imports the messages received since the given day, resuming a
+// previous run with the same start day. It returns the final checkpoint.
+func (r *Runner) Run(ctx context.Context, c *mailbox.Client, userID string, since time.Time, progress ProgressFunc) (*model.Backfill, error) {
+	ctx, done, err := r.register(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	return r.run(ctx, c, userID, since, progress)
+}
+
+// Open makes ctx, usually the one of the lifecycle manager, the parent of
+// the backfills started in the background, so they are cancelled with it.
+func (r *Runner) Open(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ctx = ctx
+}
+
+// Start runs a backfill in the background. Its progress is available from
+// the store. It fails with ErrNotOpen until Open is called.
+func (r *Runner) Start(c *mailbox.Client, userID string, since time.Time) error {
+	r.mu.Lock()
+	parent := r.ctx
+	r.mu.Unlock()
+	if parent == nil {
+		return ErrNotOpen
+	}
+
+	ctx, done, err := r.register(parent, userID)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer done()
+		// The outcome is recorded in the checkpoint.
+		_, _ = r.run(ctx, c, userID, since, nil)
+	}()
+	return nil
+}
+
+// Stop interrupts the running backfill of a user. It can be resumed later.
+func (r *Runner) Stop(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.running[userID]
+	if !ok {
+		return ErrNotRunning
+	}
+	j.cancel()
+	return nil
+}
+
+// Shutdown stops every running backfill and waits until their checkpoints
+// are saved, or until ctx is done. No backfill can start afterwards.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	for _, j := range r.running {
+		j.cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stalled returns the users whose running backfill has not processed a
+// message for longer than timeout, in no particular order.
+func (r *Runner) Stalled(timeout time.Duration) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var users []string
+	for userID, j := range r.running {
+		if time.Since(j.progress) > timeout {
+			users = append(users, userID)
+		}
+	}
+	return users
+}
+
+// Status returns the latest checkpoint of a user, or nil.
+func (r *Runner) Status(ctx context.Context, userID string) (*model.Backfill, error) {
+	return r.store.GetBackfill(ctx, userID)
+}
+
+// job is a running backfill.
+type job struct {
+	cancel   context.CancelFunc
+	progress time.Time
+}
+
+// touch records that the backfill of a user made progress.
+func (r *Runner) touch(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if j, ok := r.running[userID]; ok {
+		j.progress = time.Now()
+	}
+}
+
+func (r *Runner) register(ctx context.Context, userID string) (context.Context, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, nil, ErrClosed
+	}
+	if _, ok := r.running[userID]; ok {
+		return nil, nil, ErrRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.running[userID] = &job{cancel: cancel, progress: time.Now()}
+	r.wg.Add(1)
+	metrics.JobsRunning.WithLabelValues("backfill").Inc()
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.running, userID)
+		r.mu.Unlock()
+		cancel()
+		metrics.JobsRunning.WithLabelValues("backfill").Dec()
+		r.wg.Done()
+	}, nil
+}
+
+func (r *Runner) run(ctx context.Context, c *mailbox.Client, userID string, since time.Time, progress ProgressFunc) (*model.Backfill, error) {
+	b, err := r.store.GetBackfill(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil || !b.Since.Equal(since) || b.Status == model.BackfillDone {
+		b = &model.Backfill{UserID: userID, Since: since, StartedAt: time.Now()}
+	}
+	b.Status = model.BackfillRunning
+	b.Error = ""
+	if err := r.store.SaveBackfill(ctx, b); err != nil {
+		return nil, err
+	}
+
+	query := "after:" + since.Format("2006/01/02")
+	for {
+		var page *gmail.ListMessagesResponse
+		err := c.Call(ctx, "messages.list", func() error {
+			var err error
+			page, err = c.Service.Users.Messages.List(mailbox.User).Q(query).PageToken(b.PageToken).MaxResults(pageSize).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return r.stop(ctx, b, err)
+		}
+
+		results := mailbox.Fetch(ctx, c, userID, remaining(page.Messages, b.LastMessageID), r.workers)
+		for _, res := range results {
+			// Results are in order, so everything after the first
+			// cancelled fetch is left for the next run.
+			if ctx.Err() != nil && errors.Is(res.Err, ctx.Err()) {
+				return r.stop(ctx, b, ctx.Err())
+			}
+
+			ok, err := r.process(ctx, res)
+			if err != nil {
+				return r.stop(ctx, b, err)
+			}
+			if !ok {
+				b.Failed++
+			} else {
+				b.Processed++
+			}
+			b.LastMessageID = res.ID
+			r.touch(userID)
+		}
+
+		b.LastMessageID = ""
+		b.PageToken = page.NextPageToken
+		if page.NextPageToken == "" {
+			b.Status = model.BackfillDone
+		}
+		if err := r.store.SaveBackfill(ctx, b); err != nil {
+			return nil, err
+		}
+		r.touch(userID)
+		if progress != nil {
+			progress(b)
+		}
+
+		if b.Status == model.BackfillDone {
+			return b, nil
+		}
+	}
+}
+
+// process stores a fetched message and reports whether it succeeded. Only
+// storage errors and cancellation stop the run; messages that could not be
+// fetched or post-processed are counted as failed.
+func (r *Runner) process(ctx context.Context, res mailbox.FetchResult) (bool, error) {
+	if res.Err != nil {
+		return false, nil
+	}
+	if err := r.messages.SaveMessage(ctx, res.Message); err != nil {
+		return false, err
+	}
+	if r.onMessage != nil {
+		if err := r.onMessage(ctx, res.Message); err != nil {
+			return false, ctx.Err()
+		}
+	}
+	return true, nil
+}
+
+// stop records why a run ended early. Cancelled runs are resumable.
+func (r *Runner) stop(ctx context.Context, b *model.Backfill, err error) (*model.Backfill, error) {
+	if ctx.Err() != nil {
+		b.Status = model.BackfillStopped
+	} else {
+		b.Status = model.BackfillFailed
+		b.Error = err.Error()
+	}
+	if saveErr := r.store.SaveBackfill(ctx, b); saveErr != nil {
+		return nil, errors.Join(err, saveErr)
+	}
+	return b, err
+}
+
+// remaining skips the messages of a page up to and including lastID. If the
+// page changed and lastID is gone, the whole page is processed again.
+func remaining(msgs []*gmail.Message, lastID string) []string {
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.Id)
+	}
+	if lastID == "" {
+		return ids
+	}
+	for i, id := range ids {
+		if id == lastID {
+			return ids[i+1:]
+		}
+	}
+	return ids
+}
+
+// Summarize returns a MessageFunc summarizing each message with the user's
+// default prompt profile.
+func Summarize(s summarizer.Summarizer, summaries database.SummaryStore, preferences database.PreferenceStore) MessageFunc {
+	return func(ctx context.Context, msg *model.Message) error {
+		prefs, err := preferences.GetPreferences(ctx, msg.UserID)
+		if err != nil {
+			return err
+		}
+		if prefs == nil {
+			prefs = summarizer.DefaultPreferences(msg.UserID)
+		}
+
+		profile, err := summarizer.FindProfile(prefs, "")
+		if err != nil {
+			return err
+		}
+		instructions, err := summarizer.Render(profile, prefs, msg.Subject, msg.Sender)
+		if err != nil {
+			return err
+		}
+
+		res, err := s.Summarize(ctx, summarizer.Request{Instructions: instructions, Content: msg.Markdown})
+		if err != nil {
+			return err
+		}
+
+		_, err = summaries.SaveSummary(ctx, &model.Summary{
+			UserID:    msg.UserID,
+			MessageID: msg.ID,
+			Text:      res.Text,
+			Model:     s.Model(),
+			Profile:   profile.Name,
+		})
+		return err
+	}
+}
@@ -0,0 +1,253 @@
+package backfill
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+
+	"main/internal/mailbox"
+	"main/internal/model"
+)
+
+type memoryStore struct {
+	mu        sync.Mutex
+	backfills map[string]model.Backfill
+	messages  []string
+}
+
+func (s *memoryStore) GetBackfill(ctx context.Context, userID string) (*model.Backfill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.backfills[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func (s *memoryStore) SaveBackfill(ctx context.Context, b *model.Backfill) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backfills[b.UserID] = *b
+	return nil
+}
+
+func (s *memoryStore) SaveMessage(ctx context.Context, msg *model.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg.ID)
+	return nil
+}
+
+func (s *memoryStore) FindMessage(ctx context.Context, userID, id string) (*model.Message, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error) {
+	return nil, nil
+}
+
+// pagedGmail lists two pages of messages and serves each message in batch
+// responses.
+func pagedGmail(t *testing.T) *httptest.Server {
+	pages := map[string]*gmail.ListMessagesResponse{
+		"":       {Messages: []*gmail.Message{{Id: "m1"}, {Id: "m2"}, {Id: "m3"}}, NextPageToken: "page-2"},
+		"page-2": {Messages: []*gmail.Message{{Id: "m4"}, {Id: "m5"}}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gmail/v1/users/me/messages" {
+			assert.Equal(t, "after:2026/01/01", r.URL.Query().Get("q"))
+			_ = json.NewEncoder(w).Encode(pages[r.URL.Query().Get("pageToken")])
+			return
+		}
+
+		require.Equal(t, "/batch/gmail/v1", r.URL.Path)
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+
+		var ids, cids []string
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			require.NoError(t, err)
+			ids = append(ids, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+			cids = append(cids, "<response-"+strings.Trim(part.Header.Get("Content-Id"), "<>")+">")
+		}
+
+		out := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+		for i, id := range ids {
+			body, err := json.Marshal(&gmail.Message{Id: id, Payload: &gmail.MessagePart{
+				MimeType: "text/plain",
+				Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("body of " + id))},
+			}})
+			require.NoError(t, err)
+			pw, err := out.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {cids[i]}})
+			require.NoError(t, err)
+			fmt.Fprintf(pw, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+		}
+		require.NoError(t, out.Close())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunner_Run(t *testing.T) {
+	srv := pagedGmail(t)
+	client, err := mailbox.NewClient(context.Background(), srv.Client(), srv.URL, "user-123", mailbox.NewLimiter(0, 0), mailbox.Backoff{})
+	require.NoError(t, err)
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Walks every page", func(t *testing.T) {
+		store := &memoryStore{backfills: map[string]model.Backfill{}}
+		r := NewRunner(store, store, 2, nil)
+
+		var pages int
+		b, err := r.Run(context.Background(), client, "user-123", since, func(*model.Backfill) { pages++ })
+		require.NoError(t, err)
+
+		assert.Equal(t, model.BackfillDone, b.Status)
+		assert.Equal(t, 5, b.Processed)
+		assert.Equal(t, 2, pages)
+		assert.Equal(t, []string{"m1", "m2", "m3", "m4", "m5"}, store.messages)
+	})
+
+	t.Run("Resumes from the checkpoint", func(t *testing.T) {
+		store := &memoryStore{backfills: map[string]model.Backfill{}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		// Interrupt after the second message is stored.
+		r := NewRunner(store, store, 1, func(ctx context.Context, msg *model.Message) error {
+			if msg.ID == "m2" {
+				cancel()
+				return ctx.Err()
+			}
+			return nil
+		})
+
+		b, err := r.Run(ctx, client, "user-123", since, nil)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, model.BackfillStopped, b.Status)
+		assert.Equal(t, "m1", b.LastMessageID)
+
+		r = NewRunner(store, store, 1, nil)
+		b, err = r.Run(context.Background(), client, "user-123", since, nil)
+		require.NoError(t, err)
+		assert.Equal(t, model.BackfillDone, b.Status)
+		assert.Equal(t, 5, b.Processed)
+		// m2 was stored before the interruption and is stored again.
+		assert.Equal(t, []string{"m1", "m2", "m2", "m3", "m4", "m5"}, store.messages)
+	})
+
+	t.Run("Refuses concurrent runs for a user", func(t *testing.T) {
+		store := &memoryStore{backfills: map[string]model.Backfill{}}
+		r := NewRunner(store, store, 1, nil)
+
+		_, done, err := r.register(context.Background(), "user-123")
+		require.NoError(t, err)
+		_, err = r.Run(context.Background(), client, "user-123", since, nil)
+		assert.ErrorIs(t, err, ErrRunning)
+
+		require.NoError(t, r.Stop("user-123"))
+		done()
+		assert.ErrorIs(t, r.Stop("user-123"), ErrNotRunning)
+	})
+
+	t.Run("Reports stalled runs", func(t *testing.T) {
+		store := &memoryStore{backfills: map[string]model.Backfill{}}
+		r := NewRunner(store, store, 1, nil)
+
+		_, done, err := r.register(context.Background(), "user-123")
+		require.NoError(t, err)
+		defer done()
+
+		assert.Empty(t, r.Stalled(time.Minute))
+		r.running["user-123"].progress = time.Now().Add(-2 * time.Minute)
+		assert.Equal(t, []string{"user-123"}, r.Stalled(time.Minute))
+		r.touch("user-123")
+		assert.Empty(t, r.Stalled(time.Minute))
+	})
+}
+
+func TestRunner_Shutdown(t *testing.T) {
+	srv := pagedGmail(t)
+	client, err := mailbox.NewClient(context.Background(), srv.Client(), srv.URL, "user-123", mailbox.NewLimiter(0, 0), mailbox.Backoff{})
+	require.NoError(t, err)
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &memoryStore{backfills: map[string]model.Backfill{}}
+	started := make(chan struct{})
+	r := NewRunner(store, store, 1, func(ctx context.Context, msg *model.Message) error {
+		if msg.ID == "m1" {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, r.Start(client, "user-123", since), ErrNotOpen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Open(ctx)
+	require.NoError(t, r.Start(client, "user-123", since))
+	<-started
+
+	require.NoError(t, r.Shutdown(context.Background()))
+	b, err := r.Status(context.Background(), "user-123")
+	require.NoError(t, err)
+	assert.Equal(t, model.BackfillStopped, b.Status)
+
+	assert.ErrorIs(t, r.Start(client, "user-123", since), ErrClosed)
+}
+
+func TestRunner_Open(t *testing.T) {
+	srv := pagedGmail(t)
+	client, err := mailbox.NewClient(context.Background(), srv.Client(), srv.URL, "user-123", mailbox.NewLimiter(0, 0), mailbox.Backoff{})
+	require.NoError(t, err)
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := &memoryStore{backfills: map[string]model.Backfill{}}
+	started := make(chan struct{})
+	r := NewRunner(store, store, 1, func(ctx context.Context, msg *model.Message) error {
+		if msg.ID == "m1" {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Open(ctx)
+	require.NoError(t, r.Start(client, "user-123", since))
+	<-started
+
+	// Cancelling the parent stops the run, which is left to resume.
+	cancel()
+	require.NoError(t, r.Shutdown(context.Background()))
+	b, err := r.Status(context.Background(), "user-123")
+	require.NoError(t, err)
+	assert.Equal(t, model.BackfillStopped, b.Status)
+}
@@ -1,47 +1,438 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"main/internal/logging"
+	"main/internal/tracing"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/gorilla/sessions"
 	"github.com/joho/godotenv"
-	"github.com/markbates/goth/gothic"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+	"gopkg.in/yaml.v3"
 )
 
+// FileEnv names the environment variable pointing at the config file.
+const FileEnv = "SUMNOTES_CONFIG"
+
+// Stores are the values of Config.Store.
+const (
+	// StorePostgres keeps data and sessions in the database_url database.
+	StorePostgres = "postgres"
+	// StoreMemory keeps them in memory until the process exits, for local
+	// development without a database.
+	StoreMemory = "memory"
+)
+
+// Config is the application configuration. Every field has a key used as is
+// in the config file, upper cased in the environment (DATABASE_URL) and
+// dashed as a flag (--database-url).
 type Config struct {
-	ClientID          string
-	ClientSecret      string
-	ClientCallbackURL string
-	DatabaseURL       string
-	SessionSecret     string
-	FrontendURL       string
+	Port              int      `config:"port"`
+	LogLevel          string   `config:"log_level"`
+	TraceExporter     string   `config:"trace_exporter"`
+	ClientID          string   `config:"client_id"`
+	ClientSecret      string   `config:"client_secret"`
+	ClientCallbackURL string   `config:"client_callback_url"`
+	Store             string   `config:"store"`
+	DatabaseURL       string   `config:"database_url"`
+	SessionSecret     string   `config:"session_secret"`
+	FrontendURL       string   `config:"frontend_url"`
+	CORSOrigins       []string `config:"cors_origins"`
+	GmailScopes       []string `config:"gmail_scopes"`
+
+	// CookieSecure and CookieSameSite apply to the session and OAuth state
+	// cookies. SameSite none requires secure cookies.
+	CookieSecure   bool   `config:"cookie_secure"`
+	CookieSameSite string `config:"cookie_same_site"`
+
+	ReadTimeout       time.Duration `config:"read_timeout"`
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout"`
+	// WriteTimeout is disabled by default so summary streams are not cut.
+	WriteTimeout    time.Duration `config:"write_timeout"`
+	IdleTimeout     time.Duration `config:"idle_timeout"`
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+	// SessionCleanupInterval is how often expired sessions are deleted, 0
+	// disables it.
+	SessionCleanupInterval time.Duration `config:"session_cleanup_interval"`
+
+	SummarizerURL    string `config:"summarizer_url"`
+	SummarizerAPIKey string `config:"summarizer_api_key"`
+	SummarizerModel  string `config:"summarizer_model"`
+	EmbeddingsURL    string `config:"embeddings_url"`
+	EmbeddingsAPIKey string `config:"embeddings_api_key"`
+	EmbeddingsModel  string `config:"embeddings_model"`
+
+	SummaryChunkTokens  int `config:"summary_chunk_tokens"`
+	SummaryChunkOverlap int `config:"summary_chunk_overlap"`
+	SummaryWorkers      int `config:"summary_workers"`
+	SummaryCacheSize    int `config:"summary_cache_size"`
+	FetchWorkers        int `config:"gmail_fetch_workers"`
+}
+
+// Default returns the configuration used for anything not set elsewhere.
+func Default() *Config {
+	return &Config{
+		Port:          9999,
+		LogLevel:      "info",
+		TraceExporter: "none",
+		Store:         StorePostgres,
+		FrontendURL:   "http://localhost:3000",
+		CORSOrigins:   []string{"http://localhost:3000"},
+		GmailScopes: []string{
+			"https://www.googleapis.com/auth/gmail.modify",
+			"https://www.googleapis.com/auth/userinfo.email",
+			"https://www.googleapis.com/auth/userinfo.profile",
+		},
+		CookieSecure:      true,
+		CookieSameSite:    "none",
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+
+		SessionCleanupInterval: 5 * time.Minute,
+	}
 }
 
-func Load() (*Config, error) {
-	err := godotenv.Load()
+// Options locates the layers applied over the defaults.
+type Options struct {
+	// File is a YAML or TOML config file. When empty, $SUMNOTES_CONFIG is
+	// used if set.
+	File string
+	// Flags are values set on the command line, by key.
+	Flags map[string]string
+	// LookupEnv reads the environment. When nil, the process environment
+	// is used, after loading a .env file if there is one.
+	LookupEnv func(key string) (string, bool)
+}
+
+// Load builds the configuration from the defaults, the config file, the
+// environment and flags, each overriding the previous one, and validates it.
+func Load(opts Options) (*Config, error) {
+	lookup := opts.LookupEnv
+	if lookup == nil {
+		// Containers usually inject the environment directly, so a
+		// missing .env is fine.
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("config: .env: %w", err)
+		}
+		lookup = os.LookupEnv
+	}
+
+	cfg := Default()
+
+	file := opts.File
+	if file == "" {
+		file, _ = lookup(FileEnv)
+	}
+	if file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range cfg.fields() {
+		if v, ok := lookup(f.env()); ok {
+			if err := f.set(v); err != nil {
+				return nil, fmt.Errorf("config: %s: %w", f.env(), err)
+			}
+		}
+	}
+
+	for key, v := range opts.Flags {
+		f, ok := cfg.field(key)
+		if !ok {
+			return nil, fmt.Errorf("config: unknown flag --%s", flagName(key))
+		}
+		if err := f.set(v); err != nil {
+			return nil, fmt.Errorf("config: --%s: %w", flagName(key), err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(".env file failed to load!")
+		return fmt.Errorf("config: %w", err)
 	}
 
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-	clientCallbackURL := os.Getenv("CLIENT_CALLBACK_URL")
-	databaseURL := os.Getenv("DATABASE_URL")
-	sessionSecret := os.Getenv("SESSION_SECRET")
-	frontendURL := os.Getenv("FRONTEND_URL")
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config: %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
 
-	if clientID == "" || clientSecret == "" || clientCallbackURL == "" || databaseURL == "" || sessionSecret == "" {
-		log.Fatal("Environment variables (CLIENT_ID, CLIENT_SECRET, CLIENT_CALLBACK_URL, DATABASE_URL, SESSION_SECRET) are required")
+	var errs []error
+	for key, v := range values {
+		f, ok := c.field(key)
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key %q", key))
+			continue
+		}
+		if err := f.set(fileValue(v)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
 	}
-	gothic.Store = sessions.NewCookieStore([]byte(sessionSecret))
+	return nil
+}
 
-	return &Config{
-		ClientID:          clientID,
-		ClientSecret:      clientSecret,
-		ClientCallbackURL: clientCallbackURL,
-		DatabaseURL:       databaseURL,
-		SessionSecret:     sessionSecret,
-		FrontendURL:       frontendURL,
-	}, nil
+// fileValue turns a decoded file value into the string form used by the
+// environment and flags.
+func fileValue(v any) string {
+	if list, ok := v.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// Validate reports every invalid or missing setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for key, v := range map[string]string{
+		"client_id":           c.ClientID,
+		"client_secret":       c.ClientSecret,
+		"client_callback_url": c.ClientCallbackURL,
+		"session_secret":      c.SessionSecret,
+	} {
+		if v == "" {
+			add("%s is required (env %s)", key, strings.ToUpper(key))
+		}
+	}
+
+	switch c.Store {
+	case StorePostgres:
+		if c.DatabaseURL == "" {
+			add("database_url is required (env DATABASE_URL)")
+		}
+	case StoreMemory:
+	default:
+		add("store must be postgres or memory, got %q", c.Store)
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		add("port must be between 1 and 65535, got %d", c.Port)
+	}
+
+	for _, origin := range c.CORSOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			add("cors_origins: %q is not an origin such as https://example.com", origin)
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		add("log_level: %v", err)
+	}
+
+	if !tracing.ValidExporter(c.TraceExporter) {
+		add("trace_exporter must be none, stdout or otlp, got %q", c.TraceExporter)
+	}
+
+	if len(c.GmailScopes) == 0 {
+		add("gmail_scopes must not be empty")
+	}
+
+	sameSite, err := parseSameSite(c.CookieSameSite)
+	if err != nil {
+		add("cookie_same_site: %v", err)
+	} else if sameSite == http.SameSiteNoneMode && !c.CookieSecure {
+		add("cookie_same_site none requires cookie_secure")
+	}
+
+	for key, d := range map[string]time.Duration{
+		"read_timeout":             c.ReadTimeout,
+		"read_header_timeout":      c.ReadHeaderTimeout,
+		"write_timeout":            c.WriteTimeout,
+		"idle_timeout":             c.IdleTimeout,
+		"shutdown_timeout":         c.ShutdownTimeout,
+		"session_cleanup_interval": c.SessionCleanupInterval,
+	} {
+		if d < 0 {
+			add("%s must not be negative", key)
+		}
+	}
+
+	for key, n := range map[string]int{
+		"summary_chunk_tokens":  c.SummaryChunkTokens,
+		"summary_chunk_overlap": c.SummaryChunkOverlap,
+		"summary_workers":       c.SummaryWorkers,
+		"summary_cache_size":    c.SummaryCacheSize,
+		"gmail_fetch_workers":   c.FetchWorkers,
+	} {
+		if n < 0 {
+			add("%s must not be negative", key)
+		}
+	}
+	if c.SummaryChunkTokens > 0 && c.SummaryChunkOverlap >= c.SummaryChunkTokens {
+		add("summary_chunk_overlap must be smaller than summary_chunk_tokens")
+	}
+
+	for key, v := range map[string]string{
+		"frontend_url":        c.FrontendURL,
+		"client_callback_url": c.ClientCallbackURL,
+		"summarizer_url":      c.SummarizerURL,
+		"embeddings_url":      c.EmbeddingsURL,
+	} {
+		if u, err := url.Parse(v); v != "" && (err != nil || u.Host == "") {
+			add("%s: %q is not an absolute URL", key, v)
+		}
+	}
+
+	// Map iteration is random; keep the report stable.
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(errs...)
+}
+
+// Level returns the log level. It assumes a valid config.
+func (c *Config) Level() slog.Level {
+	level, _ := logging.ParseLevel(c.LogLevel)
+	return level
+}
+
+// SameSite returns the cookie SameSite mode. It assumes a valid config.
+func (c *Config) SameSite() http.SameSite {
+	mode, _ := parseSameSite(c.CookieSameSite)
+	return mode
+}
+
+// Addr is the address the HTTP server listens on.
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// OAuth2 is the Google OAuth client, used to refresh the users' tokens.
+func (c *Config) OAuth2() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.ClientCallbackURL,
+		Scopes:       c.GmailScopes,
+		Endpoint:     endpoints.Google,
+	}
+}
+
+func parseSameSite(v string) (http.SameSite, error) {
+	switch strings.ToLower(v) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("must be lax, strict or none, got %q", v)
+}
+
+// field is a settable Config field.
+type field struct {
+	key   string
+	value reflect.Value
+}
+
+func (f field) env() string {
+	return strings.ToUpper(f.key)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses v into the field. Lists are comma separated.
+func (f field) set(v string) error {
+	v = strings.TrimSpace(v)
+
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(v)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+func (c *Config) fields() []field {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	fields := make([]field, 0, t.NumField())
+	for i := range t.NumField() {
+		if key := t.Field(i).Tag.Get("config"); key != "" {
+			fields = append(fields, field{key, v.Field(i)})
+		}
+	}
+	return fields
+}
+
+// field finds a field by key, accepting the file, environment and flag
+// spellings.
+func (c *Config) field(key string) (field, bool) {
+	key = strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	for _, f := range c.fields() {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
 }
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// env returns a LookupEnv over vars, with the required settings present.
+func env(vars map[string]string) func(string) (string, bool) {
+	all := map[string]string{
+		"CLIENT_ID":           "id",
+		"CLIENT_SECRET":       "secret",
+		"CLIENT_CALLBACK_URL": "http://localhost:9999/api/auth/google/callback",
+		"DATABASE_URL":        "postgres://localhost/sumnotes",
+		"SESSION_SECRET":      "session",
+	}
+	for k, v := range vars {
+		all[k] = v
+	}
+	return func(key string) (string, bool) {
+		v, ok := all[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(Options{LookupEnv: env(nil)})
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9999", cfg.Addr())
+	assert.Equal(t, []string{"http://localhost:3000"}, cfg.CORSOrigins)
+	assert.Len(t, cfg.GmailScopes, 3)
+	assert.Equal(t, http.SameSiteNoneMode, cfg.SameSite())
+	assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+}
+
+func TestLoad_Layers(t *testing.T) {
+	yamlFile := writeFile(t, "sumnotes.yaml", `
+port: 8000
+cors_origins:
+  - https://app.example.com
+  - https://admin.example.com
+read_timeout: 5s
+summary_workers: 2
+frontend_url: https://file.example.com
+`)
+	tomlFile := writeFile(t, "sumnotes.toml", `
+port = 8001
+cookie_secure = false
+cookie_same_site = "lax"
+`)
+
+	t.Run("File over defaults", func(t *testing.T) {
+		cfg, err := Load(Options{File: yamlFile, LookupEnv: env(nil)})
+		require.NoError(t, err)
+
+		assert.Equal(t, 8000, cfg.Port)
+		assert.Equal(t, []string{"https://app.example.com", "https://admin.example.com"}, cfg.CORSOrigins)
+		assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+		assert.Equal(t, 2, cfg.SummaryWorkers)
+	})
+
+	t.Run("TOML file from the environment", func(t *testing.T) {
+		cfg, err := Load(Options{LookupEnv: env(map[string]string{FileEnv: tomlFile})})
+		require.NoError(t, err)
+
+		assert.Equal(t, 8001, cfg.Port)
+		assert.False(t, cfg.CookieSecure)
+		assert.Equal(t, http.SameSiteLaxMode, cfg.SameSite())
+	})
+
+	t.Run("Env over file and flags over env", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterFlags(fs)
+		require.NoError(t, fs.Parse([]string{"--config", yamlFile, "--port", "8002", "--cors-origins", "https://flag.example.com"}))
+
+		opts := options()
+		opts.LookupEnv = env(map[string]string{"PORT": "8003", "FRONTEND_URL": "https://env.example.com", "SUMMARY_WORKERS": "3"})
+		cfg, err := Load(opts)
+		require.NoError(t, err)
+
+		assert.Equal(t, 8002, cfg.Port)
+		assert.Equal(t, []string{"https://flag.example.com"}, cfg.CORSOrigins)
+		assert.Equal(t, "https://env.example.com", cfg.FrontendURL)
+		assert.Equal(t, 3, cfg.SummaryWorkers)
+		assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	})
+
+	t.Run("Boolean flags without a value", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		options := RegisterFlags(fs)
+		require.NoError(t, fs.Parse([]string{"--cookie-secure"}))
+
+		opts := options()
+		opts.LookupEnv = env(map[string]string{"COOKIE_SECURE": "false"})
+		cfg, err := Load(opts)
+		require.NoError(t, err)
+		assert.True(t, cfg.CookieSecure)
+	})
+}
+
+func TestLoad_Errors(t *testing.T) {
+	_, err := Load(Options{LookupEnv: env(map[string]string{"PORT": "http"})})
+	assert.ErrorContains(t, err, "PORT: invalid integer")
+
+	_, err = Load(Options{File: writeFile(t, "bad.yaml", "prot: 1\nread_timeout: soon\n"), LookupEnv: env(nil)})
+	assert.ErrorContains(t, err, `unknown key "prot"`)
+	assert.ErrorContains(t, err, "read_timeout: invalid duration")
+
+	_, err = Load(Options{File: writeFile(t, "config.json", "{}"), LookupEnv: env(nil)})
+	assert.ErrorContains(t, err, "unsupported format")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Port = 0
+	cfg.CookieSecure = false
+	cfg.CORSOrigins = []string{"localhost:3000"}
+	cfg.GmailScopes = nil
+	cfg.ShutdownTimeout = -time.Second
+	cfg.SummaryChunkTokens = 100
+	cfg.SummaryChunkOverlap = 100
+	cfg.LogLevel = "verbose"
+	cfg.TraceExporter = "jaeger"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+
+	// Every problem is reported, not only the first one.
+	for _, want := range []string{
+		"client_id is required",
+		"database_url is required",
+		"port must be between 1 and 65535",
+		"cookie_same_site none requires cookie_secure",
+		`cors_origins: "localhost:3000"`,
+		"gmail_scopes must not be empty",
+		"log_level: must be debug, info, warn or error",
+		"shutdown_timeout must not be negative",
+		"summary_chunk_overlap must be smaller",
+		`trace_exporter must be none, stdout or otlp, got "jaeger"`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+
+	cfg.Store = "sqlite"
+	assert.ErrorContains(t, cfg.Validate(), `store must be postgres or memory, got "sqlite"`)
+
+	// The memory store needs no database.
+	cfg.Store = StoreMemory
+	assert.NotContains(t, cfg.Validate().Error(), "database_url")
+}
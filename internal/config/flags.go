@@ -0,0 +1,53 @@
+package config
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+)
+
+// RegisterFlags adds --config and a flag per config key to fs. After fs is
+// parsed, the returned function gives the Options for the flags that were
+// set.
+func RegisterFlags(fs *flag.FlagSet) func() Options {
+	var file string
+	fs.StringVar(&file, "config", "", "YAML or TOML config file (env "+FileEnv+")")
+
+	set := map[string]string{}
+	for _, f := range Default().fields() {
+		fs.Var(&flagValue{key: f.key, set: set, isBool: f.value.Kind() == reflect.Bool}, flagName(f.key), "overrides env "+f.env())
+	}
+
+	return func() Options {
+		return Options{File: file, Flags: set}
+	}
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// flagValue records the raw value of a flag; parsing happens in Load with
+// the other layers.
+type flagValue struct {
+	key    string
+	set    map[string]string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.set == nil {
+		return ""
+	}
+	return v.set[v.key]
+}
+
+func (v *flagValue) Set(s string) error {
+	v.set[v.key] = s
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be passed as --cookie-secure.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
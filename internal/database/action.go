@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"main/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ActionStore defines the interface for post-summary action persistence.
+type ActionStore interface {
+	GetActionSettings(ctx context.Context, userID string) (*model.ActionSettings, error)
+	SaveActionSettings(ctx context.Context, settings *model.ActionSettings) error
+	CreateActionBatch(ctx context.Context, batch *model.ActionBatch) (*model.ActionBatch, error)
+	ListActionBatches(ctx context.Context, userID string, limit int) ([]model.ActionBatch, error)
+	LastActionBatch(ctx context.Context, userID string) (*model.ActionBatch, error)
+	MarkActionBatchUndone(ctx context.Context, id string, undoneAt time.Time) error
+}
+
+// DefaultActionSettings are used for users that never saved their own.
+func DefaultActionSettings(userID string) *model.ActionSettings {
+	return &model.ActionSettings{
+		UserID:             userID,
+		AddSummarizedLabel: true,
+	}
+}
+
+func (db *DB) GetActionSettings(ctx context.Context, userID string) (*model.ActionSettings, error) {
+	s := &model.ActionSettings{UserID: userID}
+
+	err := db.QueryRowContext(ctx, "SELECT add_summarized_label, apply_category_labels, mark_read, archive, updated_at FROM action_settings WHERE user_id = $1", userID).Scan(&s.AddSummarizedLabel, &s.ApplyCategoryLabels, &s.MarkRead, &s.Archive, &s.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return DefaultActionSettings(userID), nil
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (db *DB) SaveActionSettings(ctx context.Context, s *model.ActionSettings) error {
+	s.UpdatedAt = time.Now()
+
+	_, err := db.ExecContext(ctx, `INSERT INTO action_settings (user_id, add_summarized_label, apply_category_labels, mark_read, archive, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET add_summarized_label = EXCLUDED.add_summarized_label, apply_category_labels = EXCLUDED.apply_category_labels, mark_read = EXCLUDED.mark_read, archive = EXCLUDED.archive, updated_at = EXCLUDED.updated_at`,
+		s.UserID, s.AddSummarizedLabel, s.ApplyCategoryLabels, s.MarkRead, s.Archive, s.UpdatedAt)
+	return err
+}
+
+func (db *DB) CreateActionBatch(ctx context.Context, batch *model.ActionBatch) (*model.ActionBatch, error) {
+	batch.ID = uuid.New().String()
+	batch.CreatedAt = time.Now()
+
+	ops, err := json.Marshal(batch.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.ExecContext(ctx, "INSERT INTO action_log (id, user_id, operations, created_at) VALUES ($1, $2, $3, $4)",
+		batch.ID, batch.UserID, ops, batch.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (db *DB) ListActionBatches(ctx context.Context, userID string, limit int) ([]model.ActionBatch, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, user_id, operations, created_at, undone_at FROM action_log WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2", userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []model.ActionBatch{}
+	for rows.Next() {
+		b, err := scanActionBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *b)
+	}
+
+	return batches, rows.Err()
+}
+
+func (db *DB) LastActionBatch(ctx context.Context, userID string) (*model.ActionBatch, error) {
+	row := db.QueryRowContext(ctx, "SELECT id, user_id, operations, created_at, undone_at FROM action_log WHERE user_id = $1 AND undone_at IS NULL ORDER BY created_at DESC LIMIT 1", userID)
+
+	b, err := scanActionBatch(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No batch left to undo is not an error
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (db *DB) MarkActionBatchUndone(ctx context.Context, id string, undoneAt time.Time) error {
+	_, err := db.ExecContext(ctx, "UPDATE action_log SET undone_at = $1 WHERE id = $2", undoneAt, id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanActionBatch(s scanner) (*model.ActionBatch, error) {
+	b := &model.ActionBatch{}
+	var ops []byte
+	var undoneAt sql.NullTime
+
+	if err := s.Scan(&b.ID, &b.UserID, &ops, &b.CreatedAt, &undoneAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(ops, &b.Operations); err != nil {
+		return nil, err
+	}
+
+	if undoneAt.Valid {
+		b.UndoneAt = &undoneAt.Time
+	}
+
+	return b, nil
+}
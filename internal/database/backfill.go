@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/model"
+	"time"
+)
+
+// BackfillStore defines the interface for backfill checkpoints.
+type BackfillStore interface {
+	// GetBackfill returns nil when the user never started a backfill.
+	GetBackfill(ctx context.Context, userID string) (*model.Backfill, error)
+	SaveBackfill(ctx context.Context, b *model.Backfill) error
+}
+
+func (db *DB) GetBackfill(ctx context.Context, userID string) (*model.Backfill, error) {
+	b := &model.Backfill{UserID: userID}
+
+	err := db.QueryRowContext(ctx, "SELECT since, status, page_token, last_message_id, processed, failed, error, started_at, updated_at FROM backfills WHERE user_id = $1", userID).Scan(&b.Since, &b.Status, &b.PageToken, &b.LastMessageID, &b.Processed, &b.Failed, &b.Error, &b.StartedAt, &b.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No backfill is not an error
+		}
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (db *DB) SaveBackfill(ctx context.Context, b *model.Backfill) error {
+	b.UpdatedAt = time.Now()
+
+	_, err := db.ExecContext(ctx, `INSERT INTO backfills (user_id, since, status, page_token, last_message_id, processed, failed, error, started_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE SET since = EXCLUDED.since, status = EXCLUDED.status, page_token = EXCLUDED.page_token, last_message_id = EXCLUDED.last_message_id, processed = EXCLUDED.processed, failed = EXCLUDED.failed, error = EXCLUDED.error, started_at = EXCLUDED.started_at, updated_at = EXCLUDED.updated_at`,
+		b.UserID, b.Since, b.Status, b.PageToken, b.LastMessageID, b.Processed, b.Failed, b.Error, b.StartedAt, b.UpdatedAt)
+	return err
+}
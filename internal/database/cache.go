@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/model"
+	"time"
+)
+
+// SummaryCacheStore defines the interface for the persistent summary cache.
+type SummaryCacheStore interface {
+	GetCachedSummary(ctx context.Context, key string) (*model.CachedSummary, error)
+	SaveCachedSummary(ctx context.Context, entry *model.CachedSummary) error
+	DeleteCachedSummary(ctx context.Context, key string) error
+	// DeleteCachedSummaries removes every entry of a model, or all entries
+	// when model is empty, and returns how many were removed.
+	DeleteCachedSummaries(ctx context.Context, model string) (int64, error)
+}
+
+func (db *DB) GetCachedSummary(ctx context.Context, key string) (*model.CachedSummary, error) {
+	e := &model.CachedSummary{}
+
+	err := db.QueryRowContext(ctx, "SELECT key, model, text, prompt_tokens, completion_tokens, created_at FROM summary_cache WHERE key = $1", key).Scan(&e.Key, &e.Model, &e.Text, &e.PromptTokens, &e.CompletionTokens, &e.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // A cache miss is not an error
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (db *DB) SaveCachedSummary(ctx context.Context, e *model.CachedSummary) error {
+	e.CreatedAt = time.Now()
+
+	_, err := db.ExecContext(ctx, `INSERT INTO summary_cache (key, model, text, prompt_tokens, completion_tokens, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET model = EXCLUDED.model, text = EXCLUDED.text, prompt_tokens = EXCLUDED.prompt_tokens, completion_tokens = EXCLUDED.completion_tokens, created_at = EXCLUDED.created_at`,
+		e.Key, e.Model, e.Text, e.PromptTokens, e.CompletionTokens, e.CreatedAt)
+	return err
+}
+
+func (db *DB) DeleteCachedSummary(ctx context.Context, key string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM summary_cache WHERE key = $1", key)
+	return err
+}
+
+func (db *DB) DeleteCachedSummaries(ctx context.Context, model string) (int64, error) {
+	var res sql.Result
+	var err error
+	if model == "" {
+		res, err = db.ExecContext(ctx, "DELETE FROM summary_cache")
+	} else {
+		res, err = db.ExecContext(ctx, "DELETE FROM summary_cache WHERE model = $1", model)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/embedding"
+	"main/internal/model"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// EmbeddingStore defines the interface for message embedding persistence
+// and similarity search.
+type EmbeddingStore interface {
+	SaveChunks(ctx context.Context, userID, messageID, embeddingModel string, chunks []model.Chunk) error
+	SimilarMessages(ctx context.Context, userID, embeddingModel string, vector []float32, limit int) ([]model.SemanticResult, error)
+}
+
+// candidatesPerResult over-fetches chunks since several may belong to the
+// same message.
+const candidatesPerResult = 4
+
+// VectorDimensions is the size of the indexed pgvector column, the one of
+// text-embedding-3-small. Chunks of other sizes are searched without it.
+const VectorDimensions = 1536
+
+// vectorSupport is what the schema offers for similarity search.
+type vectorSupport struct {
+	// installed is set when the pgvector extension is.
+	installed bool
+	// indexed is set when message_chunks has the indexed vector column.
+	indexed bool
+}
+
+// SaveChunks replaces the message's chunks for the given model.
+func (db *DB) SaveChunks(ctx context.Context, userID, messageID, embeddingModel string, chunks []model.Chunk) error {
+	support, err := db.vectorSupport(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.inTx(ctx, func(tx *DB) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM message_chunks WHERE user_id = $1 AND message_id = $2 AND model = $3", userID, messageID, embeddingModel)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range chunks {
+			if support.indexed && len(c.Embedding) == VectorDimensions {
+				_, err = tx.ExecContext(ctx, "INSERT INTO message_chunks (user_id, message_id, chunk_index, model, content, embedding, embedding_vector) VALUES ($1, $2, $3, $4, $5, $6, $7::vector)",
+					userID, messageID, c.Index, embeddingModel, c.Content, pq.Float32Array(c.Embedding), vectorLiteral(c.Embedding))
+			} else {
+				_, err = tx.ExecContext(ctx, "INSERT INTO message_chunks (user_id, message_id, chunk_index, model, content, embedding) VALUES ($1, $2, $3, $4, $5, $6)",
+					userID, messageID, c.Index, embeddingModel, c.Content, pq.Float32Array(c.Embedding))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SimilarMessages returns the messages whose best matching chunk is most
+// similar to vector. It uses the pgvector index for VectorDimensions, casts
+// the stored arrays for other sizes when pgvector is installed and
+// otherwise scores every chunk of the user in Go.
+func (db *DB) SimilarMessages(ctx context.Context, userID, embeddingModel string, vector []float32, limit int) ([]model.SemanticResult, error) {
+	support, err := db.vectorSupport(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []model.SemanticResult
+	switch {
+	case support.indexed && len(vector) == VectorDimensions:
+		candidates, err = db.similarChunksPgvector(ctx, userID, embeddingModel, vector, limit*candidatesPerResult, "c.embedding_vector")
+	case support.installed:
+		candidates, err = db.similarChunksPgvector(ctx, userID, embeddingModel, vector, limit*candidatesPerResult, "c.embedding::vector")
+	default:
+		candidates, err = db.similarChunksFallback(ctx, userID, embeddingModel, vector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return bestPerMessage(candidates, limit), nil
+}
+
+// vectorSupport probes the schema once it answered; a failed probe is
+// retried on the next call instead of disabling pgvector.
+func (db *DB) vectorSupport(ctx context.Context) (vectorSupport, error) {
+	db.vector.mu.Lock()
+	defer db.vector.mu.Unlock()
+
+	if db.vector.checked {
+		return db.vector.support, nil
+	}
+
+	var s vectorSupport
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector'),
+		EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = to_regclass('message_chunks') AND attname = 'embedding_vector' AND NOT attisdropped)`).Scan(&s.installed, &s.indexed)
+	if err != nil {
+		return vectorSupport{}, err
+	}
+	db.vector.checked, db.vector.support = true, s
+	return s, nil
+}
+
+// similarChunksPgvector orders the user's chunks by cosine distance of
+// column, either the indexed vector column or a cast of the stored array.
+func (db *DB) similarChunksPgvector(ctx context.Context, userID, embeddingModel string, vector []float32, limit int, column string) ([]model.SemanticResult, error) {
+	rows, err := db.QueryContext(ctx, `SELECT c.message_id, m.thread_id, m.subject, m.sender, m.received_at, c.content,
+			1 - (`+column+` <=> $3::vector) AS score
+		FROM message_chunks c
+		JOIN messages m ON m.user_id = c.user_id AND m.id = c.message_id
+		WHERE c.user_id = $1 AND c.model = $2 AND `+column+` IS NOT NULL
+		ORDER BY `+column+` <=> $3::vector
+		LIMIT $4`, userID, embeddingModel, vectorLiteral(vector), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SemanticResult
+	for rows.Next() {
+		var r model.SemanticResult
+		var threadID sql.NullString
+		var receivedAt sql.NullTime
+
+		if err := rows.Scan(&r.ID, &threadID, &r.Subject, &r.Sender, &receivedAt, &r.Passage, &r.Score); err != nil {
+			return nil, err
+		}
+		r.ThreadID = threadID.String
+		r.ReceivedAt = receivedAt.Time
+		out = append(out, r)
+	}
+
+	return out, rows.Err()
+}
+
+func (db *DB) similarChunksFallback(ctx context.Context, userID, embeddingModel string, vector []float32) ([]model.SemanticResult, error) {
+	rows, err := db.QueryContext(ctx, `SELECT c.message_id, m.thread_id, m.subject, m.sender, m.received_at, c.content, c.embedding
+		FROM message_chunks c
+		JOIN messages m ON m.user_id = c.user_id AND m.id = c.message_id
+		WHERE c.user_id = $1 AND c.model = $2`, userID, embeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.SemanticResult
+	for rows.Next() {
+		var r model.SemanticResult
+		var threadID sql.NullString
+		var receivedAt sql.NullTime
+		var emb pq.Float32Array
+
+		if err := rows.Scan(&r.ID, &threadID, &r.Subject, &r.Sender, &receivedAt, &r.Passage, &emb); err != nil {
+			return nil, err
+		}
+		r.ThreadID = threadID.String
+		r.ReceivedAt = receivedAt.Time
+		r.Score = embedding.Cosine(vector, emb)
+		out = append(out, r)
+	}
+
+	return out, rows.Err()
+}
+
+// bestPerMessage keeps the highest scoring chunk of each message and
+// returns the top limit messages.
+func bestPerMessage(candidates []model.SemanticResult, limit int) []model.SemanticResult {
+	best := map[string]int{}
+	results := []model.SemanticResult{}
+
+	for _, c := range candidates {
+		i, ok := best[c.ID]
+		if !ok {
+			best[c.ID] = len(results)
+			results = append(results, c)
+			continue
+		}
+		if c.Score > results[i].Score {
+			results[i] = c
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func vectorLiteral(v []float32) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
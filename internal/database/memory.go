@@ -0,0 +1,656 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"main/internal/embedding"
+	"main/internal/model"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Memory is a Store keeping everything in memory, for local development
+// and tests. It behaves like the Postgres store except for search, which
+// matches words instead of ranking with full text search.
+type Memory struct {
+	*memory
+	// inTx is set on the Memory passed to the callback of WithTx.
+	inTx bool
+}
+
+type memory struct {
+	mu sync.Mutex
+	// txMu serializes transactions.
+	txMu sync.Mutex
+	data memoryData
+}
+
+// memoryData holds copies of the stored values, which are replaced rather
+// than modified so a shallow clone is a snapshot.
+type memoryData struct {
+	users          map[string]model.User
+	actionSettings map[string]model.ActionSettings
+	actionBatches  []model.ActionBatch
+	subscriptions  map[string]model.Subscription
+	messages       map[messageKey]model.Message
+	summaries      []model.Summary
+	chunks         map[chunkKey][]model.Chunk
+	preferences    map[string]model.Preferences
+	cache          map[string]model.CachedSummary
+	backfills      map[string]model.Backfill
+}
+
+type messageKey struct{ userID, id string }
+
+type chunkKey struct{ userID, messageID, model string }
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory creates an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{memory: &memory{data: memoryData{
+		users:          map[string]model.User{},
+		actionSettings: map[string]model.ActionSettings{},
+		subscriptions:  map[string]model.Subscription{},
+		messages:       map[messageKey]model.Message{},
+		chunks:         map[chunkKey][]model.Chunk{},
+		preferences:    map[string]model.Preferences{},
+		cache:          map[string]model.CachedSummary{},
+		backfills:      map[string]model.Backfill{},
+	}}}
+}
+
+func (d *memoryData) clone() memoryData {
+	return memoryData{
+		users:          maps.Clone(d.users),
+		actionSettings: maps.Clone(d.actionSettings),
+		actionBatches:  slices.Clone(d.actionBatches),
+		subscriptions:  maps.Clone(d.subscriptions),
+		messages:       maps.Clone(d.messages),
+		summaries:      slices.Clone(d.summaries),
+		chunks:         maps.Clone(d.chunks),
+		preferences:    maps.Clone(d.preferences),
+		cache:          maps.Clone(d.cache),
+		backfills:      maps.Clone(d.backfills),
+	}
+}
+
+// WithTx restores the data as it was before fn when fn fails. Writes made
+// outside the transaction while it runs are rolled back with it, which is
+// fine for a single developer.
+func (m *Memory) WithTx(ctx context.Context, fn func(Store) error) error {
+	if m.inTx {
+		return fn(m)
+	}
+
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	snapshot := m.data.clone()
+	m.mu.Unlock()
+
+	if err := fn(&Memory{memory: m.memory, inTx: true}); err != nil {
+		m.mu.Lock()
+		m.data = snapshot
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.data.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *Memory) FindUserByID(ctx context.Context, id string) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.data.users[id]; ok {
+		return &u, nil
+	}
+	return nil, nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.userByEmail(user.Email) != nil {
+		return nil, fmt.Errorf("user %s: %w", user.Email, ErrConflict)
+	}
+
+	user.ID = uuid.New().String()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	// Like the INSERT of the Postgres store, tokens are set separately.
+	m.data.users[user.ID] = model.User{ID: user.ID, Email: user.Email, Name: user.Name, AvatarURL: user.AvatarURL, CreatedAt: user.CreatedAt, UpdatedAt: user.UpdatedAt}
+	return user, nil
+}
+
+func (m *Memory) UpsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *user
+	stored.UpdatedAt = time.Now()
+	if existing := m.userByEmail(user.Email); existing != nil {
+		stored.ID = existing.ID
+		stored.CreatedAt = existing.CreatedAt
+	} else {
+		stored.ID = uuid.New().String()
+		stored.CreatedAt = stored.UpdatedAt
+	}
+	m.data.users[stored.ID] = stored
+	return &stored, nil
+}
+
+func (m *Memory) userByEmail(email string) *model.User {
+	for _, u := range m.data.users {
+		if u.Email == email {
+			return &u
+		}
+	}
+	return nil
+}
+
+func (m *Memory) UpdateUserTokens(ctx context.Context, userID, accessToken, refreshToken string, tokenExpiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.data.users[userID]
+	if !ok {
+		return nil
+	}
+	u.AccessToken, u.RefreshToken, u.TokenExpiry, u.UpdatedAt = accessToken, refreshToken, tokenExpiry, time.Now()
+	m.data.users[userID] = u
+	return nil
+}
+
+func (m *Memory) ListUsers(ctx context.Context) ([]model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []model.User
+	for _, u := range m.data.users {
+		// ListUsers leaves the tokens out.
+		u.AccessToken, u.RefreshToken = "", ""
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+	return users, nil
+}
+
+// DeleteUser removes everything of the user, like the cascading keys of
+// the Postgres schema.
+func (m *Memory) DeleteUser(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := &m.data
+	delete(d.users, id)
+	delete(d.actionSettings, id)
+	delete(d.preferences, id)
+	delete(d.backfills, id)
+	d.actionBatches = slices.DeleteFunc(d.actionBatches, func(b model.ActionBatch) bool { return b.UserID == id })
+	d.summaries = slices.DeleteFunc(d.summaries, func(s model.Summary) bool { return s.UserID == id })
+	maps.DeleteFunc(d.subscriptions, func(_ string, s model.Subscription) bool { return s.UserID == id })
+	maps.DeleteFunc(d.messages, func(k messageKey, _ model.Message) bool { return k.userID == id })
+	maps.DeleteFunc(d.chunks, func(k chunkKey, _ []model.Chunk) bool { return k.userID == id })
+	return nil
+}
+
+func (m *Memory) GetActionSettings(ctx context.Context, userID string) (*model.ActionSettings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.data.actionSettings[userID]; ok {
+		return &s, nil
+	}
+	return DefaultActionSettings(userID), nil
+}
+
+func (m *Memory) SaveActionSettings(ctx context.Context, s *model.ActionSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.UpdatedAt = time.Now()
+	m.data.actionSettings[s.UserID] = *s
+	return nil
+}
+
+func (m *Memory) CreateActionBatch(ctx context.Context, batch *model.ActionBatch) (*model.ActionBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch.ID = uuid.New().String()
+	batch.CreatedAt = time.Now()
+	stored := *batch
+	stored.Operations = cloneOperations(batch.Operations)
+	stored.UndoneAt = nil
+	m.data.actionBatches = append(m.data.actionBatches, stored)
+	return batch, nil
+}
+
+func (m *Memory) ListActionBatches(ctx context.Context, userID string, limit int) ([]model.ActionBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batches := []model.ActionBatch{}
+	for _, b := range m.newestBatches(userID) {
+		if len(batches) == limit {
+			break
+		}
+		batches = append(batches, b)
+	}
+	return batches, nil
+}
+
+func (m *Memory) LastActionBatch(ctx context.Context, userID string) (*model.ActionBatch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, b := range m.newestBatches(userID) {
+		if b.UndoneAt == nil {
+			return &b, nil
+		}
+	}
+	return nil, nil
+}
+
+// newestBatches returns copies of the user's batches, newest first.
+func (m *Memory) newestBatches(userID string) []model.ActionBatch {
+	var batches []model.ActionBatch
+	for _, b := range m.data.actionBatches {
+		if b.UserID == userID {
+			b.Operations = cloneOperations(b.Operations)
+			batches = append(batches, b)
+		}
+	}
+	sort.SliceStable(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	return batches
+}
+
+func (m *Memory) MarkActionBatchUndone(ctx context.Context, id string, undoneAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, b := range m.data.actionBatches {
+		if b.ID == id {
+			b.UndoneAt = &undoneAt
+			m.data.actionBatches[i] = b
+		}
+	}
+	return nil
+}
+
+func cloneOperations(ops []model.LabelOperation) []model.LabelOperation {
+	if ops == nil {
+		return nil
+	}
+	out := make([]model.LabelOperation, len(ops))
+	for i, op := range ops {
+		out[i] = model.LabelOperation{
+			MessageIDs:     slices.Clone(op.MessageIDs),
+			AddLabelIDs:    slices.Clone(op.AddLabelIDs),
+			RemoveLabelIDs: slices.Clone(op.RemoveLabelIDs),
+		}
+	}
+	return out
+}
+
+func (m *Memory) SaveSubscription(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub.UpdatedAt = time.Now()
+	stored := *sub
+	stored.ID = uuid.New().String()
+	stored.UnsubscribedAt = nil
+	for _, s := range m.data.subscriptions {
+		if s.UserID == sub.UserID && s.Sender == sub.Sender {
+			stored.ID = s.ID
+			stored.UnsubscribedAt = s.UnsubscribedAt
+		}
+	}
+	m.data.subscriptions[stored.ID] = stored
+	return &stored, nil
+}
+
+func (m *Memory) ListSubscriptions(ctx context.Context, userID string) ([]model.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := []model.Subscription{}
+	for _, s := range m.data.subscriptions {
+		if s.UserID == userID {
+			subs = append(subs, s)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Sender < subs[j].Sender })
+	return subs, nil
+}
+
+func (m *Memory) FindSubscription(ctx context.Context, userID, id string) (*model.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.data.subscriptions[id]; ok && s.UserID == userID {
+		return &s, nil
+	}
+	return nil, nil
+}
+
+func (m *Memory) MarkUnsubscribed(ctx context.Context, id string, unsubscribedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.data.subscriptions[id]; ok {
+		s.UnsubscribedAt, s.UpdatedAt = &unsubscribedAt, unsubscribedAt
+		m.data.subscriptions[id] = s
+	}
+	return nil
+}
+
+func (m *Memory) SaveMessage(ctx context.Context, msg *model.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = now
+	}
+	msg.UpdatedAt = now
+	if msg.LabelIDs == nil {
+		msg.LabelIDs = []string{}
+	}
+
+	key := messageKey{msg.UserID, msg.ID}
+	stored := *msg
+	stored.LabelIDs = slices.Clone(msg.LabelIDs)
+	if existing, ok := m.data.messages[key]; ok {
+		stored.CreatedAt = existing.CreatedAt
+	}
+	m.data.messages[key] = stored
+	return nil
+}
+
+func (m *Memory) FindMessage(ctx context.Context, userID, id string) (*model.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg, ok := m.data.messages[messageKey{userID, id}]
+	if !ok {
+		return nil, nil
+	}
+	msg.LabelIDs = slices.Clone(msg.LabelIDs)
+	return &msg, nil
+}
+
+// SearchMessages returns the messages whose subject, sender, body or latest
+// summary contain every word of the query, ranked by how often they occur.
+// Search operators of the Postgres store are not supported.
+func (m *Memory) SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	words := strings.Fields(strings.ToLower(q.Query))
+	results := []model.SearchResult{}
+	for key, msg := range m.data.messages {
+		if key.userID != userID || !matchesFilters(msg, q) {
+			continue
+		}
+
+		summary := ""
+		if s := m.latestSummary(userID, msg.ID); s != nil {
+			summary = s.Text
+		}
+
+		text := strings.ToLower(msg.Subject + " " + msg.Sender + " " + msg.Markdown + " " + summary)
+		rank := 0
+		for _, w := range words {
+			n := strings.Count(text, w)
+			if n == 0 {
+				rank = 0
+				break
+			}
+			rank += n
+		}
+		if rank == 0 {
+			continue
+		}
+
+		results = append(results, model.SearchResult{
+			ID:             msg.ID,
+			ThreadID:       msg.ThreadID,
+			Subject:        msg.Subject,
+			Sender:         msg.Sender,
+			LabelIDs:       slices.Clone(msg.LabelIDs),
+			ReceivedAt:     msg.ReceivedAt,
+			Rank:           float64(rank),
+			Snippet:        headline(msg.Markdown, words),
+			SummarySnippet: headline(summary, words),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ReceivedAt.After(results[j].ReceivedAt)
+	})
+
+	if q.Offset >= len(results) {
+		return []model.SearchResult{}, nil
+	}
+	results = results[q.Offset:]
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func matchesFilters(msg model.Message, q model.SearchQuery) bool {
+	if q.Sender != "" && !strings.Contains(strings.ToLower(msg.Sender), strings.ToLower(q.Sender)) {
+		return false
+	}
+	if q.Label != "" && !slices.Contains(msg.LabelIDs, q.Label) {
+		return false
+	}
+	if !q.After.IsZero() && msg.ReceivedAt.Before(q.After) {
+		return false
+	}
+	if !q.Before.IsZero() && !msg.ReceivedAt.Before(q.Before) {
+		return false
+	}
+	return true
+}
+
+// headlineWords is how many words headline keeps around the first match.
+const headlineWords = 30
+
+// headline returns the words of text around the first query word, marked
+// like the ts_headline of the Postgres store.
+func headline(text string, words []string) string {
+	fields := strings.Fields(text)
+	first := -1
+	for i, f := range fields {
+		lower := strings.ToLower(f)
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				fields[i] = "<mark>" + f + "</mark>"
+				if first < 0 {
+					first = i
+				}
+			}
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := max(0, first-headlineWords/2)
+	end := min(len(fields), start+headlineWords)
+	return strings.Join(fields[start:end], " ")
+}
+
+func (m *Memory) SaveSummary(ctx context.Context, summary *model.Summary) (*model.Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary.ID = uuid.New().String()
+	summary.CreatedAt = time.Now()
+	m.data.summaries = append(m.data.summaries, *summary)
+	return summary, nil
+}
+
+func (m *Memory) LatestSummary(ctx context.Context, userID, messageID string) (*model.Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.latestSummary(userID, messageID), nil
+}
+
+func (m *Memory) latestSummary(userID, messageID string) *model.Summary {
+	var latest *model.Summary
+	for _, s := range m.data.summaries {
+		if s.UserID == userID && s.MessageID == messageID && (latest == nil || !s.CreatedAt.Before(latest.CreatedAt)) {
+			latest = &s
+		}
+	}
+	return latest
+}
+
+func (m *Memory) SaveChunks(ctx context.Context, userID, messageID, embeddingModel string, chunks []model.Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := make([]model.Chunk, len(chunks))
+	for i, c := range chunks {
+		c.Embedding = slices.Clone(c.Embedding)
+		stored[i] = c
+	}
+	m.data.chunks[chunkKey{userID, messageID, embeddingModel}] = stored
+	return nil
+}
+
+func (m *Memory) SimilarMessages(ctx context.Context, userID, embeddingModel string, vector []float32, limit int) ([]model.SemanticResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var candidates []model.SemanticResult
+	for key, chunks := range m.data.chunks {
+		if key.userID != userID || key.model != embeddingModel {
+			continue
+		}
+		msg, ok := m.data.messages[messageKey{userID, key.messageID}]
+		if !ok {
+			continue
+		}
+		for _, c := range chunks {
+			candidates = append(candidates, model.SemanticResult{
+				ID:         msg.ID,
+				ThreadID:   msg.ThreadID,
+				Subject:    msg.Subject,
+				Sender:     msg.Sender,
+				ReceivedAt: msg.ReceivedAt,
+				Score:      embedding.Cosine(vector, c.Embedding),
+				Passage:    c.Content,
+			})
+		}
+	}
+	return bestPerMessage(candidates, limit), nil
+}
+
+func (m *Memory) GetPreferences(ctx context.Context, userID string) (*model.Preferences, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.data.preferences[userID]
+	if !ok {
+		return nil, nil
+	}
+	p.Profiles = slices.Clone(p.Profiles)
+	return &p, nil
+}
+
+func (m *Memory) SavePreferences(ctx context.Context, p *model.Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p.UpdatedAt = time.Now()
+	stored := *p
+	stored.Profiles = slices.Clone(p.Profiles)
+	m.data.preferences[p.UserID] = stored
+	return nil
+}
+
+func (m *Memory) GetCachedSummary(ctx context.Context, key string) (*model.CachedSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.data.cache[key]; ok {
+		return &e, nil
+	}
+	return nil, nil
+}
+
+func (m *Memory) SaveCachedSummary(ctx context.Context, e *model.CachedSummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e.CreatedAt = time.Now()
+	m.data.cache[e.Key] = *e
+	return nil
+}
+
+func (m *Memory) DeleteCachedSummary(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.data.cache, key)
+	return nil
+}
+
+func (m *Memory) DeleteCachedSummaries(ctx context.Context, summaryModel string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.data.cache)
+	maps.DeleteFunc(m.data.cache, func(_ string, e model.CachedSummary) bool { return summaryModel == "" || e.Model == summaryModel })
+	return int64(before - len(m.data.cache)), nil
+}
+
+func (m *Memory) GetBackfill(ctx context.Context, userID string) (*model.Backfill, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if b, ok := m.data.backfills[userID]; ok {
+		return &b, nil
+	}
+	return nil, nil
+}
+
+func (m *Memory) SaveBackfill(ctx context.Context, b *model.Backfill) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b.UpdatedAt = time.Now()
+	m.data.backfills[b.UserID] = *b
+	return nil
+}
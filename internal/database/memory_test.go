@@ -0,0 +1,13 @@
+package database_test
+
+import (
+	"main/internal/database"
+	"main/internal/database/storetest"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return database.NewMemory()
+	})
+}
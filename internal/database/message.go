@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"main/internal/model"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MessageStore defines the interface for locally stored Gmail messages.
+type MessageStore interface {
+	SaveMessage(ctx context.Context, msg *model.Message) error
+	FindMessage(ctx context.Context, userID, id string) (*model.Message, error)
+	SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error)
+}
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \""
+
+// SaveMessage inserts the message or replaces the stored copy.
+func (db *DB) SaveMessage(ctx context.Context, msg *model.Message) error {
+	now := time.Now()
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = now
+	}
+	msg.UpdatedAt = now
+	if msg.LabelIDs == nil {
+		msg.LabelIDs = []string{}
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO messages (id, user_id, thread_id, subject, sender, snippet, markdown, label_ids, received_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_id, id) DO UPDATE SET thread_id = EXCLUDED.thread_id, subject = EXCLUDED.subject, sender = EXCLUDED.sender, snippet = EXCLUDED.snippet, markdown = EXCLUDED.markdown, label_ids = EXCLUDED.label_ids, received_at = EXCLUDED.received_at, updated_at = EXCLUDED.updated_at`,
+		msg.ID, msg.UserID, msg.ThreadID, msg.Subject, msg.Sender, msg.Snippet, msg.Markdown, pq.Array(msg.LabelIDs), msg.ReceivedAt, msg.CreatedAt, msg.UpdatedAt)
+	return err
+}
+
+func (db *DB) FindMessage(ctx context.Context, userID, id string) (*model.Message, error) {
+	msg := &model.Message{}
+	var threadID sql.NullString
+	var receivedAt sql.NullTime
+
+	err := db.QueryRowContext(ctx, "SELECT id, user_id, thread_id, subject, sender, snippet, markdown, label_ids, received_at, created_at, updated_at FROM messages WHERE user_id = $1 AND id = $2", userID, id).Scan(&msg.ID, &msg.UserID, &threadID, &msg.Subject, &msg.Sender, &msg.Snippet, &msg.Markdown, pq.Array(&msg.LabelIDs), &receivedAt, &msg.CreatedAt, &msg.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No message found is not an error
+		}
+		return nil, err
+	}
+
+	msg.ThreadID = threadID.String
+	msg.ReceivedAt = receivedAt.Time
+
+	return msg, nil
+}
+
+// SearchMessages ranks the user's messages and their latest summary against
+// a web search style query.
+func (db *DB) SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error) {
+	args := []any{userID, q.Query}
+	var filters []string
+
+	if q.Sender != "" {
+		args = append(args, "%"+escapeLike(q.Sender)+"%")
+		filters = append(filters, fmt.Sprintf("m.sender ILIKE $%d", len(args)))
+	}
+	if q.Label != "" {
+		args = append(args, q.Label)
+		filters = append(filters, fmt.Sprintf("$%d = ANY(m.label_ids)", len(args)))
+	}
+	if !q.After.IsZero() {
+		args = append(args, q.After)
+		filters = append(filters, fmt.Sprintf("m.received_at >= $%d", len(args)))
+	}
+	if !q.Before.IsZero() {
+		args = append(args, q.Before)
+		filters = append(filters, fmt.Sprintf("m.received_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(filters) > 0 {
+		where = " AND " + strings.Join(filters, " AND ")
+	}
+
+	args = append(args, q.Limit, q.Offset)
+	query := `SELECT m.id, m.thread_id, m.subject, m.sender, m.label_ids, m.received_at,
+			ts_rank(m.search || coalesce(s.search, ''::tsvector), tsq.query) AS rank,
+			ts_headline('english', m.markdown, tsq.query, '` + headlineOptions + `'),
+			coalesce(ts_headline('english', s.text, tsq.query, '` + headlineOptions + `'), '')
+		FROM messages m
+		CROSS JOIN (SELECT websearch_to_tsquery('english', $2) AS query) tsq
+		LEFT JOIN LATERAL (
+			SELECT text, search FROM summaries
+			WHERE summaries.user_id = m.user_id AND summaries.message_id = m.id
+			ORDER BY created_at DESC LIMIT 1
+		) s ON TRUE
+		WHERE m.user_id = $1 AND (m.search @@ tsq.query OR s.search @@ tsq.query)` + where + `
+		ORDER BY rank DESC, m.received_at DESC
+		LIMIT $` + fmt.Sprint(len(args)-1) + ` OFFSET $` + fmt.Sprint(len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []model.SearchResult{}
+	for rows.Next() {
+		var r model.SearchResult
+		var threadID sql.NullString
+		var receivedAt sql.NullTime
+
+		if err := rows.Scan(&r.ID, &threadID, &r.Subject, &r.Sender, pq.Array(&r.LabelIDs), &receivedAt, &r.Rank, &r.Snippet, &r.SummarySnippet); err != nil {
+			return nil, err
+		}
+		r.ThreadID = threadID.String
+		r.ReceivedAt = receivedAt.Time
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,125 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/database"
+	"main/internal/database/storetest"
+	"main/internal/migrate"
+	"main/internal/model"
+	"main/migrations"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestPostgres runs the conformance suite against the database in
+// TEST_DATABASE_URL and is skipped when it is not set. It migrates its own
+// schema so it can run next to real data.
+func TestPostgres(t *testing.T) {
+	db := testDB(t)
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		truncate(t, db)
+		return database.NewUserStore(db)
+	})
+}
+
+// TestPostgres_NullTokens checks the columns themselves: users without
+// tokens keep them NULL rather than empty.
+func TestPostgres_NullTokens(t *testing.T) {
+	db := testDB(t)
+	truncate(t, db)
+	store := database.NewUserStore(db)
+	ctx := context.Background()
+
+	created, err := store.CreateUser(ctx, &model.User{Email: "ada@example.com"})
+	require.NoError(t, err)
+	upserted, err := store.UpsertUser(ctx, &model.User{Email: "bob@example.com"})
+	require.NoError(t, err)
+	updated, err := store.UpsertUser(ctx, &model.User{Email: "carol@example.com", AccessToken: "a", RefreshToken: "r", TokenExpiry: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateUserTokens(ctx, updated.ID, "", "", time.Time{}))
+
+	for _, id := range []string{created.ID, upserted.ID, updated.ID} {
+		var access, refresh, expiry bool
+		err := db.QueryRowContext(ctx, "SELECT access_token IS NULL, refresh_token IS NULL, token_expiry IS NULL FROM users WHERE id = $1", id).Scan(&access, &refresh, &expiry)
+		require.NoError(t, err)
+		assert.True(t, access && refresh && expiry, "user %s has token columns set", id)
+	}
+}
+
+// TestPostgres_Tracing checks that the stores query under the caller's span
+// rather than starting new traces.
+func TestPostgres_Tracing(t *testing.T) {
+	db := testDB(t)
+	truncate(t, db)
+	store := database.NewUserStore(db)
+
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prevProvider)
+
+	ctx, request := otel.Tracer("test").Start(context.Background(), "GET /preferences", trace.WithSpanKind(trace.SpanKindServer))
+	_, err := store.GetPreferences(ctx, "00000000-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	_, err = store.SearchMessages(ctx, "00000000-0000-0000-0000-000000000000", model.SearchQuery{Query: "x", Limit: 10})
+	require.NoError(t, err)
+	request.End()
+
+	var queries int
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() != trace.SpanKindClient {
+			continue
+		}
+		queries++
+		assert.Equal(t, request.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		assert.Equal(t, request.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+	}
+	assert.Positive(t, queries)
+}
+
+func testDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := database.New(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	// One connection keeps the search_path.
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	// public stays on the path for an extension installed there.
+	_, err = db.ExecContext(ctx, "DROP SCHEMA IF EXISTS database_test CASCADE; CREATE SCHEMA database_test; SET search_path TO database_test, public")
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.ExecContext(ctx, "DROP SCHEMA IF EXISTS database_test CASCADE") })
+
+	m, err := migrate.New(db, migrations.FS)
+	require.NoError(t, err)
+	_, err = m.Apply(ctx)
+	require.NoError(t, err)
+	return db
+}
+
+// truncate empties every table but the migration versions.
+func truncate(t *testing.T, db *sql.DB) {
+	_, err := db.Exec(`DO $$
+	DECLARE r RECORD;
+	BEGIN
+		FOR r IN SELECT tablename FROM pg_tables WHERE schemaname = 'database_test' AND tablename <> '` + migrate.VersionTable + `' LOOP
+			EXECUTE 'TRUNCATE ' || quote_ident(r.tablename) || ' CASCADE';
+		END LOOP;
+	END $$`)
+	require.NoError(t, err)
+}
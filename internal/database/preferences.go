@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"main/internal/model"
+	"time"
+)
+
+// PreferenceStore defines the interface for per-user summary preferences.
+type PreferenceStore interface {
+	// GetPreferences returns nil when the user never saved preferences.
+	GetPreferences(ctx context.Context, userID string) (*model.Preferences, error)
+	SavePreferences(ctx context.Context, prefs *model.Preferences) error
+}
+
+func (db *DB) GetPreferences(ctx context.Context, userID string) (*model.Preferences, error) {
+	p := &model.Preferences{UserID: userID}
+	var profiles []byte
+
+	err := db.QueryRowContext(ctx, "SELECT format, length, focus_action_items, language, default_profile, profiles, updated_at FROM user_preferences WHERE user_id = $1", userID).Scan(&p.Format, &p.Length, &p.FocusActionItems, &p.Language, &p.DefaultProfile, &profiles, &p.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No preferences saved is not an error
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(profiles, &p.Profiles); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (db *DB) SavePreferences(ctx context.Context, p *model.Preferences) error {
+	p.UpdatedAt = time.Now()
+
+	profiles, err := json.Marshal(p.Profiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO user_preferences (user_id, format, length, focus_action_items, language, default_profile, profiles, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET format = EXCLUDED.format, length = EXCLUDED.length, focus_action_items = EXCLUDED.focus_action_items, language = EXCLUDED.language, default_profile = EXCLUDED.default_profile, profiles = EXCLUDED.profiles, updated_at = EXCLUDED.updated_at`,
+		p.UserID, p.Format, p.Length, p.FocusActionItems, p.Language, p.DefaultProfile, profiles, p.UpdatedAt)
+	return err
+}
@@ -0,0 +1,615 @@
+// Package storetest is the conformance suite of database.Store: every
+// implementation must behave the same for the handlers to work on it.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"main/internal/database"
+	"main/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the suite, calling newStore for an empty store in every test.
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s database.Store)
+	}{
+		{"Users", testUsers},
+		{"NullTokens", testNullTokens},
+		{"Conflicts", testConflicts},
+		{"Timestamps", testTimestamps},
+		{"Transactions", testTransactions},
+		{"DeleteUser", testDeleteUser},
+		{"Actions", testActions},
+		{"Subscriptions", testSubscriptions},
+		{"Messages", testMessages},
+		{"Search", testSearch},
+		{"Summaries", testSummaries},
+		{"Embeddings", testEmbeddings},
+		{"Preferences", testPreferences},
+		{"SummaryCache", testSummaryCache},
+		{"Backfills", testBackfills},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// sameTime compares timestamps at the precision of Postgres.
+func sameTime(t *testing.T, want, got time.Time, msgAndArgs ...any) {
+	t.Helper()
+	assert.WithinDuration(t, want, got, time.Microsecond, msgAndArgs...)
+}
+
+func createUser(t *testing.T, s database.Store, email string) *model.User {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), &model.User{Email: email, Name: "Ada"})
+	require.NoError(t, err)
+	return u
+}
+
+func testUsers(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	u, err := s.CreateUser(ctx, &model.User{Email: "ada@example.com", Name: "Ada", AvatarURL: "https://example.com/ada.png"})
+	require.NoError(t, err)
+	require.NotEmpty(t, u.ID)
+
+	found, err := s.FindUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, u.ID, found.ID)
+	assert.Equal(t, "https://example.com/ada.png", found.AvatarURL)
+	// Tokens are only set once Google issued them.
+	assert.Empty(t, found.AccessToken)
+	assert.Empty(t, found.RefreshToken)
+	assert.True(t, found.TokenExpiry.IsZero())
+	sameTime(t, u.CreatedAt, found.CreatedAt)
+
+	_, err = s.CreateUser(ctx, &model.User{Email: "ada@example.com"})
+	assert.ErrorIs(t, err, database.ErrConflict)
+
+	missing, err := s.FindUserByEmail(ctx, "bob@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+	missing, err = s.FindUserByID(ctx, "00000000-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, s.UpdateUserTokens(ctx, u.ID, "access", "refresh", expiry))
+	found, err = s.FindUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "access", found.AccessToken)
+	assert.Equal(t, "refresh", found.RefreshToken)
+	sameTime(t, expiry, found.TokenExpiry)
+
+	upserted, err := s.UpsertUser(ctx, &model.User{Email: "ada@example.com", Name: "Ada Lovelace", AccessToken: "new", RefreshToken: "newer", TokenExpiry: expiry})
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, upserted.ID, "upserting an existing email keeps the user")
+	sameTime(t, u.CreatedAt, upserted.CreatedAt)
+	found, err = s.FindUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", found.Name)
+	assert.Equal(t, "new", found.AccessToken)
+
+	bob, err := s.UpsertUser(ctx, &model.User{Email: "bob@example.com", Name: "Bob"})
+	require.NoError(t, err)
+	assert.NotEqual(t, u.ID, bob.ID)
+
+	users, err := s.ListUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Equal(t, "ada@example.com", users[0].Email, "users are listed by creation")
+	assert.Equal(t, "bob@example.com", users[1].Email)
+}
+
+func testNullTokens(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	u, err := s.UpsertUser(ctx, &model.User{Email: "ada@example.com", Name: "Ada"})
+	require.NoError(t, err)
+	found, err := s.FindUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Empty(t, found.AccessToken)
+	assert.Empty(t, found.RefreshToken)
+	assert.True(t, found.TokenExpiry.IsZero())
+
+	require.NoError(t, s.UpdateUserTokens(ctx, u.ID, "access", "refresh", time.Now().Add(time.Hour)))
+	// A revoked grant clears the tokens again.
+	require.NoError(t, s.UpdateUserTokens(ctx, u.ID, "", "", time.Time{}))
+	found, err = s.FindUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, found.AccessToken)
+	assert.Empty(t, found.RefreshToken)
+	assert.True(t, found.TokenExpiry.IsZero())
+
+	users, err := s.ListUsers(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.True(t, users[0].TokenExpiry.IsZero())
+}
+
+func testConflicts(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	u, err := s.CreateUser(ctx, &model.User{Email: "ada@example.com", Name: "Ada"})
+	require.NoError(t, err)
+
+	_, err = s.CreateUser(ctx, &model.User{Email: "ada@example.com", Name: "Impostor"})
+	require.ErrorIs(t, err, database.ErrConflict)
+	found, err := s.FindUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, found.ID)
+	assert.Equal(t, "Ada", found.Name, "a conflicting create changes nothing")
+
+	// Emails are compared as stored.
+	other, err := s.CreateUser(ctx, &model.User{Email: "Ada@example.com"})
+	require.NoError(t, err)
+	assert.NotEqual(t, u.ID, other.ID)
+
+	// A conflict inside a transaction rolls it back.
+	err = s.WithTx(ctx, func(tx database.Store) error {
+		if _, err := tx.CreateUser(ctx, &model.User{Email: "bob@example.com"}); err != nil {
+			return err
+		}
+		_, err := tx.CreateUser(ctx, &model.User{Email: "ada@example.com"})
+		return err
+	})
+	assert.ErrorIs(t, err, database.ErrConflict)
+	bob, err := s.FindUserByEmail(ctx, "bob@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, bob)
+}
+
+func testTimestamps(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	before := time.Now()
+	u := createUser(t, s, "ada@example.com")
+	assert.False(t, u.CreatedAt.Before(before.Truncate(time.Microsecond)))
+
+	// The instant survives, whatever the location and below microseconds.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	expiry := time.Date(2026, 10, 18, 21, 30, 15, 123456789, tokyo)
+	require.NoError(t, s.UpdateUserTokens(ctx, u.ID, "access", "refresh", expiry))
+
+	found, err := s.FindUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	sameTime(t, expiry, found.TokenExpiry)
+	sameTime(t, u.CreatedAt, found.CreatedAt)
+	assert.False(t, found.UpdatedAt.Before(found.CreatedAt), "updated after creation")
+
+	upserted, err := s.UpsertUser(ctx, &model.User{Email: "ada@example.com", AccessToken: "a", RefreshToken: "r", TokenExpiry: expiry})
+	require.NoError(t, err)
+	sameTime(t, u.CreatedAt, upserted.CreatedAt)
+	found, err = s.FindUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	sameTime(t, expiry, found.TokenExpiry)
+	sameTime(t, upserted.UpdatedAt, found.UpdatedAt)
+}
+
+func testTransactions(t *testing.T, s database.Store) {
+	ctx := context.Background()
+
+	err := s.WithTx(ctx, func(tx database.Store) error {
+		_, err := tx.UpsertUser(ctx, &model.User{Email: "ada@example.com"})
+		return err
+	})
+	require.NoError(t, err)
+	u, err := s.FindUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, u, "committed")
+
+	errRollback := errors.New("rollback")
+	err = s.WithTx(ctx, func(tx database.Store) error {
+		if _, err := tx.UpsertUser(ctx, &model.User{Email: "bob@example.com"}); err != nil {
+			return err
+		}
+		// Nested calls join the transaction.
+		return tx.WithTx(ctx, func(tx database.Store) error {
+			return tx.SaveBackfill(ctx, &model.Backfill{UserID: u.ID, Status: model.BackfillRunning})
+		})
+	})
+	require.NoError(t, err)
+
+	err = s.WithTx(ctx, func(tx database.Store) error {
+		if _, err := tx.UpsertUser(ctx, &model.User{Email: "carol@example.com"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	carol, err := s.FindUserByEmail(ctx, "carol@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, carol, "rolled back")
+	bob, err := s.FindUserByEmail(ctx, "bob@example.com")
+	require.NoError(t, err)
+	assert.NotNil(t, bob)
+	b, err := s.GetBackfill(ctx, u.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, b)
+}
+
+func testDeleteUser(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	other := createUser(t, s, "bob@example.com")
+
+	for _, id := range []string{u.ID, other.ID} {
+		require.NoError(t, s.SaveMessage(ctx, &model.Message{ID: "m1", UserID: id, Subject: "Invoice"}))
+		_, err := s.SaveSummary(ctx, &model.Summary{UserID: id, MessageID: "m1", Text: "Pay it"})
+		require.NoError(t, err)
+		require.NoError(t, s.SavePreferences(ctx, &model.Preferences{UserID: id, Format: "bullets"}))
+	}
+
+	require.NoError(t, s.DeleteUser(ctx, u.ID))
+
+	found, err := s.FindUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+	msg, err := s.FindMessage(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	assert.Nil(t, msg, "messages are deleted with the user")
+	summary, err := s.LatestSummary(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	assert.Nil(t, summary)
+	prefs, err := s.GetPreferences(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, prefs)
+
+	msg, err = s.FindMessage(ctx, other.ID, "m1")
+	require.NoError(t, err)
+	assert.NotNil(t, msg, "other users keep their data")
+}
+
+func testActions(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+
+	settings, err := s.GetActionSettings(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, database.DefaultActionSettings(u.ID), settings)
+
+	require.NoError(t, s.SaveActionSettings(ctx, &model.ActionSettings{UserID: u.ID, MarkRead: true}))
+	settings, err = s.GetActionSettings(ctx, u.ID)
+	require.NoError(t, err)
+	assert.True(t, settings.MarkRead)
+	assert.False(t, settings.AddSummarizedLabel)
+	assert.False(t, settings.UpdatedAt.IsZero())
+
+	batches, err := s.ListActionBatches(ctx, u.ID, 10)
+	require.NoError(t, err)
+	assert.NotNil(t, batches)
+	assert.Empty(t, batches)
+	last, err := s.LastActionBatch(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	first, err := s.CreateActionBatch(ctx, &model.ActionBatch{UserID: u.ID, Operations: []model.LabelOperation{{MessageIDs: []string{"m1"}, AddLabelIDs: []string{"SUMMARIZED"}}}})
+	require.NoError(t, err)
+	// Batches are ordered by their creation time.
+	time.Sleep(2 * time.Millisecond)
+	second, err := s.CreateActionBatch(ctx, &model.ActionBatch{UserID: u.ID, Operations: []model.LabelOperation{{MessageIDs: []string{"m2"}, RemoveLabelIDs: []string{"UNREAD"}}}})
+	require.NoError(t, err)
+
+	batches, err = s.ListActionBatches(ctx, u.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	assert.Equal(t, second.ID, batches[0].ID)
+	assert.Equal(t, []string{"UNREAD"}, batches[0].Operations[0].RemoveLabelIDs)
+	assert.Nil(t, batches[0].UndoneAt)
+
+	batches, err = s.ListActionBatches(ctx, u.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, batches, 1)
+
+	undoneAt := time.Now()
+	require.NoError(t, s.MarkActionBatchUndone(ctx, second.ID, undoneAt))
+	last, err = s.LastActionBatch(ctx, u.ID)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, first.ID, last.ID, "undone batches are skipped")
+
+	batches, err = s.ListActionBatches(ctx, u.ID, 10)
+	require.NoError(t, err)
+	require.NotNil(t, batches[0].UndoneAt)
+	sameTime(t, undoneAt, *batches[0].UndoneAt)
+}
+
+func testSubscriptions(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	seen := time.Now().Add(-time.Hour)
+
+	sub, err := s.SaveSubscription(ctx, &model.Subscription{UserID: u.ID, Sender: "news@example.com", SenderName: "News", UnsubscribeURL: "https://example.com/unsub", MessageCount: 3, ReadCount: 1, LastSeenAt: seen})
+	require.NoError(t, err)
+	require.NotEmpty(t, sub.ID)
+	assert.Nil(t, sub.UnsubscribedAt)
+	sameTime(t, seen, sub.LastSeenAt)
+
+	found, err := s.FindSubscription(ctx, u.ID, sub.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "https://example.com/unsub", found.UnsubscribeURL)
+	assert.Empty(t, found.UnsubscribeMailto)
+
+	other := createUser(t, s, "bob@example.com")
+	found, err = s.FindSubscription(ctx, other.ID, sub.ID)
+	require.NoError(t, err)
+	assert.Nil(t, found, "subscriptions are per user")
+
+	unsubscribedAt := time.Now()
+	require.NoError(t, s.MarkUnsubscribed(ctx, sub.ID, unsubscribedAt))
+
+	// Saving the same sender refreshes the counters and keeps the id and
+	// unsubscribe state.
+	again, err := s.SaveSubscription(ctx, &model.Subscription{UserID: u.ID, Sender: "news@example.com", MessageCount: 5, LastSeenAt: seen})
+	require.NoError(t, err)
+	assert.Equal(t, sub.ID, again.ID)
+	assert.Equal(t, 5, again.MessageCount)
+	require.NotNil(t, again.UnsubscribedAt)
+	sameTime(t, unsubscribedAt, *again.UnsubscribedAt)
+
+	subs, err := s.ListSubscriptions(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Len(t, subs, 1)
+	subs, err = s.ListSubscriptions(ctx, other.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, subs)
+	assert.Empty(t, subs)
+}
+
+func testMessages(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	received := time.Now().Add(-24 * time.Hour)
+
+	msg := &model.Message{ID: "m1", UserID: u.ID, ThreadID: "t1", Subject: "Invoice", Sender: "billing@example.com", Markdown: "Please pay", ReceivedAt: received}
+	require.NoError(t, s.SaveMessage(ctx, msg))
+	assert.Equal(t, []string{}, msg.LabelIDs)
+
+	found, err := s.FindMessage(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "Invoice", found.Subject)
+	assert.Equal(t, []string{}, found.LabelIDs)
+	sameTime(t, received, found.ReceivedAt)
+	created := found.CreatedAt
+
+	require.NoError(t, s.SaveMessage(ctx, &model.Message{ID: "m1", UserID: u.ID, Subject: "Invoice #2", LabelIDs: []string{"INBOX"}, ReceivedAt: received}))
+	found, err = s.FindMessage(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	assert.Equal(t, "Invoice #2", found.Subject, "saving again replaces the message")
+	assert.Equal(t, []string{"INBOX"}, found.LabelIDs)
+	sameTime(t, created, found.CreatedAt, "the creation time is kept")
+
+	missing, err := s.FindMessage(ctx, u.ID, "m2")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func testSearch(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	other := createUser(t, s, "bob@example.com")
+	now := time.Now()
+
+	for _, msg := range []*model.Message{
+		{ID: "m1", UserID: u.ID, Subject: "Invoice for March", Sender: "billing@example.com", Markdown: "Your invoice is attached.", LabelIDs: []string{"INBOX"}, ReceivedAt: now.Add(-48 * time.Hour)},
+		{ID: "m2", UserID: u.ID, Subject: "Team lunch", Sender: "friends@example.com", Markdown: "Pizza on Friday.", LabelIDs: []string{"INBOX"}, ReceivedAt: now.Add(-time.Hour)},
+		{ID: "m3", UserID: u.ID, Subject: "Receipt", Sender: "shop@example.com", Markdown: "Thanks for your order.", LabelIDs: []string{"CATEGORY_UPDATES"}, ReceivedAt: now},
+		{ID: "m1", UserID: other.ID, Subject: "Invoice", Sender: "billing@example.com", Markdown: "Another invoice.", ReceivedAt: now},
+	} {
+		require.NoError(t, s.SaveMessage(ctx, msg))
+	}
+	_, err := s.SaveSummary(ctx, &model.Summary{UserID: u.ID, MessageID: "m3", Text: "An invoice for the order."})
+	require.NoError(t, err)
+
+	ids := func(q model.SearchQuery) []string {
+		t.Helper()
+		if q.Limit == 0 {
+			q.Limit = 10
+		}
+		results, err := s.SearchMessages(ctx, u.ID, q)
+		require.NoError(t, err)
+		require.NotNil(t, results)
+		out := []string{}
+		for _, r := range results {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []string{"m1", "m3"}, ids(model.SearchQuery{Query: "invoice"}), "summaries are searched too")
+	assert.Equal(t, []string{"m2"}, ids(model.SearchQuery{Query: "pizza"}))
+	assert.Empty(t, ids(model.SearchQuery{Query: "holiday"}))
+	assert.Equal(t, []string{"m1"}, ids(model.SearchQuery{Query: "invoice", Sender: "billing"}))
+	assert.Equal(t, []string{"m3"}, ids(model.SearchQuery{Query: "invoice", Label: "CATEGORY_UPDATES"}))
+	assert.Equal(t, []string{"m3"}, ids(model.SearchQuery{Query: "invoice", After: now.Add(-time.Hour)}))
+	assert.Equal(t, []string{"m1"}, ids(model.SearchQuery{Query: "invoice", Before: now.Add(-time.Hour)}))
+	assert.Len(t, ids(model.SearchQuery{Query: "invoice", Limit: 1}), 1)
+	assert.Len(t, ids(model.SearchQuery{Query: "invoice", Limit: 10, Offset: 1}), 1)
+
+	results, err := s.SearchMessages(ctx, u.ID, model.SearchQuery{Query: "pizza", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Snippet, "<mark>")
+	assert.Equal(t, []string{"INBOX"}, results[0].LabelIDs)
+}
+
+func testSummaries(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	require.NoError(t, s.SaveMessage(ctx, &model.Message{ID: "m1", UserID: u.ID}))
+
+	latest, err := s.LatestSummary(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	_, err = s.SaveSummary(ctx, &model.Summary{UserID: u.ID, MessageID: "m1", Text: "first", Model: "offline", Profile: "default"})
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	second, err := s.SaveSummary(ctx, &model.Summary{UserID: u.ID, MessageID: "m1", Text: "second", Model: "offline", Profile: "brief"})
+	require.NoError(t, err)
+	require.NotEmpty(t, second.ID)
+
+	latest, err = s.LatestSummary(ctx, u.ID, "m1")
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, second.ID, latest.ID)
+	assert.Equal(t, "second", latest.Text)
+	assert.Equal(t, "brief", latest.Profile)
+	sameTime(t, second.CreatedAt, latest.CreatedAt)
+}
+
+func testEmbeddings(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+	for _, id := range []string{"m1", "m2"} {
+		require.NoError(t, s.SaveMessage(ctx, &model.Message{ID: id, UserID: u.ID, Subject: "Subject " + id}))
+	}
+
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m1", "test", []model.Chunk{
+		{Index: 0, Content: "about cats", Embedding: []float32{1, 0, 0}},
+		{Index: 1, Content: "about dogs", Embedding: []float32{0, 1, 0}},
+	}))
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m2", "test", []model.Chunk{
+		{Index: 0, Content: "about birds", Embedding: []float32{0, 0, 1}},
+	}))
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m2", "other", []model.Chunk{
+		{Index: 0, Content: "another model", Embedding: []float32{0, 1, 0}},
+	}))
+
+	results, err := s.SimilarMessages(ctx, u.ID, "test", []float32{0, 1, 0.1}, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2, "one result per message")
+	assert.Equal(t, "m1", results[0].ID)
+	assert.Equal(t, "about dogs", results[0].Passage, "the best chunk of the message")
+	assert.Equal(t, "Subject m1", results[0].Subject)
+	assert.Greater(t, results[0].Score, results[1].Score)
+
+	// Saving chunks again replaces them.
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m1", "test", []model.Chunk{
+		{Index: 0, Content: "about fish", Embedding: []float32{1, 0, 0}},
+	}))
+	results, err = s.SimilarMessages(ctx, u.ID, "test", []float32{0, 1, 0}, 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NotEqual(t, "about dogs", results[0].Passage)
+
+	// Vectors of the indexed size are searched the same way.
+	axis := func(i int) []float32 {
+		v := make([]float32, database.VectorDimensions)
+		v[i] = 1
+		return v
+	}
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m1", "large", []model.Chunk{{Index: 0, Content: "first axis", Embedding: axis(0)}}))
+	require.NoError(t, s.SaveChunks(ctx, u.ID, "m2", "large", []model.Chunk{{Index: 0, Content: "last axis", Embedding: axis(database.VectorDimensions - 1)}}))
+	results, err = s.SimilarMessages(ctx, u.ID, "large", axis(database.VectorDimensions-1), 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "m2", results[0].ID)
+	assert.InDelta(t, 1, results[0].Score, 1e-6)
+}
+
+func testPreferences(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+
+	prefs, err := s.GetPreferences(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, prefs)
+
+	saved := &model.Preferences{UserID: u.ID, Format: "bullets", Length: "short", FocusActionItems: true, Language: "fr", DefaultProfile: "work", Profiles: []model.PromptProfile{{Name: "work", Template: "Summarize {{.Subject}}"}}}
+	require.NoError(t, s.SavePreferences(ctx, saved))
+	assert.False(t, saved.UpdatedAt.IsZero())
+
+	prefs, err = s.GetPreferences(ctx, u.ID)
+	require.NoError(t, err)
+	require.NotNil(t, prefs)
+	assert.Equal(t, "bullets", prefs.Format)
+	assert.True(t, prefs.FocusActionItems)
+	assert.Equal(t, saved.Profiles, prefs.Profiles)
+	sameTime(t, saved.UpdatedAt, prefs.UpdatedAt)
+
+	saved.Format = "paragraph"
+	require.NoError(t, s.SavePreferences(ctx, saved))
+	prefs, err = s.GetPreferences(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "paragraph", prefs.Format)
+}
+
+func testSummaryCache(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	entry, err := s.GetCachedSummary(ctx, "k1")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	for _, e := range []*model.CachedSummary{
+		{Key: "k1", Model: "a", Text: "one", PromptTokens: 10, CompletionTokens: 2},
+		{Key: "k2", Model: "a", Text: "two"},
+		{Key: "k3", Model: "b", Text: "three"},
+	} {
+		require.NoError(t, s.SaveCachedSummary(ctx, e))
+	}
+
+	entry, err = s.GetCachedSummary(ctx, "k1")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "one", entry.Text)
+	assert.Equal(t, 10, entry.PromptTokens)
+
+	require.NoError(t, s.SaveCachedSummary(ctx, &model.CachedSummary{Key: "k1", Model: "a", Text: "uno"}))
+	entry, err = s.GetCachedSummary(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "uno", entry.Text)
+
+	require.NoError(t, s.DeleteCachedSummary(ctx, "k1"))
+	entry, err = s.GetCachedSummary(ctx, "k1")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	n, err := s.DeleteCachedSummaries(ctx, "a")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	n, err = s.DeleteCachedSummaries(ctx, "")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+}
+
+func testBackfills(t *testing.T, s database.Store) {
+	ctx := context.Background()
+	u := createUser(t, s, "ada@example.com")
+
+	b, err := s.GetBackfill(ctx, u.ID)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	started := time.Now()
+	saved := &model.Backfill{UserID: u.ID, Since: since, Status: model.BackfillRunning, PageToken: "p1", StartedAt: started}
+	require.NoError(t, s.SaveBackfill(ctx, saved))
+
+	saved.Status, saved.Processed, saved.LastMessageID = model.BackfillDone, 42, "m42"
+	require.NoError(t, s.SaveBackfill(ctx, saved))
+
+	b, err = s.GetBackfill(ctx, u.ID)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	assert.Equal(t, model.BackfillDone, b.Status)
+	assert.Equal(t, 42, b.Processed)
+	assert.Equal(t, "p1", b.PageToken)
+	sameTime(t, since, b.Since)
+	sameTime(t, started, b.StartedAt)
+	sameTime(t, saved.UpdatedAt, b.UpdatedAt)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionStore defines the interface for mailing list subscription persistence.
+type SubscriptionStore interface {
+	SaveSubscription(ctx context.Context, sub *model.Subscription) (*model.Subscription, error)
+	ListSubscriptions(ctx context.Context, userID string) ([]model.Subscription, error)
+	FindSubscription(ctx context.Context, userID, id string) (*model.Subscription, error)
+	MarkUnsubscribed(ctx context.Context, id string, unsubscribedAt time.Time) error
+}
+
+const subscriptionColumns = "id, user_id, sender, sender_name, unsubscribe_url, unsubscribe_mailto, one_click, message_count, read_count, last_seen_at, unsubscribed_at, updated_at"
+
+// SaveSubscription inserts the subscription or refreshes the counters of the
+// existing row for the same sender. The returned subscription carries the
+// stored id and unsubscribe state.
+func (db *DB) SaveSubscription(ctx context.Context, sub *model.Subscription) (*model.Subscription, error) {
+	sub.UpdatedAt = time.Now()
+
+	row := db.QueryRowContext(ctx, `INSERT INTO subscriptions (id, user_id, sender, sender_name, unsubscribe_url, unsubscribe_mailto, one_click, message_count, read_count, last_seen_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (user_id, sender) DO UPDATE SET sender_name = EXCLUDED.sender_name, unsubscribe_url = EXCLUDED.unsubscribe_url, unsubscribe_mailto = EXCLUDED.unsubscribe_mailto, one_click = EXCLUDED.one_click, message_count = EXCLUDED.message_count, read_count = EXCLUDED.read_count, last_seen_at = EXCLUDED.last_seen_at, updated_at = EXCLUDED.updated_at
+		RETURNING `+subscriptionColumns,
+		uuid.New().String(), sub.UserID, sub.Sender, sub.SenderName, sub.UnsubscribeURL, sub.UnsubscribeMailto, sub.OneClick, sub.MessageCount, sub.ReadCount, sub.LastSeenAt, sub.UpdatedAt)
+
+	return scanSubscription(row)
+}
+
+func (db *DB) ListSubscriptions(ctx context.Context, userID string) ([]model.Subscription, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []model.Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *s)
+	}
+
+	return subs, rows.Err()
+}
+
+func (db *DB) FindSubscription(ctx context.Context, userID, id string) (*model.Subscription, error) {
+	row := db.QueryRowContext(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE user_id = $1 AND id = $2", userID, id)
+
+	s, err := scanSubscription(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No subscription found is not an error
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (db *DB) MarkUnsubscribed(ctx context.Context, id string, unsubscribedAt time.Time) error {
+	_, err := db.ExecContext(ctx, "UPDATE subscriptions SET unsubscribed_at = $1, updated_at = $1 WHERE id = $2", unsubscribedAt, id)
+	return err
+}
+
+func scanSubscription(s scanner) (*model.Subscription, error) {
+	sub := &model.Subscription{}
+	var senderName, url, mailto sql.NullString
+	var lastSeen, unsubscribedAt sql.NullTime
+
+	err := s.Scan(&sub.ID, &sub.UserID, &sub.Sender, &senderName, &url, &mailto, &sub.OneClick, &sub.MessageCount, &sub.ReadCount, &lastSeen, &unsubscribedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.SenderName = senderName.String
+	sub.UnsubscribeURL = url.String
+	sub.UnsubscribeMailto = mailto.String
+	sub.LastSeenAt = lastSeen.Time
+	if unsubscribedAt.Valid {
+		sub.UnsubscribedAt = &unsubscribedAt.Time
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"main/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SummaryStore defines the interface for generated summary persistence.
+type SummaryStore interface {
+	SaveSummary(ctx context.Context, summary *model.Summary) (*model.Summary, error)
+	LatestSummary(ctx context.Context, userID, messageID string) (*model.Summary, error)
+}
+
+func (db *DB) SaveSummary(ctx context.Context, summary *model.Summary) (*model.Summary, error) {
+	summary.ID = uuid.New().String()
+	summary.CreatedAt = time.Now()
+
+	_, err := db.ExecContext(ctx, "INSERT INTO summaries (id, user_id, message_id, text, model, profile, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		summary.ID, summary.UserID, summary.MessageID, summary.Text, summary.Model, summary.Profile, summary.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func (db *DB) LatestSummary(ctx context.Context, userID, messageID string) (*model.Summary, error) {
+	s := &model.Summary{}
+
+	err := db.QueryRowContext(ctx, "SELECT id, user_id, message_id, text, model, profile, created_at FROM summaries WHERE user_id = $1 AND message_id = $2 ORDER BY created_at DESC LIMIT 1", userID, messageID).Scan(&s.ID, &s.UserID, &s.MessageID, &s.Text, &s.Model, &s.Profile, &s.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // No summary found is not an error
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
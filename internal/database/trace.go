@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "main/internal/database"
+
+// The methods below shadow those of the embedded *sql.DB so every query of
+// the stores gets a span and runs in the DB's transaction, if any. Queries
+// only hold placeholders, so their text is safe to record. Stores must pass
+// their ctx: the plain Exec, Query and QueryRow of *sql.DB are neither traced
+// nor part of the transaction.
+
+// conn is what *sql.DB and *sql.Tx have in common.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (db *DB) conn() conn {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.DB
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	res, err := db.conn().ExecContext(ctx, query, args...)
+	endQuery(span, err)
+	return res, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	rows, err := db.conn().QueryContext(ctx, query, args...)
+	endQuery(span, err)
+	return rows, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	row := db.conn().QueryRowContext(ctx, query, args...)
+	// ErrNoRows is only known on Scan and is not a failure of the query.
+	endQuery(span, row.Err())
+	return row
+}
+
+// startQuery starts a client span named after the SQL operation.
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	op := "QUERY"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		op = strings.ToUpper(fields[0])
+	}
+	return otel.Tracer(tracerName).Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(op),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+func endQuery(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "query failed")
+	}
+	span.End()
+}
@@ -1,37 +1,108 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"main/internal/model"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// ErrConflict is returned when a row with the same unique key, such as a
+// user's email, already exists.
+var ErrConflict = errors.New("already exists")
+
 // UserStorer defines the interface for user database operations.
 type UserStore interface {
-	FindUserByEmail(email string) (*model.User, error)
-	FindUserByID(id string) (*model.User, error)
-	CreateUser(user *model.User) (*model.User, error)
-	UpdateUserTokens(userID, accessToken, refreshToken string, tokenExpiry time.Time) error
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+	FindUserByID(ctx context.Context, id string) (*model.User, error)
+	CreateUser(ctx context.Context, user *model.User) (*model.User, error)
+	// UpsertUser creates the user, or updates the profile and tokens of the
+	// user with the same email, and returns the stored user.
+	UpsertUser(ctx context.Context, user *model.User) (*model.User, error)
+	UpdateUserTokens(ctx context.Context, userID, accessToken, refreshToken string, tokenExpiry time.Time) error
+	ListUsers(ctx context.Context) ([]model.User, error)
+	// DeleteUser removes a user and, through cascading keys, all their data.
+	DeleteUser(ctx context.Context, id string) error
+	// WithTx runs fn with a Store bound to a transaction, committed when fn
+	// returns nil and rolled back otherwise. Calls on a Store that is
+	// already in a transaction join it.
+	WithTx(ctx context.Context, fn func(Store) error) error
+}
+
+// Store groups every store interface backed by the database.
+type Store interface {
+	UserStore
+	ActionStore
+	SubscriptionStore
+	MessageStore
+	SummaryStore
+	EmbeddingStore
+	PreferenceStore
+	SummaryCacheStore
+	BackfillStore
 }
 
 // DB holds the database connection pool.
 type DB struct {
 	*sql.DB
+
+	// tx is set on the DB passed to the callback of WithTx; its queries
+	// run in the transaction instead of the pool.
+	tx *sql.Tx
+
+	// vector caches the pgvector support of the schema. It is
+	// shared with the DBs of transactions.
+	vector *vectorCheck
+}
+
+// vectorCheck remembers what pgvector support the schema has, once a
+// probe succeeded.
+type vectorCheck struct {
+	mu      sync.Mutex
+	checked bool
+	support vectorSupport
 }
 
 // NewUserStore creates a new DB instance.
 func NewUserStore(db *sql.DB) *DB {
-	return &DB{db}
+	return &DB{DB: db, vector: &vectorCheck{}}
+}
+
+func (db *DB) WithTx(ctx context.Context, fn func(Store) error) error {
+	return db.inTx(ctx, func(tx *DB) error { return fn(tx) })
+}
+
+// inTx runs fn with a DB bound to a transaction, or with db when it is
+// already in one.
+func (db *DB) inTx(ctx context.Context, fn func(*DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&DB{DB: db.DB, tx: tx, vector: db.vector}); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (db *DB) FindUserByEmail(email string) (*model.User, error) {
+func (db *DB) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	user := &model.User{}
 	var accessToken, refreshToken sql.NullString
 	var tokenExpiry sql.NullTime
 
-	err := db.QueryRow("SELECT id, email, name, avatar_url, access_token, refresh_token, token_expiry, created_at, updated_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Email, &user.Name, &user.AvatarURL, &accessToken, &refreshToken, &tokenExpiry, &user.CreatedAt, &user.UpdatedAt)
+	err := db.QueryRowContext(ctx, "SELECT id, email, name, avatar_url, access_token, refresh_token, token_expiry, created_at, updated_at FROM users WHERE email = $1", email).Scan(&user.ID, &user.Email, &user.Name, &user.AvatarURL, &accessToken, &refreshToken, &tokenExpiry, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found is not an error
@@ -46,12 +117,12 @@ func (db *DB) FindUserByEmail(email string) (*model.User, error) {
 	return user, nil
 }
 
-func (db *DB) FindUserByID(id string) (*model.User, error) {
+func (db *DB) FindUserByID(ctx context.Context, id string) (*model.User, error) {
 	user := &model.User{}
 	var accessToken, refreshToken sql.NullString
 	var tokenExpiry sql.NullTime
 
-	err := db.QueryRow("SELECT id, email, name, avatar_url, access_token, refresh_token, token_expiry, created_at, updated_at FROM users WHERE id = $1", id).Scan(&user.ID, &user.Email, &user.Name, &user.AvatarURL, &accessToken, &refreshToken, &tokenExpiry, &user.CreatedAt, &user.UpdatedAt)
+	err := db.QueryRowContext(ctx, "SELECT id, email, name, avatar_url, access_token, refresh_token, token_expiry, created_at, updated_at FROM users WHERE id = $1", id).Scan(&user.ID, &user.Email, &user.Name, &user.AvatarURL, &accessToken, &refreshToken, &tokenExpiry, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // No user found is not an error
@@ -66,21 +137,83 @@ func (db *DB) FindUserByID(id string) (*model.User, error) {
 	return user, nil
 }
 
-func (db *DB) CreateUser(user *model.User) (*model.User, error) {
+func (db *DB) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
 	user.ID = uuid.New().String()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
-	_, err := db.Exec("INSERT INTO users (id, email, name, avatar_url, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)",
+	_, err := db.ExecContext(ctx, "INSERT INTO users (id, email, name, avatar_url, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)",
 		user.ID, user.Email, user.Name, user.AvatarURL, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
+		if uniqueViolation(err) {
+			return nil, fmt.Errorf("user %s: %w", user.Email, ErrConflict)
+		}
 		return nil, err
 	}
 	return user, nil
 }
 
-func (db *DB) UpdateUserTokens(userID, accessToken, refreshToken string, tokenExpiry time.Time) error {
-	_, err := db.Exec("UPDATE users SET access_token = $1, refresh_token = $2, token_expiry = $3, updated_at = $4 WHERE id = $5",
-		accessToken, refreshToken, tokenExpiry, time.Now(), userID)
+func uniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// UpsertUser relies on the unique email, so concurrent first sign ins of
+// the same user end up with one row.
+func (db *DB) UpsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	now := time.Now()
+	stored := *user
+
+	err := db.QueryRowContext(ctx, `INSERT INTO users (id, email, name, avatar_url, access_token, refresh_token, token_expiry, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name, avatar_url = EXCLUDED.avatar_url,
+			access_token = EXCLUDED.access_token, refresh_token = EXCLUDED.refresh_token, token_expiry = EXCLUDED.token_expiry,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at, updated_at`,
+		uuid.New().String(), user.Email, user.Name, user.AvatarURL, nullString(user.AccessToken), nullString(user.RefreshToken), nullTime(user.TokenExpiry), now).Scan(&stored.ID, &stored.CreatedAt, &stored.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (db *DB) UpdateUserTokens(ctx context.Context, userID, accessToken, refreshToken string, tokenExpiry time.Time) error {
+	_, err := db.ExecContext(ctx, "UPDATE users SET access_token = $1, refresh_token = $2, token_expiry = $3, updated_at = $4 WHERE id = $5",
+		nullString(accessToken), nullString(refreshToken), nullTime(tokenExpiry), time.Now(), userID)
+	return err
+}
+
+// nullString and nullTime store missing tokens as NULL, like a user that
+// never had any.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (db *DB) ListUsers(ctx context.Context) ([]model.User, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, email, name, avatar_url, token_expiry, created_at, updated_at FROM users ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var user model.User
+		var tokenExpiry sql.NullTime
+		if err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.AvatarURL, &tokenExpiry, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, err
+		}
+		user.TokenExpiry = tokenExpiry.Time
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (db *DB) DeleteUser(ctx context.Context, id string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
 	return err
 }
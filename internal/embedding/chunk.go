@@ -0,0 +1,35 @@
+package embedding
+
+import "strings"
+
+const (
+	DefaultChunkWords   = 200
+	DefaultChunkOverlap = 40
+)
+
+// Chunk splits text into windows of at most size words, each sharing
+// overlap words with the previous one, so every passage is embedded with
+// some of its surrounding context.
+func Chunk(text string, size, overlap int) []string {
+	if size <= 0 {
+		size = DefaultChunkWords
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := min(start+size, len(words))
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,63 @@
+package embedding
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashing_Embed(t *testing.T) {
+	h := NewHashing(128)
+
+	vectors, err := h.Embed(context.Background(), []string{
+		"Invoice 42 is overdue, please pay the invoice",
+		"Reminder: the invoice is overdue",
+		"Team lunch on Friday at noon",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 3)
+	assert.Len(t, vectors[0], 128)
+
+	again, err := h.Embed(context.Background(), []string{"Invoice 42 is overdue, please pay the invoice"})
+	require.NoError(t, err)
+	assert.Equal(t, vectors[0], again[0], "embeddings are deterministic")
+
+	assert.InDelta(t, 1.0, Cosine(vectors[0], vectors[0]), 1e-6)
+	assert.Greater(t, Cosine(vectors[0], vectors[1]), Cosine(vectors[0], vectors[2]))
+	assert.Equal(t, "hashing-128", h.Model())
+}
+
+func TestCosine(t *testing.T) {
+	assert.InDelta(t, 0.0, Cosine([]float32{1, 0}, []float32{0, 1}), 1e-9)
+	assert.InDelta(t, -1.0, Cosine([]float32{1, 1}, []float32{-1, -1}), 1e-9)
+	assert.Equal(t, 0.0, Cosine([]float32{1}, []float32{1, 0}))
+	assert.Equal(t, 0.0, Cosine([]float32{0, 0}, []float32{1, 0}))
+}
+
+func TestChunk(t *testing.T) {
+	assert.Nil(t, Chunk("   ", 3, 1))
+	assert.Equal(t, []string{"a b c"}, Chunk("a b c", 3, 1))
+	assert.Equal(t, []string{"a b c", "c d e", "e f"}, Chunk("a b\nc d e f", 3, 1))
+	assert.Equal(t, []string{"a b", "c d"}, Chunk("a b c d", 2, 5), "overlap larger than size is ignored")
+}
+
+func TestOpenAI_Embed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		// Results may come back out of order.
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	e := NewOpenAI(srv.URL, "secret", "test-embed", srv.Client())
+	vectors, err := e.Embed(context.Background(), []string{"first", "second"})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+	assert.Equal(t, "openai:test-embed", e.Model())
+}
@@ -0,0 +1,76 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const DefaultHashingDimensions = 256
+
+// Hashing is a deterministic local embedder using the hashing trick over
+// word unigrams and bigrams. It needs no network access and is meant for
+// tests and local development; it only captures lexical overlap.
+type Hashing struct {
+	dims int
+}
+
+// NewHashing creates a new Hashing embedder with the given number of
+// dimensions, or DefaultHashingDimensions when dims is not positive.
+func NewHashing(dims int) *Hashing {
+	if dims <= 0 {
+		dims = DefaultHashingDimensions
+	}
+	return &Hashing{dims}
+}
+
+func (h *Hashing) Model() string {
+	return fmt.Sprintf("hashing-%d", h.dims)
+}
+
+func (h *Hashing) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = h.embed(text)
+	}
+	return out, nil
+}
+
+func (h *Hashing) embed(text string) []float32 {
+	v := make([]float32, h.dims)
+	words := Words(text)
+
+	for i, w := range words {
+		h.add(v, w, 1)
+		if i > 0 {
+			h.add(v, words[i-1]+" "+w, 0.5)
+		}
+	}
+	return normalize(v)
+}
+
+func (h *Hashing) add(v []float32, feature string, weight float32) {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(feature))
+	sum := f.Sum64()
+
+	// The top bit picks the sign so collisions cancel out on average.
+	sign := float32(1)
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	v[sum%uint64(h.dims)] += sign * weight
+}
+
+// Words lowercases text and splits it into letter and digit runs.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
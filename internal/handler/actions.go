@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"main/internal/actions"
+	"main/internal/apierr"
+	"main/internal/mailbox"
+	"main/internal/middleware"
+	"main/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/gmail/v1"
+)
+
+const actionLogLimit = 50
+
+func (h *Handler) ActionSettings(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	settings, err := h.actions.GetActionSettings(c.Request.Context(), user.ID)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) UpdateActionSettings(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	var settings model.ActionSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, "invalid action settings"))
+		return
+	}
+	settings.UserID = user.ID
+
+	if err := h.actions.SaveActionSettings(c.Request.Context(), &settings); err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *Handler) ActionLog(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	batches, err := h.actions.ListActionBatches(c.Request.Context(), user.ID, actionLogLimit)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+func (h *Handler) UndoActions(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	client, err := h.client(c.Request.Context(), user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	batch, err := actions.NewRunner(h.actions).Undo(c.Request.Context(), client, user.ID)
+	if err != nil {
+		var merr *actions.ModifyError
+		switch {
+		case errors.Is(err, actions.ErrNothingToUndo):
+			middleware.Abort(c, apierr.Wrap(err, apierr.NotFound, "nothing to undo"))
+		case errors.As(err, &merr):
+			middleware.Abort(c, apierr.Gmail(err))
+		default:
+			middleware.Abort(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// applyActions runs the user's post-summary actions on the summarized messages.
+func (h *Handler) applyActions(ctx context.Context, client *mailbox.Client, user *model.User, msgs ...*gmail.Message) error {
+	if h.actions == nil {
+		return nil
+	}
+
+	settings, err := h.actions.GetActionSettings(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = actions.NewRunner(h.actions).Apply(ctx, client, user.ID, settings, msgs)
+	return err
+}
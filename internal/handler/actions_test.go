@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+
+	"main/internal/actions"
+	"main/internal/apierr"
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/mailbox"
+	"main/internal/middleware"
+	"main/internal/model"
+)
+
+// fakeGmail is a minimal Gmail API server that records every request.
+type fakeGmail struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []fakeGmailRequest
+	routes   map[string]http.HandlerFunc
+}
+
+type fakeGmailRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+func newFakeGmail(t *testing.T, routes map[string]http.HandlerFunc) *fakeGmail {
+	f := &fakeGmail{routes: routes}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/batch/gmail/v1" {
+			f.batch(t, w, r)
+			return
+		}
+		f.serve(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGmail) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeGmailRequest{r.Method, r.URL.Path, body})
+	f.mu.Unlock()
+
+	route, ok := f.routes[r.Method+" "+r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	route(w, r)
+}
+
+// batch answers each call of a batch request as if it was sent alone.
+func (f *fakeGmail) batch(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeGmailRequest{r.Method, r.URL.Path, nil})
+	f.mu.Unlock()
+
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	require.NoError(t, err)
+
+	var parts [][]byte
+	var cids []string
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		req, err := http.ReadRequest(bufio.NewReader(part))
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		f.serve(rec, req)
+		var raw bytes.Buffer
+		require.NoError(t, rec.Result().Write(&raw))
+		parts = append(parts, raw.Bytes())
+		cids = append(cids, "<response-"+strings.Trim(part.Header.Get("Content-Id"), "<>")+">")
+	}
+
+	out := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+	for i, raw := range parts {
+		pw, err := out.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {cids[i]}})
+		require.NoError(t, err)
+		_, _ = pw.Write(raw)
+	}
+	require.NoError(t, out.Close())
+}
+
+// client returns a ClientFunc pointing at the fake server.
+func (f *fakeGmail) client() mailbox.ClientFunc {
+	return func(ctx context.Context, u *model.User) (*mailbox.Client, error) {
+		return mailbox.NewClient(ctx, f.Client(), f.URL, u.ID, nil, mailbox.Backoff{})
+	}
+}
+
+func (f *fakeGmail) requestsFor(method, path string) []fakeGmailRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out []fakeGmailRequest
+	for _, r := range f.requests {
+		if r.Method == method && r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func writeJSON(v any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+}
+
+// failingActions fails reading the action log with err; the rest of the
+// store is the in-memory one.
+type failingActions struct {
+	*database.Memory
+	err error
+}
+
+func (f *failingActions) LastActionBatch(ctx context.Context, userID string) (*model.ActionBatch, error) {
+	return nil, f.err
+}
+
+func setupActionsTest(fg *fakeGmail) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory) {
+	return setupFailingActionsTest(fg, nil)
+}
+
+// setupFailingActionsTest is setupActionsTest with the action log failing
+// with storeErr, when set.
+func setupFailingActionsTest(fg *fakeGmail, storeErr error) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	var store database.ActionStore = db
+	if storeErr != nil {
+		store = &failingActions{Memory: db, err: storeErr}
+	}
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithActionStore(store), WithGmailClient(fg.client()))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/actions/settings", h.ActionSettings)
+	router.PUT("/actions/settings", h.UpdateActionSettings)
+	router.POST("/actions/undo", h.UndoActions)
+
+	return w, router, db
+}
+
+func TestActionRunner_Apply(t *testing.T) {
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"GET /gmail/v1/users/me/labels": writeJSON(gmail.ListLabelsResponse{
+			Labels: []*gmail.Label{{Id: "Label_promos", Name: "sumnotes/promotions"}},
+		}),
+		"POST /gmail/v1/users/me/labels":               writeJSON(gmail.Label{Id: "Label_done", Name: actions.SummarizedLabel}),
+		"POST /gmail/v1/users/me/messages/batchModify": func(w http.ResponseWriter, r *http.Request) {},
+	})
+	client, err := fg.client()(context.Background(), &model.User{ID: "user-123"})
+	require.NoError(t, err)
+
+	db := database.NewMemory()
+
+	settings := &model.ActionSettings{AddSummarizedLabel: true, ApplyCategoryLabels: true, MarkRead: true, Archive: true}
+	msgs := []*gmail.Message{
+		{Id: "a", LabelIds: []string{"INBOX", "UNREAD", "CATEGORY_PROMOTIONS"}},
+		{Id: "b", LabelIds: []string{"INBOX", "CATEGORY_PROMOTIONS"}},
+		{Id: "c", LabelIds: []string{"Label_done", "Label_promos"}},
+	}
+
+	batch, err := actions.NewRunner(db).Apply(context.Background(), client, "user-123", settings, msgs)
+	require.NoError(t, err)
+	require.NotNil(t, batch)
+	recorded, err := db.LastActionBatch(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.NotNil(t, recorded)
+	assert.Equal(t, batch.ID, recorded.ID)
+
+	assert.Equal(t, "user-123", recorded.UserID)
+	assert.Equal(t, []model.LabelOperation{
+		{MessageIDs: []string{"a"}, AddLabelIDs: []string{"Label_done", "Label_promos"}, RemoveLabelIDs: []string{"INBOX", "UNREAD"}},
+		{MessageIDs: []string{"b"}, AddLabelIDs: []string{"Label_done", "Label_promos"}, RemoveLabelIDs: []string{"INBOX"}},
+	}, recorded.Operations)
+
+	// The missing summarized label is created exactly once.
+	assert.Len(t, fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/labels"), 1)
+	assert.Len(t, fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/batchModify"), 2)
+}
+
+func TestActionRunner_ApplyPartialFailure(t *testing.T) {
+	var calls int
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"POST /gmail/v1/users/me/messages/batchModify": func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls > 2 {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+			}
+		},
+	})
+	client, err := fg.client()(context.Background(), &model.User{ID: "user-123"})
+	require.NoError(t, err)
+
+	db := database.NewMemory()
+
+	// 1500 unread messages take two calls, the archived one a third.
+	var msgs []*gmail.Message
+	for i := range 1500 {
+		msgs = append(msgs, &gmail.Message{Id: fmt.Sprintf("m%d", i), LabelIds: []string{"UNREAD"}})
+	}
+	msgs = append(msgs, &gmail.Message{Id: "archived", LabelIds: []string{"INBOX"}})
+	settings := &model.ActionSettings{MarkRead: true, Archive: true}
+
+	_, err = actions.NewRunner(db).Apply(context.Background(), client, "user-123", settings, msgs)
+	require.Error(t, err)
+
+	modified := fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/batchModify")
+	require.Len(t, modified, 3)
+	var first gmail.BatchModifyMessagesRequest
+	require.NoError(t, json.Unmarshal(modified[0].Body, &first))
+	assert.Len(t, first.Ids, 1000)
+
+	// What reached Gmail before the failure can still be undone.
+	recorded, err := db.LastActionBatch(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.NotNil(t, recorded)
+	require.Len(t, recorded.Operations, 1)
+	assert.Len(t, recorded.Operations[0].MessageIDs, 1500)
+	assert.Equal(t, []string{"UNREAD"}, recorded.Operations[0].RemoveLabelIDs)
+}
+
+func TestActionRunner_UndoPartialFailure(t *testing.T) {
+	var calls int
+	var failing bool
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"POST /gmail/v1/users/me/messages/batchModify": func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if failing && calls == 2 {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+			}
+		},
+	})
+	client, err := fg.client()(context.Background(), &model.User{ID: "user-123"})
+	require.NoError(t, err)
+
+	// 1500 read messages take two calls, the archived one a third.
+	var read []string
+	for i := range 1500 {
+		read = append(read, fmt.Sprintf("m%d", i))
+	}
+	db := database.NewMemory()
+	original, err := db.CreateActionBatch(context.Background(), &model.ActionBatch{
+		UserID: "user-123",
+		Operations: []model.LabelOperation{
+			{MessageIDs: read, RemoveLabelIDs: []string{"UNREAD"}},
+			{MessageIDs: []string{"archived"}, RemoveLabelIDs: []string{"INBOX"}},
+		},
+	})
+	require.NoError(t, err)
+
+	failing = true
+	_, err = actions.NewRunner(db).Undo(context.Background(), client, "user-123")
+	require.Error(t, err)
+	var merr *actions.ModifyError
+	assert.ErrorAs(t, err, &merr)
+
+	// Only what is left to revert is recorded.
+	remaining, err := db.LastActionBatch(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.NotNil(t, remaining)
+	assert.NotEqual(t, original.ID, remaining.ID)
+	require.Len(t, remaining.Operations, 2)
+	assert.Equal(t, read[1000:], remaining.Operations[0].MessageIDs)
+	assert.Equal(t, []string{"UNREAD"}, remaining.Operations[0].RemoveLabelIDs)
+	assert.Equal(t, []string{"archived"}, remaining.Operations[1].MessageIDs)
+
+	// A retry reverts the rest only.
+	failing = false
+	_, err = actions.NewRunner(db).Undo(context.Background(), client, "user-123")
+	require.NoError(t, err)
+
+	modified := fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/batchModify")
+	require.Len(t, modified, 4)
+	var retried gmail.BatchModifyMessagesRequest
+	require.NoError(t, json.Unmarshal(modified[2].Body, &retried))
+	assert.Equal(t, read[1000:], retried.Ids)
+
+	last, err := db.LastActionBatch(context.Background(), "user-123")
+	require.NoError(t, err)
+	assert.Nil(t, last)
+}
+
+func TestHandler_UndoActions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Undo reverses the last batch", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"POST /gmail/v1/users/me/messages/batchModify": func(w http.ResponseWriter, r *http.Request) {},
+		})
+		w, router, db := setupActionsTest(fg)
+
+		_, err := db.CreateActionBatch(context.Background(), &model.ActionBatch{
+			UserID: "user-123",
+			Operations: []model.LabelOperation{
+				{MessageIDs: []string{"a"}, AddLabelIDs: []string{"Label_done"}, RemoveLabelIDs: []string{"INBOX"}},
+			},
+		})
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest(http.MethodPost, "/actions/undo", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		calls := fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/batchModify")
+		require.Len(t, calls, 1)
+
+		var body gmail.BatchModifyMessagesRequest
+		require.NoError(t, json.Unmarshal(calls[0].Body, &body))
+		assert.Equal(t, []string{"a"}, body.Ids)
+		assert.Equal(t, []string{"INBOX"}, body.AddLabelIds)
+		assert.Equal(t, []string{"Label_done"}, body.RemoveLabelIds)
+
+		// The batch is marked undone, so it is not undone twice.
+		last, err := db.LastActionBatch(context.Background(), "user-123")
+		require.NoError(t, err)
+		assert.Nil(t, last)
+	})
+
+	t.Run("Nothing to undo", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, _ := setupActionsTest(fg)
+
+		req, _ := http.NewRequest(http.MethodPost, "/actions/undo", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Gmail failure", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"POST /gmail/v1/users/me/messages/batchModify": func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+			},
+		})
+		w, router, db := setupActionsTest(fg)
+
+		_, err := db.CreateActionBatch(context.Background(), &model.ActionBatch{
+			UserID:     "user-123",
+			Operations: []model.LabelOperation{{MessageIDs: []string{"a"}, RemoveLabelIDs: []string{"INBOX"}}},
+		})
+		require.NoError(t, err)
+
+		req, _ := http.NewRequest(http.MethodPost, "/actions/undo", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, apierr.Upstream, errorCode(t, w))
+	})
+
+	t.Run("Store failure is not blamed on Gmail", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, _ := setupFailingActionsTest(fg, errors.New("connection refused"))
+
+		req, _ := http.NewRequest(http.MethodPost, "/actions/undo", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, apierr.Internal, errorCode(t, w))
+		assert.Empty(t, fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/batchModify"))
+	})
+}
+
+func TestHandler_UpdateActionSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fg := newFakeGmail(t, nil)
+	w, router, db := setupActionsTest(fg)
+
+	req, _ := http.NewRequest(http.MethodPut, "/actions/settings", strings.NewReader(`{"archive":true}`))
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	settings, err := db.GetActionSettings(context.Background(), "user-123")
+	require.NoError(t, err)
+	assert.True(t, settings.Archive)
+	assert.False(t, settings.MarkRead)
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"main/internal/apierr"
+	"main/internal/backfill"
+	"main/internal/middleware"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type startBackfillRequest struct {
+	// Since is a day in YYYY-MM-DD format.
+	Since string `json:"since" binding:"required"`
+}
+
+// StartBackfill imports the user's mail since the given day in the
+// background, resuming an interrupted run with the same start day.
+func (h *Handler) StartBackfill(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	var req startBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, "since is required"))
+		return
+	}
+	since, err := time.Parse(time.DateOnly, req.Since)
+	if err != nil || since.After(time.Now()) {
+		middleware.Abort(c, apierr.New(apierr.BadRequest, "since must be a past date in YYYY-MM-DD format"))
+		return
+	}
+
+	client, err := h.client(c.Request.Context(), user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	if err := h.backfill.Start(client, user.ID, since); err != nil {
+		if errors.Is(err, backfill.ErrRunning) {
+			middleware.Abort(c, apierr.Wrap(err, apierr.Conflict, err.Error()))
+		} else if errors.Is(err, backfill.ErrClosed) || errors.Is(err, backfill.ErrNotOpen) {
+			middleware.Abort(c, apierr.Wrap(err, apierr.Unavailable, err.Error()))
+		} else {
+			middleware.Abort(c, err)
+		}
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// BackfillStatus returns the progress of the user's latest backfill.
+func (h *Handler) BackfillStatus(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	b, err := h.backfill.Status(c.Request.Context(), user.ID)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+	if b == nil {
+		middleware.Abort(c, apierr.New(apierr.NotFound, "no backfill found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// StopBackfill interrupts the user's running backfill.
+func (h *Handler) StopBackfill(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	if err := h.backfill.Stop(user.ID); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.NotFound, err.Error()))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"main/internal/apierr"
+	"main/internal/middleware"
+	"main/internal/summarizer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvalidateMessageSummaryCache drops the cached summary of a stored message
+// for the requested prompt profile, so the next summary is regenerated.
+func (h *Handler) InvalidateMessageSummaryCache(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	if h.cache == nil || h.messages == nil {
+		middleware.Abort(c, apierr.New(apierr.NotFound, "summary cache is disabled"))
+		return
+	}
+
+	prefs, profile, ok := h.summaryProfile(c, user)
+	if !ok {
+		return
+	}
+
+	msg, err := h.messages.FindMessage(c.Request.Context(), user.ID, c.Param("id"))
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+	if msg == nil {
+		middleware.Abort(c, apierr.New(apierr.NotFound, "message not found"))
+		return
+	}
+
+	instructions, err := summarizer.Render(profile, prefs, msg.Subject, msg.Sender)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	if err := h.cache.Invalidate(c.Request.Context(), h.cache.Key(summarizer.Request{
+		Instructions: instructions,
+		Content:      msg.Markdown,
+	})); err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
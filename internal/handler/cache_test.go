@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+)
+
+// countingSummarizer counts backend calls.
+type countingSummarizer struct {
+	calls int
+}
+
+func (s *countingSummarizer) Model() string { return "counting" }
+
+func (s *countingSummarizer) Summarize(ctx context.Context, req summarizer.Request) (*summarizer.Response, error) {
+	s.calls++
+	return &summarizer.Response{Text: "summary"}, nil
+}
+
+func TestHandler_SummaryCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	backend := &countingSummarizer{}
+	cache := summarizer.NewCached(backend, nil, 10)
+
+	_, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithSummaryCache(cache), WithMessageStore(db))
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.DELETE("/messages/:id/summary/cache", h.InvalidateMessageSummaryCache)
+
+	msg := &model.Message{ID: "msg-1", UserID: "user-123", Subject: "Invoice", Sender: "billing@example.com", Markdown: "Please pay."}
+	require.NoError(t, db.SaveMessage(context.Background(), msg))
+
+	instructions, err := summarizer.Render(summarizer.BuiltinProfiles[0], summarizer.DefaultPreferences("user-123"), msg.Subject, msg.Sender)
+	require.NoError(t, err)
+	req := summarizer.Request{Instructions: instructions, Content: msg.Markdown}
+
+	_, err = cache.Summarize(context.Background(), req)
+	require.NoError(t, err)
+	_, err = cache.Summarize(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, backend.calls)
+
+	assert.Equal(t, 0.5, cache.Stats().HitRate)
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(http.MethodDelete, "/messages/missing/summary/cache", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r, _ = http.NewRequest(http.MethodDelete, "/messages/msg-1/summary/cache", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err = cache.Summarize(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.calls)
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"main/internal/apierr"
+	"main/internal/mailbox"
+	"main/internal/middleware"
+	"main/internal/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/googleapi"
+)
+
+// maxFetchIDs caps how many messages a single request may fetch.
+const maxFetchIDs = 500
+
+type fetchMessagesRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1"`
+}
+
+type fetchMessageError struct {
+	ID    string `json:"id"`
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+type fetchMessagesResponse struct {
+	Messages []*model.Message    `json:"messages"`
+	Errors   []fetchMessageError `json:"errors"`
+}
+
+// FetchMessages fetches and converts the requested messages concurrently
+// and stores them. Messages that fail are listed in the response errors
+// instead of failing the request.
+func (h *Handler) FetchMessages(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	var req fetchMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.IDs) > maxFetchIDs {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, fmt.Sprintf("ids must list between 1 and %d message ids", maxFetchIDs)))
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.client(ctx, user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	results := mailbox.Fetch(ctx, client, user.ID, req.IDs, h.cfg.FetchWorkers)
+	if err := ctx.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	res := fetchMessagesResponse{Messages: []*model.Message{}, Errors: []fetchMessageError{}}
+	for _, r := range results {
+		if r.Err != nil {
+			c.Error(r.Err)
+			res.Errors = append(res.Errors, fetchError(r.Err))
+			continue
+		}
+
+		if h.messages != nil {
+			if err := h.messages.SaveMessage(ctx, r.Message); err != nil {
+				middleware.Abort(c, err)
+				return
+			}
+			if err := h.indexMessage(ctx, r.Message); err != nil {
+				c.Error(err)
+			}
+		}
+		res.Messages = append(res.Messages, r.Message)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// fetchError describes a failed message without exposing upstream errors.
+func fetchError(err error) fetchMessageError {
+	out := fetchMessageError{Stage: mailbox.StageFetch, Error: "failed to fetch message"}
+
+	var ferr *mailbox.FetchError
+	if errors.As(err, &ferr) {
+		out.ID = ferr.ID
+		out.Stage = ferr.Stage
+	}
+
+	var gerr *googleapi.Error
+	switch {
+	case errors.As(err, &gerr) && gerr.Code == http.StatusNotFound:
+		out.Error = "message not found"
+	case out.Stage == mailbox.StageConvert:
+		out.Error = "failed to convert message"
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/middleware"
+	"main/internal/model"
+)
+
+func TestHandler_FetchMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+		"GET /gmail/v1/users/me/messages/msg-2": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":{"code":500,"message":"backend exploded at 10.0.0.1"}}`))
+		},
+	})
+
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	h := New(db, mockStore, &config.Config{FetchWorkers: 2}, mockProvider, mockAuthenticator,
+		WithGmailClient(fg.client()), WithMessageStore(db))
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.POST("/messages/fetch", h.FetchMessages)
+
+	req, _ := http.NewRequest(http.MethodPost, "/messages/fetch", strings.NewReader(`{"ids":["msg-2","missing","msg-1"]}`))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res fetchMessagesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Messages, 1)
+	assert.Equal(t, "Invoice", res.Messages[0].Subject)
+	assert.Equal(t, []fetchMessageError{
+		{ID: "msg-2", Stage: "fetch", Error: "failed to fetch message"},
+		{ID: "missing", Stage: "fetch", Error: "message not found"},
+	}, res.Errors)
+	assert.NotContains(t, w.Body.String(), "10.0.0.1")
+
+	// Only the fetched message is stored.
+	stored, err := db.FindMessage(context.Background(), "user-123", "msg-1")
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+	stored, err = db.FindMessage(context.Background(), "user-123", "msg-2")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func TestHandler_FetchMessagesValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithGmailClient(newFakeGmail(t, nil).client()))
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.POST("/messages/fetch", h.FetchMessages)
+
+	req, _ := http.NewRequest(http.MethodPost, "/messages/fetch", strings.NewReader(`{"ids":[]}`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"main/internal/health"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is up. It checks no dependency so a
+// database outage does not get the process restarted.
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readyz runs the readiness checks. It fails with 503 when a required
+// dependency is down and succeeds when the service is only degraded.
+func (h *Handler) Readyz(c *gin.Context) {
+	report := h.health.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/health"
+)
+
+func TestHandler_Health(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var dbErr, summarizerErr error
+	checker := health.NewChecker(0,
+		health.Check{Name: "database", Func: func(ctx context.Context) error { return dbErr }},
+		health.Check{Name: "summarizer", Optional: true, Func: func(ctx context.Context) error { return summarizerErr }},
+	)
+
+	_, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator, WithHealth(checker))
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+
+	var body string
+	ready := func() (int, health.Report) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		body = w.Body.String()
+		var report health.Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	t.Run("Liveness", func(t *testing.T) {
+		dbErr = errors.New("down")
+		defer func() { dbErr = nil }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		code, report := ready()
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		assert.Len(t, report.Checks, 2)
+	})
+
+	t.Run("Degraded is still ready", func(t *testing.T) {
+		summarizerErr = errors.New("summarizer returned status 502")
+		defer func() { summarizerErr = nil }()
+
+		code, report := ready()
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusDegraded, report.Status)
+		assert.Equal(t, health.StatusDegraded, report.Checks[1].Status)
+		assert.NotContains(t, body, "502")
+	})
+
+	t.Run("Not ready", func(t *testing.T) {
+		dbErr = errors.New("connection refused")
+		defer func() { dbErr = nil }()
+
+		code, report := ready()
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, health.StatusDown, report.Checks[0].Status)
+		// The unauthenticated probe does not leak the errors.
+		assert.NotContains(t, body, "connection refused")
+	})
+}
@@ -1,36 +1,163 @@
 package handler
 
 import (
-	"context"
-	"encoding/base64"
+	"errors"
+	"main/internal/apierr"
 	"main/internal/auth"
+	"main/internal/backfill"
 	"main/internal/config"
 	"main/internal/database"
+	"main/internal/embedding"
+	"main/internal/health"
+	"main/internal/mailbox"
+	"main/internal/middleware"
 	"main/internal/model"
+	"main/internal/summarizer"
 	"net/http"
 	"time"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
-	"golang.org/x/oauth2"
 	"google.golang.org/api/gmail/v1"
-	"google.golang.org/api/option"
 )
 
 type Handler struct {
-	db    database.UserStore
-	store sessions.Store
-	cfg   *config.Config
-	p     goth.Provider
-	auth  auth.Authenticator
+	db      database.UserStore
+	store   sessions.Store
+	cfg     *config.Config
+	p       goth.Provider
+	auth    auth.Authenticator
+	client  mailbox.ClientFunc
+	actions database.ActionStore
+
+	subscriptions database.SubscriptionStore
+	httpClient    *http.Client
+	summarizer    summarizer.Summarizer
+	replies       summarizer.Summarizer
+	cache         *summarizer.Cached
+	messages      database.MessageStore
+	embedder      embedding.Embedder
+	embeddings    database.EmbeddingStore
+	summaries     database.SummaryStore
+	preferences   database.PreferenceStore
+	backfill      *backfill.Runner
+	health        *health.Checker
 }
 
-func New(db database.UserStore, store sessions.Store, cfg *config.Config, p goth.Provider, auth auth.Authenticator) *Handler {
+// Option configures optional Handler dependencies.
+type Option func(*Handler)
 
-	return &Handler{db, store, cfg, p, auth}
+// WithGmailClient overrides how rate limited Gmail clients are created for
+// a user.
+func WithGmailClient(fn mailbox.ClientFunc) Option {
+	return func(h *Handler) {
+		h.client = fn
+	}
+}
+
+// WithActionStore enables post-summary mailbox actions.
+func WithActionStore(s database.ActionStore) Option {
+	return func(h *Handler) {
+		h.actions = s
+	}
+}
+
+// WithSubscriptionStore enables the unsubscribe assistant.
+func WithSubscriptionStore(s database.SubscriptionStore) Option {
+	return func(h *Handler) {
+		h.subscriptions = s
+	}
+}
+
+// WithHTTPClient overrides the client used for outbound requests to
+// third parties, such as one-click unsubscribe links.
+func WithHTTPClient(client *http.Client) Option {
+	return func(h *Handler) {
+		h.httpClient = client
+	}
+}
+
+// WithSummarizer sets the backend used for summaries and reply drafts.
+func WithSummarizer(s summarizer.Summarizer) Option {
+	return func(h *Handler) {
+		h.summarizer = s
+		h.replies = s
+	}
+}
+
+// WithSummaryCache sets a cached summarizer for summaries and enables the
+// cache endpoints. Reply drafts keep the backend set by WithSummarizer:
+// they must not be chunked with summary prompts nor served from the cache.
+func WithSummaryCache(c *summarizer.Cached) Option {
+	return func(h *Handler) {
+		h.summarizer = c
+		h.cache = c
+	}
+}
+
+// WithMessageStore keeps fetched messages in the local store for search.
+func WithMessageStore(s database.MessageStore) Option {
+	return func(h *Handler) {
+		h.messages = s
+	}
+}
+
+// WithEmbeddings enables semantic search over stored messages.
+func WithEmbeddings(e embedding.Embedder, s database.EmbeddingStore) Option {
+	return func(h *Handler) {
+		h.embedder = e
+		h.embeddings = s
+	}
+}
+
+// WithSummaryStore persists generated summaries.
+func WithSummaryStore(s database.SummaryStore) Option {
+	return func(h *Handler) {
+		h.summaries = s
+	}
+}
+
+// WithPreferenceStore enables per-user summary preferences and profiles.
+func WithPreferenceStore(s database.PreferenceStore) Option {
+	return func(h *Handler) {
+		h.preferences = s
+	}
+}
+
+// WithBackfill enables historical mailbox imports.
+func WithBackfill(r *backfill.Runner) Option {
+	return func(h *Handler) {
+		h.backfill = r
+	}
+}
+
+// WithHealth sets the readiness checks.
+func WithHealth(c *health.Checker) Option {
+	return func(h *Handler) {
+		h.health = c
+	}
+}
+
+func New(db database.UserStore, store sessions.Store, cfg *config.Config, p goth.Provider, auth auth.Authenticator, opts ...Option) *Handler {
+	h := &Handler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		p:          p,
+		auth:       auth,
+		client:     mailbox.NewClientFunc(cfg.OAuth2(), db, mailbox.NewLimiter(0, 0), mailbox.DefaultBackoff),
+		summarizer: summarizer.NewOffline(0),
+		replies:    summarizer.NewOffline(0),
+		health:     health.NewChecker(0),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 func (h *Handler) Home(c *gin.Context) {
@@ -57,43 +184,40 @@ func (h *Handler) CallbackHandler(c *gin.Context) {
 
 	gothUser, err := h.auth.CompleteUserAuth(c.Writer, c.Request)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, apierr.Wrap(err, apierr.Unauthenticated, "Google sign in failed"))
 		return
 	}
 
-	dbUser, err := h.db.FindUserByEmail(gothUser.Email)
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
-	}
-
-	if dbUser == nil {
-		dbUser, err = h.db.CreateUser(&model.User{
-			Email:     gothUser.Email,
-			Name:      gothUser.Name,
-			AvatarURL: gothUser.AvatarURL,
+	// A single upsert keeps concurrent first sign ins of the same email
+	// from both creating the user.
+	ctx := c.Request.Context()
+	var dbUser *model.User
+	err = h.db.WithTx(ctx, func(tx database.Store) error {
+		var err error
+		dbUser, err = tx.UpsertUser(ctx, &model.User{
+			Email:        gothUser.Email,
+			Name:         gothUser.Name,
+			AvatarURL:    gothUser.AvatarURL,
+			AccessToken:  gothUser.AccessToken,
+			RefreshToken: gothUser.RefreshToken,
+			TokenExpiry:  gothUser.ExpiresAt,
 		})
-		if err != nil {
-			c.AbortWithError(http.StatusInternalServerError, err)
-			return
-		}
-	}
-
-	err = h.db.UpdateUserTokens(dbUser.ID, gothUser.AccessToken, gothUser.RefreshToken, gothUser.ExpiresAt)
+		return err
+	})
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	session, err := auth.GetSession(h.store, c.Request)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	session.Values["user_id"] = dbUser.ID
 	if err := session.Save(c.Request, c.Writer); err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
@@ -107,86 +231,97 @@ func (h *Handler) Success(c *gin.Context) {
 func (h *Handler) Refresh(c *gin.Context) {
 	session, err := auth.GetSession(h.store, c.Request)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	userID, ok := session.Values["user_id"].(string)
 	if !ok || userID == "" {
-		c.AbortWithStatus(http.StatusNotFound)
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
 		return
 	}
 
-	user, err := h.db.FindUserByID(userID)
+	user, err := h.db.FindUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 	if user == nil {
-		c.AbortWithStatus(http.StatusNotFound)
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
 		return
 	}
 
-	err = auth.RefreshToken(user, h.db, h.p)
+	err = auth.RefreshToken(c.Request.Context(), user, h.db, h.p)
 	if err != nil {
+		if !errors.Is(err, auth.ErrRefreshFailed) {
+			middleware.Abort(c, err)
+			return
+		}
 		// When refresh fails, the user is no longer authenticated.
 		// We should clear the session and return 401 Unauthorized.
 		session.Options.MaxAge = -1
 		if err := session.Save(c.Request, c.Writer); err != nil {
 			// If we can't even save the session, something is very wrong.
-			c.AbortWithStatus(http.StatusInternalServerError)
+			middleware.Abort(c, err)
 			return
 		}
-		c.AbortWithStatus(http.StatusUnauthorized)
+		middleware.Abort(c, apierr.Wrap(err, apierr.TokenRevoked, ""))
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
 
 func (h *Handler) Me(c *gin.Context) {
 	session, err := auth.GetSession(h.store, c.Request)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	userID, ok := session.Values["user_id"].(string)
 	if !ok || userID == "" {
-		c.AbortWithStatus(http.StatusNotFound)
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
 		return
 	}
 
-	user, err := h.db.FindUserByID(userID)
+	user, err := h.db.FindUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	if user == nil {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
-	}
-
-	c.JSON(http.StatusOK, struct {
-		AvatarURL string
-		CreatedAt time.Time
-		Email     string
-		ID        string
-		Name      string
-	}{
-		AvatarURL: user.AvatarURL,
-		CreatedAt: user.CreatedAt,
-		Email:     user.Email,
-		ID:        user.ID,
-		Name:      user.Name,
-	})
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// userResponse is the user without their tokens.
+type userResponse struct {
+	AvatarURL string
+	CreatedAt time.Time
+	Email     string
+	ID        string
+	Name      string
+}
+
+func newUserResponse(u *model.User) userResponse {
+	return userResponse{
+		AvatarURL: u.AvatarURL,
+		CreatedAt: u.CreatedAt,
+		Email:     u.Email,
+		ID:        u.ID,
+		Name:      u.Name,
+	}
 }
 
 func (h *Handler) Summaries(c *gin.Context) {
 	session, err := auth.GetSession(h.store, c.Request)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
@@ -197,57 +332,66 @@ func (h *Handler) Summaries(c *gin.Context) {
 		return
 	}
 
-	user, err := h.db.FindUserByID(userID)
+	user, err := h.db.FindUserByID(c.Request.Context(), userID)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
 	if user == nil {
-		c.AbortWithStatus(http.StatusNotFound)
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
 		return
 	}
 
-	token := &oauth2.Token{
-		AccessToken:  user.AccessToken,
-		RefreshToken: user.RefreshToken,
-		Expiry:       user.TokenExpiry,
-		TokenType:    "Bearer",
-	}
-
-	ctx := context.Background()
-	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
-	gmailService, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	ctx := c.Request.Context()
+	client, err := h.client(ctx, user)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
-	mails, err := gmailService.Users.Messages.List("me").Do()
+	var mails *gmail.ListMessagesResponse
+	err = client.Call(ctx, "messages.list", func() error {
+		var err error
+		mails, err = client.Service.Users.Messages.List(mailbox.User).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, apierr.Gmail(err))
+		return
+	}
+	if len(mails.Messages) == 0 {
+		middleware.Abort(c, apierr.New(apierr.NotFound, "mailbox is empty"))
 		return
 	}
 
-	m, err := gmailService.Users.Messages.Get("me", mails.Messages[0].Id).Do()
+	var m *gmail.Message
+	err = client.Call(ctx, "messages.get", func() error {
+		var err error
+		m, err = client.Service.Users.Messages.Get(mailbox.User, mails.Messages[0].Id).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, apierr.Gmail(err))
 		return
 	}
 
-	data, err := base64.URLEncoding.DecodeString(m.Payload.Body.Data)
+	msg, err := mailbox.ToModel(user.ID, m)
 	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
+		middleware.Abort(c, err)
 		return
 	}
 
-	converter := md.NewConverter("", true, nil)
+	if h.messages != nil {
+		if err := h.messages.SaveMessage(ctx, msg); err != nil {
+			middleware.Abort(c, err)
+			return
+		}
 
-	markdown, err := converter.ConvertString(string(data))
-	if err != nil {
-		c.AbortWithError(http.StatusInternalServerError, err)
-		return
+		if err := h.indexMessage(ctx, msg); err != nil {
+			c.Error(err)
+		}
 	}
 
-	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(markdown))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(msg.Markdown))
 }
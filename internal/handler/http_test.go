@@ -3,8 +3,11 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"main/internal/apierr"
+	"main/internal/middleware"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -16,6 +19,7 @@ import (
 	"github.com/markbates/goth/gothic"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 
 	"main/internal/auth"
@@ -24,13 +28,35 @@ import (
 	"main/internal/model"
 )
 
-// MockDB is a mock implementation of the UserStorer interface.
-type MockDB struct {
-	mock.Mock
+// failingUsers fails the user lookups and upserts with err; the rest of the
+// store is the in-memory one.
+type failingUsers struct {
+	*database.Memory
+	err error
+}
+
+var _ database.Store = (*failingUsers)(nil)
+
+func (f *failingUsers) FindUserByID(ctx context.Context, id string) (*model.User, error) {
+	return nil, f.err
+}
+
+func (f *failingUsers) UpsertUser(ctx context.Context, user *model.User) (*model.User, error) {
+	return nil, f.err
+}
+
+// WithTx keeps the failures inside the transaction.
+func (f *failingUsers) WithTx(ctx context.Context, fn func(database.Store) error) error {
+	return fn(f)
 }
 
-// Ensure MockDB satisfies the UserStorer interface.
-var _ database.UserStore = (*MockDB)(nil)
+// users returns db, or db failing with err when it is set.
+func users(db *database.Memory, err error) database.UserStore {
+	if err != nil {
+		return &failingUsers{Memory: db, err: err}
+	}
+	return db
+}
 
 // MockStore is a mock implementation of the sessions.Store interface.
 type MockStore struct {
@@ -83,35 +109,6 @@ func (m *MockProvider) RefreshToken(refreshToken string) (*oauth2.Token, error)
 	return args.Get(0).(*oauth2.Token), args.Error(1)
 }
 
-func (m *MockDB) FindUserByEmail(email string) (*model.User, error) {
-	args := m.Called(email)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*model.User), args.Error(1)
-}
-
-func (m *MockDB) FindUserByID(id string) (*model.User, error) {
-	args := m.Called(id)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*model.User), args.Error(1)
-}
-
-func (m *MockDB) CreateUser(user *model.User) (*model.User, error) {
-	args := m.Called(user)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*model.User), args.Error(1)
-}
-
-func (m *MockDB) UpdateUserTokens(userID, accessToken, refreshToken string, tokenExpiry time.Time) error {
-	args := m.Called(userID, accessToken, refreshToken, tokenExpiry)
-	return args.Error(0)
-}
-
 func (m *MockStore) Get(r *http.Request, name string) (*sessions.Session, error) {
 	args := m.Called(r, name)
 	if args.Get(0) == nil {
@@ -148,31 +145,39 @@ func (m *MockAuth) CompleteUserAuth(w http.ResponseWriter, r *http.Request) (got
 	return args.Get(0).(goth.User), args.Error(1)
 }
 
-func setupBaseTest() (*httptest.ResponseRecorder, *gin.Engine, *MockDB, *MockStore, *MockProvider, *MockAuth) {
+func setupBaseTest() (*httptest.ResponseRecorder, *gin.Engine, *database.Memory, *MockStore, *MockProvider, *MockAuth) {
 	gin.SetMode(gin.TestMode)
 
-	mockDB := new(MockDB)
+	db := database.NewMemory()
 	mockStore := new(MockStore)
 	mockProvider := new(MockProvider)
 	mockAuthenticator := new(MockAuth)
 
 	w := httptest.NewRecorder()
 	router := gin.Default()
+	router.Use(middleware.Errors())
 
-	return w, router, mockDB, mockStore, mockProvider, mockAuthenticator
+	return w, router, db, mockStore, mockProvider, mockAuthenticator
 }
 
+// errorCode decodes the code of an error response.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) apierr.Code {
+	t.Helper()
+	var res middleware.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	return res.Error.Code
+}
 func TestNew(t *testing.T) {
 	t.Run("New Handler", func(t *testing.T) {
-		_, _, mockDB, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+		_, _, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
 
 		cfg := &config.Config{
 			FrontendURL: "example.com",
 		}
-		h := New(mockDB, mockStore, cfg, mockProvider, mockAuthenticator)
+		h := New(db, mockStore, cfg, mockProvider, mockAuthenticator)
 
 		assert.NotNil(t, h)
-		assert.Equal(t, mockDB, h.db)
+		assert.Equal(t, db, h.db)
 		assert.Equal(t, mockProvider, h.p)
 		assert.Equal(t, mockStore, h.store)
 		assert.Equal(t, cfg, h.cfg)
@@ -183,13 +188,13 @@ func TestSignInWithProvider(t *testing.T) {
 	t.Run("Sign in with a provider", func(t *testing.T) {
 		gin.SetMode(gin.TestMode)
 
-		w, router, mockDB, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+		w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
 
 		// Tell gothic to use our mock store
 		gothic.Store = mockStore
 
 		// Set up the handler with the mock provider
-		h := New(mockDB, mockStore, &config.Config{}, mockProvider, mockAuthenticator)
+		h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator)
 		router.GET("/auth/:provider", h.SignInWithProvider)
 
 		// Mock the BeginAuth call to return a mock session
@@ -221,11 +226,11 @@ func TestSignInWithProvider(t *testing.T) {
 	})
 }
 
-func setupCallBackTest() (*httptest.ResponseRecorder, *gin.Engine, *MockDB, *MockStore, *MockProvider, *MockAuth) {
-	w, router, mockDB, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+func setupCallBackTest(dbErr error) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory, *MockStore, *MockProvider, *MockAuth) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
 
 	h := &Handler{
-		db:    mockDB,
+		db:    users(db, dbErr),
 		store: mockStore,
 		p:     mockProvider,
 		auth:  mockAuthenticator,
@@ -236,52 +241,41 @@ func setupCallBackTest() (*httptest.ResponseRecorder, *gin.Engine, *MockDB, *Moc
 
 	router.GET("/auth/google/callback", h.CallbackHandler)
 
-	return w, router, mockDB, mockStore, mockProvider, mockAuthenticator
+	return w, router, db, mockStore, mockProvider, mockAuthenticator
 }
 
 func TestCallBackHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	fixedTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	gothUser := goth.User{
+		Email:        "abc@abc.com",
+		AccessToken:  "abc",
+		RefreshToken: "def",
+		ExpiresAt:    fixedTime,
+	}
 
 	testCases := []struct {
 		name           string
-		setupMocks     func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth)
+		dbErr          error
+		setupMocks     func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth)
 		expectedStatus int
-		expectedBody   *model.User
 	}{
 		{
 			name: "Callback Failed User Auth",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
+			setupMocks: func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
 
 				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(nil, errors.New("Error"))
 			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
-		},
-		{
-			name: "Callback Failed DB User Search",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-
-				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-
-				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
-					Email: "abc@abc.com",
-				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, errors.New("Error"))
-
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
+			expectedStatus: http.StatusUnauthorized,
 		},
 		{
-			name: "Callback Failed DB User nil and create user error",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
+			name:  "Callback Failed Upsert User",
+			dbErr: errors.New("Error"),
+			setupMocks: func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
@@ -289,233 +283,165 @@ func TestCallBackHandler(t *testing.T) {
 				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
 					Email: "abc@abc.com",
 				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, nil)
-				mockDB.On("CreateUser", mock.Anything).Return(nil, errors.New("Error"))
-
-				// mockDB.On("UpdateUserTokens", "abc@abc.com").Return(nil, errors.New("Error"))
-			},
-			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
-		},
-		{
-			name: "Callback Failed Update Token",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-
-				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-
-				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
-					Email:        "abc@abc.com",
-					AccessToken:  "abc",
-					RefreshToken: "def",
-					ExpiresAt:    fixedTime,
-				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, nil)
-				mockDB.On("CreateUser", mock.Anything).Return(&model.User{
-					ID: "1",
-				}, nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(errors.New("Error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Callback Failed Get Session",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
-
-				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
-					Email:        "abc@abc.com",
-					AccessToken:  "abc",
-					RefreshToken: "def",
-					ExpiresAt:    fixedTime,
-				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, nil)
-				mockDB.On("CreateUser", mock.Anything).Return(&model.User{
-					ID: "1",
-				}, nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(nil)
-
-				// session := sessions.NewSession(mockStore, "sumnotes_session")
+			setupMocks: func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
+				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(gothUser, nil)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(nil, errors.New("Error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Callback Failed Error Session and Error Save",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
-
-				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
-					Email:        "abc@abc.com",
-					AccessToken:  "abc",
-					RefreshToken: "def",
-					ExpiresAt:    fixedTime,
-				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, nil)
-				mockDB.On("CreateUser", mock.Anything).Return(&model.User{
-					ID: "1",
-				}, nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(nil)
+			setupMocks: func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
+				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(gothUser, nil)
 
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
 				mockStore.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("session save error"))
-
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Callback Success",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
-
-				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(goth.User{
-					Email:        "abc@abc.com",
-					AccessToken:  "abc",
-					RefreshToken: "def",
-					ExpiresAt:    fixedTime,
-				}, nil)
-
-				mockDB.On("FindUserByEmail", "abc@abc.com").Return(nil, nil)
-				mockDB.On("CreateUser", mock.Anything).Return(&model.User{
-					ID: "1",
-				}, nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(nil)
-				mockDB.On("UpdateUserTokens", "1", "abc", "def", fixedTime).Return(nil)
+			setupMocks: func(mockStore *MockStore, mockProvider *MockProvider, mockAuthenticator *MockAuth) {
+				mockAuthenticator.On("CompleteUserAuth", mock.Anything, mock.Anything).Return(gothUser, nil)
 
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
 				mockStore.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
-
 			},
 			expectedStatus: http.StatusTemporaryRedirect,
-			expectedBody:   nil,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			w, router, mockDB, mockStore, mockProvider, mockAuthenticator := setupCallBackTest()
+			w, router, db, mockStore, mockProvider, mockAuthenticator := setupCallBackTest(tc.dbErr)
 
-			tc.setupMocks(mockDB, mockStore, mockProvider, mockAuthenticator)
+			tc.setupMocks(mockStore, mockProvider, mockAuthenticator)
 
 			req, _ := http.NewRequest(http.MethodGet, "/auth/google/callback?provider=google", nil)
 			router.ServeHTTP(w, req)
 
+			assert.Equal(t, tc.expectedStatus, w.Code)
+
 			if tc.expectedStatus == http.StatusTemporaryRedirect {
 				assert.Equal(t, "http://example.com", w.Result().Header.Get("Location"))
 
+				// The signed in user is stored with their tokens.
+				user, err := db.FindUserByEmail(context.Background(), gothUser.Email)
+				require.NoError(t, err)
+				require.NotNil(t, user)
+				assert.Equal(t, "abc", user.AccessToken)
+				assert.Equal(t, "def", user.RefreshToken)
+				assert.True(t, fixedTime.Equal(user.TokenExpiry))
 			}
-
-			assert.Equal(t, tc.expectedStatus, w.Code)
 		})
 	}
 }
 
-func setupMeTest() (*httptest.ResponseRecorder, *gin.Engine, *MockDB, *MockStore, *MockProvider) {
-	w, router, mockDB, mockStore, mockProvider, _ := setupBaseTest()
+// seedUser stores u and returns a session signed in as them.
+func seedUser(t *testing.T, db *database.Memory, mockStore *MockStore, u model.User) *sessions.Session {
+	t.Helper()
+	stored, err := db.UpsertUser(context.Background(), &u)
+	require.NoError(t, err)
+
+	session := sessions.NewSession(mockStore, "sumnotes_session")
+	session.Values["user_id"] = stored.ID
+	return session
+}
+
+func setupMeTest(dbErr error) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory, *MockStore, *MockProvider) {
+	w, router, db, mockStore, mockProvider, _ := setupBaseTest()
 
 	h := &Handler{
-		db:    mockDB,
+		db:    users(db, dbErr),
 		store: mockStore,
 		p:     mockProvider,
 	}
 
 	router.GET("/me", h.Me)
 
-	return w, router, mockDB, mockStore, mockProvider
+	return w, router, db, mockStore, mockProvider
 }
 
 func TestHandler_Me(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
-	expectedUser := &model.User{
-		ID:        "user-123",
+	expectedUser := model.User{
 		Name:      "Test User",
 		Email:     "test@example.com",
 		AvatarURL: "http://example.com/avatar.png",
-		CreatedAt: time.Now(),
 	}
 
 	testCases := []struct {
 		name           string
-		setupMocks     func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider)
+		dbErr          error
+		setupMocks     func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider)
 		expectedStatus int
 		expectedBody   *model.User
 	}{
 		{
 			name: "Get Me Success",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(expectedUser, nil)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   expectedUser,
+			expectedBody:   &expectedUser,
 		},
 		{
 			name: "Get Me Session Error",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
-
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(nil, errors.New("Failed to Get User Session"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Get Me Session Empty User",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 				session.Values["user_id"] = ""
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
 			},
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   nil,
+			expectedStatus: http.StatusUnauthorized,
 		},
 		{
-			name: "Get Me DB Find Error",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			name:  "Get Me DB Find Error",
+			dbErr: errors.New("DB Find User Error"),
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(nil, errors.New("DB Find User Error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Get Me DB No User",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 				session.Values["user_id"] = "user-123"
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(nil, nil)
 			},
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   nil,
+			expectedStatus: http.StatusUnauthorized,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			w, router, mockDB, mockStore, mockProvider := setupMeTest()
+			w, router, db, mockStore, mockProvider := setupMeTest(tc.dbErr)
 
-			tc.setupMocks(mockDB, mockStore, mockProvider)
+			tc.setupMocks(t, db, mockStore, mockProvider)
 
 			// Perform the request
 			req, _ := http.NewRequest(http.MethodGet, "/me", nil)
@@ -529,16 +455,15 @@ func TestHandler_Me(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &responseBody)
 				assert.NoError(t, err)
 
-				// We only compare the fields that are expected to be returned.
-				// The tokens and expiry are updated in the background.
-				assert.Equal(t, tc.expectedBody.ID, responseBody.ID)
+				stored, err := db.FindUserByEmail(context.Background(), tc.expectedBody.Email)
+				require.NoError(t, err)
+				assert.Equal(t, stored.ID, responseBody.ID)
 				assert.Equal(t, tc.expectedBody.Name, responseBody.Name)
 				assert.Equal(t, tc.expectedBody.Email, responseBody.Email)
 				assert.Equal(t, tc.expectedBody.AvatarURL, responseBody.AvatarURL)
 			}
 
 			// Verify that all mock expectations were met
-			mockDB.AssertExpectations(t)
 			mockStore.AssertExpectations(t)
 			mockProvider.AssertExpectations(t)
 		})
@@ -552,12 +477,12 @@ func TestHandler_Success(t *testing.T) {
 	t.Run("Get Me Success", func(t *testing.T) {
 		expectedRedirect := "http://test.com"
 
-		_, _, mockDB, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+		_, _, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
 
 		cfg := &config.Config{
 			FrontendURL: expectedRedirect,
 		}
-		h := New(mockDB, mockStore, cfg, mockProvider, mockAuthenticator)
+		h := New(db, mockStore, cfg, mockProvider, mockAuthenticator)
 
 		// Setup router
 		router := gin.Default()
@@ -574,15 +499,15 @@ func TestHandler_Success(t *testing.T) {
 
 }
 
-func setupRefreshTest() (*httptest.ResponseRecorder, *gin.Engine, *MockDB, *MockStore, *MockProvider) {
-	w, router, mockDB, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+func setupRefreshTest(dbErr error) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory, *MockStore, *MockProvider) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
 
 	cfg := &config.Config{}
-	h := New(mockDB, mockStore, cfg, mockProvider, mockAuthenticator)
+	h := New(users(db, dbErr), mockStore, cfg, mockProvider, mockAuthenticator)
 
 	router.GET("/refresh", h.Refresh)
 
-	return w, router, mockDB, mockStore, mockProvider
+	return w, router, db, mockStore, mockProvider
 }
 
 func TestHandler_Refresh(t *testing.T) {
@@ -590,13 +515,11 @@ func TestHandler_Refresh(t *testing.T) {
 
 	fixedTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
 
-	expectedUser := &model.User{
-		ID:           "user-123",
+	expectedUser := model.User{
 		Name:         "Test User",
 		Email:        "test@example.com",
 		AvatarURL:    "http://example.com/avatar.png",
 		RefreshToken: "old-refresh-token",
-		CreatedAt:    fixedTime,
 	}
 
 	// Define the new token details
@@ -608,80 +531,65 @@ func TestHandler_Refresh(t *testing.T) {
 
 	testCases := []struct {
 		name           string
-		setupMocks     func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider)
+		dbErr          error
+		setupMocks     func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider)
 		expectedStatus int
 		expectedBody   *model.User
 	}{
 		{
 			name: "Get Refresh Success",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(expectedUser, nil)
 				mockProvider.On("RefreshToken", expectedUser.RefreshToken).Return(newToken, nil)
-				mockDB.On("UpdateUserTokens", "user-123", newToken.AccessToken, newToken.RefreshToken, newToken.Expiry).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
-			expectedBody:   expectedUser,
+			expectedBody:   &expectedUser,
 		},
 		{
 			name: "Get Refresh Session Failure",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
-
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(nil, errors.New("Failed to get user session"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Get Refresh No User Session",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 				session.Values["user_id"] = ""
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
 			},
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   nil,
+			expectedStatus: http.StatusUnauthorized,
 		},
 		{
-			name: "Get Refresh DB User Error",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			name:  "Get Refresh DB User Error",
+			dbErr: errors.New("Failed to get user in DB"),
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-
-				mockDB.On("FindUserByID", "user-123").Return(nil, errors.New("Failed to get user in DB"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 		{
 			name: "Get Refresh DB No User",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
 				session := sessions.NewSession(mockStore, "sumnotes_session")
 				session.Values["user_id"] = "user-123"
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-
-				mockDB.On("FindUserByID", "user-123").Return(nil, nil)
 			},
-			expectedStatus: http.StatusNotFound,
-			expectedBody:   nil,
+			expectedStatus: http.StatusUnauthorized,
 		},
 		{
 			name: "Refresh fails and session is cleared",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(expectedUser, nil)
 				mockProvider.On("RefreshToken", expectedUser.RefreshToken).Return(nil, auth.ErrRefreshFailed)
 
 				// Expect Save to be called to clear the session
@@ -690,31 +598,27 @@ func TestHandler_Refresh(t *testing.T) {
 				})).Return(nil)
 			},
 			expectedStatus: http.StatusUnauthorized,
-			expectedBody:   nil,
 		},
 		{
 			name: "Refresh fails and session save fails",
-			setupMocks: func(mockDB *MockDB, mockStore *MockStore, mockProvider *MockProvider) {
-				session := sessions.NewSession(mockStore, "sumnotes_session")
-				session.Values["user_id"] = "user-123"
+			setupMocks: func(t *testing.T, db *database.Memory, mockStore *MockStore, mockProvider *MockProvider) {
+				session := seedUser(t, db, mockStore, expectedUser)
 
 				mockStore.On("Get", mock.Anything, "sumnotes_session").Return(session, nil)
-				mockDB.On("FindUserByID", "user-123").Return(expectedUser, nil)
 				mockProvider.On("RefreshToken", expectedUser.RefreshToken).Return(nil, auth.ErrRefreshFailed)
 
 				// Expect Save to be called and fail
 				mockStore.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("session save error"))
 			},
 			expectedStatus: http.StatusInternalServerError,
-			expectedBody:   nil,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			w, router, mockDB, mockStore, mockProvider := setupRefreshTest()
+			w, router, db, mockStore, mockProvider := setupRefreshTest(tc.dbErr)
 
-			tc.setupMocks(mockDB, mockStore, mockProvider)
+			tc.setupMocks(t, db, mockStore, mockProvider)
 
 			// Perform the request
 			req, _ := http.NewRequest(http.MethodGet, "/refresh", nil)
@@ -728,16 +632,20 @@ func TestHandler_Refresh(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &responseBody)
 				assert.NoError(t, err)
 
-				// We only compare the fields that are expected to be returned.
-				// The tokens and expiry are updated in the background.
-				assert.Equal(t, tc.expectedBody.ID, responseBody.ID)
+				stored, err := db.FindUserByEmail(context.Background(), tc.expectedBody.Email)
+				require.NoError(t, err)
+				assert.Equal(t, stored.ID, responseBody.ID)
 				assert.Equal(t, tc.expectedBody.Name, responseBody.Name)
 				assert.Equal(t, tc.expectedBody.Email, responseBody.Email)
 				assert.Equal(t, tc.expectedBody.AvatarURL, responseBody.AvatarURL)
+
+				// The new tokens are stored.
+				assert.Equal(t, newToken.AccessToken, stored.AccessToken)
+				assert.Equal(t, newToken.RefreshToken, stored.RefreshToken)
+				assert.True(t, newToken.Expiry.Equal(stored.TokenExpiry))
 			}
 
 			// Verify that all mock expectations were met
-			mockDB.AssertExpectations(t)
 			mockStore.AssertExpectations(t)
 			mockProvider.AssertExpectations(t)
 		})
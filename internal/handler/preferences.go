@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"main/internal/apierr"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) Preferences(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	prefs, err := h.userPreferences(c.Request.Context(), user.ID)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":     prefs,
+		"builtinProfiles": summarizer.BuiltinProfiles,
+	})
+}
+
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	var prefs model.Preferences
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, "invalid preferences"))
+		return
+	}
+	prefs.UserID = user.ID
+
+	if err := summarizer.ValidatePreferences(&prefs); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, err.Error()))
+		return
+	}
+
+	if err := h.preferences.SavePreferences(c.Request.Context(), &prefs); err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// userPreferences returns the stored preferences or the defaults.
+func (h *Handler) userPreferences(ctx context.Context, userID string) (*model.Preferences, error) {
+	if h.preferences == nil {
+		return summarizer.DefaultPreferences(userID), nil
+	}
+
+	prefs, err := h.preferences.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		return summarizer.DefaultPreferences(userID), nil
+	}
+	return prefs, nil
+}
+
+// summaryProfile resolves the ?profile= query parameter against the user's
+// preferences and aborts with a bad request for unknown profiles.
+func (h *Handler) summaryProfile(c *gin.Context, user *model.User) (*model.Preferences, model.PromptProfile, bool) {
+	prefs, err := h.userPreferences(c.Request.Context(), user.ID)
+	if err != nil {
+		middleware.Abort(c, err)
+		return nil, model.PromptProfile{}, false
+	}
+
+	profile, err := summarizer.FindProfile(prefs, c.Query("profile"))
+	if err != nil {
+		if errors.Is(err, summarizer.ErrUnknownProfile) {
+			middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, err.Error()))
+		} else {
+			middleware.Abort(c, err)
+		}
+		return nil, model.PromptProfile{}, false
+	}
+
+	return prefs, profile, true
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+)
+
+// instructionRecorder records the instructions of the last request.
+type instructionRecorder struct {
+	instructions string
+}
+
+func (r *instructionRecorder) Model() string { return "recorder" }
+
+func (r *instructionRecorder) Summarize(ctx context.Context, req summarizer.Request) (*summarizer.Response, error) {
+	r.instructions = req.Instructions
+	return &summarizer.Response{Text: "summary"}, nil
+}
+
+func setupPreferencesTest(fg *fakeGmail, s summarizer.Summarizer) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithPreferenceStore(db), WithGmailClient(fg.client()), WithSummarizer(s))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/me/preferences", h.Preferences)
+	router.PUT("/me/preferences", h.UpdatePreferences)
+	router.GET("/messages/:id/summary", h.MessageSummary)
+
+	return w, router, db
+}
+
+func TestHandler_Preferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Defaults when nothing is saved", func(t *testing.T) {
+		w, router, _ := setupPreferencesTest(newFakeGmail(t, nil), summarizer.NewOffline(0))
+
+		req, _ := http.NewRequest(http.MethodGet, "/me/preferences", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"defaultProfile":"default"`)
+		assert.Contains(t, w.Body.String(), `"builtinProfiles"`)
+	})
+
+	t.Run("Invalid template is rejected at save time", func(t *testing.T) {
+		w, router, db := setupPreferencesTest(newFakeGmail(t, nil), summarizer.NewOffline(0))
+
+		req, _ := http.NewRequest(http.MethodPut, "/me/preferences", strings.NewReader(`{"profiles":[{"name":"mine","template":"{{.Nope}}"}]}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `profile \"mine\"`)
+		prefs, err := db.GetPreferences(context.Background(), "user-123")
+		require.NoError(t, err)
+		assert.Nil(t, prefs)
+	})
+
+	t.Run("Valid preferences are saved", func(t *testing.T) {
+		w, router, db := setupPreferencesTest(newFakeGmail(t, nil), summarizer.NewOffline(0))
+
+		req, _ := http.NewRequest(http.MethodPut, "/me/preferences", strings.NewReader(`{"format":"prose","language":"French","defaultProfile":"mine","profiles":[{"name":"mine","template":"Summarize in {{.Language}}"}]}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		prefs, err := db.GetPreferences(context.Background(), "user-123")
+		require.NoError(t, err)
+		require.NotNil(t, prefs)
+		assert.Equal(t, "prose", prefs.Format)
+		assert.Equal(t, "French", prefs.Language)
+		assert.Equal(t, "mine", prefs.DefaultProfile)
+	})
+}
+
+func TestHandler_MessageSummaryProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	saved := model.Preferences{
+		UserID:         "user-123",
+		Format:         "bullets",
+		Length:         "line",
+		Language:       "Spanish",
+		DefaultProfile: "default",
+		Profiles:       []model.PromptProfile{{Name: "mine", Template: "Mine: {{.Subject}} in {{.Language}}"}},
+	}
+
+	t.Run("Profile from query renders the template", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+		})
+		rec := &instructionRecorder{}
+		w, router, db := setupPreferencesTest(fg, rec)
+		require.NoError(t, db.SavePreferences(context.Background(), &saved))
+
+		req, _ := http.NewRequest(http.MethodGet, "/messages/msg-1/summary?profile=mine", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Mine: Invoice in Spanish", rec.instructions)
+		assert.Contains(t, w.Body.String(), `"profile":"mine"`)
+	})
+
+	t.Run("Unknown profile", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, db := setupPreferencesTest(fg, &instructionRecorder{})
+		require.NoError(t, db.SavePreferences(context.Background(), &saved))
+
+		req, _ := http.NewRequest(http.MethodGet, "/messages/msg-1/summary?profile=nope", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, fg.requestsFor(http.MethodGet, "/gmail/v1/users/me/messages/msg-1"))
+	})
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"main/internal/apierr"
+	"main/internal/mailbox"
+	"main/internal/middleware"
+	"main/internal/summarizer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/gmail/v1"
+)
+
+type draftReplyResponse struct {
+	DraftID  string `json:"draftId"`
+	ThreadID string `json:"threadId"`
+	To       string `json:"to"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+}
+
+func (h *Handler) DraftReply(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	var opts summarizer.ReplyOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, "invalid reply options"))
+			return
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, err.Error()))
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.client(ctx, user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	var original *gmail.Message
+	err = client.Call(ctx, "messages.get", func() error {
+		var err error
+		original, err = client.Service.Users.Messages.Get(mailbox.User, c.Param("id")).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		middleware.Abort(c, apierr.Gmail(err))
+		return
+	}
+
+	markdown, err := mailbox.Markdown(original)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	proposal, err := h.replies.Summarize(ctx, summarizer.ReplyRequest(opts, mailbox.Header(original, "From"), markdown))
+	if err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.Upstream, "failed to propose a reply"))
+		return
+	}
+
+	reply := mailbox.Reply(original, user.Email, proposal.Text)
+
+	var draft *gmail.Draft
+	err = client.Call(ctx, "drafts.create", func() error {
+		var err error
+		draft, err = client.Service.Users.Drafts.Create(mailbox.User, &gmail.Draft{
+			Message: &gmail.Message{
+				Raw:      reply.Raw(),
+				ThreadId: original.ThreadId,
+			},
+		}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		middleware.Abort(c, apierr.Gmail(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, draftReplyResponse{
+		DraftID:  draft.Id,
+		ThreadID: original.ThreadId,
+		To:       reply.To,
+		Subject:  reply.Subject,
+		Body:     reply.Body,
+	})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"main/internal/apierr"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+
+	"main/internal/config"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+)
+
+func setupDraftReplyTest(fg *fakeGmail, opts ...Option) (*httptest.ResponseRecorder, *gin.Engine) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	opts = append([]Option{WithGmailClient(fg.client()), WithSummarizer(summarizer.NewOffline(2))}, opts...)
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator, opts...)
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123", Email: "me@example.com"})
+	})
+	router.POST("/messages/:id/draft-reply", h.DraftReply)
+
+	return w, router
+}
+
+func originalMessage() *gmail.Message {
+	html := "<p>Hi, can you send the <b>invoice</b> by Friday? Thanks a lot. Bob</p>"
+	return &gmail.Message{
+		Id:       "msg-1",
+		ThreadId: "thread-1",
+		Payload: &gmail.MessagePart{
+			MimeType: "multipart/alternative",
+			Headers: []*gmail.MessagePartHeader{
+				{Name: "From", Value: "Bob <bob@example.com>"},
+				{Name: "Subject", Value: "Invoice"},
+				{Name: "Message-ID", Value: "<second@example.com>"},
+				{Name: "References", Value: "<first@example.com>"},
+			},
+			Parts: []*gmail.MessagePart{
+				{MimeType: "text/plain", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("plain"))}},
+				{MimeType: "text/html", Body: &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte(html))}},
+			},
+		},
+	}
+}
+
+func TestHandler_DraftReply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Draft is threaded and saved", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+			"POST /gmail/v1/users/me/drafts":        writeJSON(gmail.Draft{Id: "draft-1"}),
+		})
+		w, router := setupDraftReplyTest(fg)
+
+		req, _ := http.NewRequest(http.MethodPost, "/messages/msg-1/draft-reply", strings.NewReader(`{"tone":"friendly","length":"short"}`))
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var res draftReplyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, "draft-1", res.DraftID)
+		assert.Equal(t, "Re: Invoice", res.Subject)
+
+		calls := fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/drafts")
+		require.Len(t, calls, 1)
+
+		var draft gmail.Draft
+		require.NoError(t, json.Unmarshal(calls[0].Body, &draft))
+		assert.Equal(t, "thread-1", draft.Message.ThreadId)
+
+		raw, err := base64.URLEncoding.DecodeString(draft.Message.Raw)
+		require.NoError(t, err)
+
+		msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+		require.NoError(t, err)
+
+		assert.Equal(t, "me@example.com", msg.Header.Get("From"))
+		assert.Equal(t, "Bob <bob@example.com>", msg.Header.Get("To"))
+		assert.Equal(t, "Re: Invoice", msg.Header.Get("Subject"))
+		assert.Equal(t, "<second@example.com>", msg.Header.Get("In-Reply-To"))
+		assert.Equal(t, "<first@example.com> <second@example.com>", msg.Header.Get("References"))
+		assert.Equal(t, "1.0", msg.Header.Get("MIME-Version"))
+		assert.Contains(t, msg.Header.Get("Content-Type"), "text/plain")
+		_, err = msg.Header.Date()
+		assert.NoError(t, err)
+
+		body, err := io.ReadAll(msg.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "Hi, can you send the invoice by Friday? Thanks a lot.", string(body))
+	})
+
+	t.Run("Replies bypass the summary cache", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+			"POST /gmail/v1/users/me/drafts":        writeJSON(gmail.Draft{Id: "draft-1"}),
+		})
+		cached := &countingSummarizer{}
+		w, router := setupDraftReplyTest(fg, WithSummaryCache(summarizer.NewCached(cached, nil, 10)))
+
+		req, _ := http.NewRequest(http.MethodPost, "/messages/msg-1/draft-reply", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Zero(t, cached.calls)
+	})
+
+	t.Run("Unknown tone", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router := setupDraftReplyTest(fg)
+
+		req, _ := http.NewRequest(http.MethodPost, "/messages/msg-1/draft-reply", strings.NewReader(`{"tone":"angry"}`))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/drafts"))
+	})
+
+	t.Run("Message not in Gmail", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router := setupDraftReplyTest(fg)
+
+		req, _ := http.NewRequest(http.MethodPost, "/messages/msg-1/draft-reply", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apierr.NotFound, errorCode(t, w))
+	})
+
+	t.Run("Gmail failure", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+			"POST /gmail/v1/users/me/drafts": func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "backend error", http.StatusInternalServerError)
+			},
+		})
+		w, router := setupDraftReplyTest(fg)
+
+		req, _ := http.NewRequest(http.MethodPost, "/messages/msg-1/draft-reply", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, apierr.Upstream, errorCode(t, w))
+		assert.NotContains(t, w.Body.String(), "backend error")
+	})
+}
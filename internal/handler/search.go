@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"main/internal/apierr"
+	"main/internal/middleware"
+	"main/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+func (h *Handler) Search(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	q, err := parseSearchQuery(c)
+	if err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.BadRequest, err.Error()))
+		return
+	}
+
+	results, err := h.messages.SearchMessages(c.Request.Context(), user.ID, q)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+type queryError string
+
+func (e queryError) Error() string { return string(e) }
+
+// parseSearchQuery reads q, sender, label, after, before, limit and offset.
+// Dates accept either YYYY-MM-DD or RFC 3339.
+func parseSearchQuery(c *gin.Context) (model.SearchQuery, error) {
+	q := model.SearchQuery{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Sender: strings.TrimSpace(c.Query("sender")),
+		Label:  strings.TrimSpace(c.Query("label")),
+		Limit:  defaultSearchLimit,
+	}
+	if q.Query == "" {
+		return q, queryError("q is required")
+	}
+
+	var err error
+	if q.After, err = parseDate(c.Query("after")); err != nil {
+		return q, queryError("after must be a date")
+	}
+	if q.Before, err = parseDate(c.Query("before")); err != nil {
+		return q, queryError("before must be a date")
+	}
+
+	if v := c.Query("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit <= 0 || q.Limit > maxSearchLimit {
+			return q, queryError("limit must be between 1 and 100")
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, queryError("offset must not be negative")
+		}
+	}
+
+	return q, nil
+}
+
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/middleware"
+	"main/internal/model"
+)
+
+// MockMessageStore is a mock implementation of the MessageStore interface.
+type MockMessageStore struct {
+	mock.Mock
+}
+
+var _ database.MessageStore = (*MockMessageStore)(nil)
+
+func (m *MockMessageStore) SaveMessage(ctx context.Context, msg *model.Message) error {
+	args := m.Called(msg)
+	return args.Error(0)
+}
+
+func (m *MockMessageStore) FindMessage(ctx context.Context, userID, id string) (*model.Message, error) {
+	args := m.Called(userID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockMessageStore) SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error) {
+	args := m.Called(userID, q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SearchResult), args.Error(1)
+}
+
+func setupSearchTest() (*httptest.ResponseRecorder, *gin.Engine, *MockMessageStore) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	mockMessages := new(MockMessageStore)
+
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator, WithMessageStore(mockMessages))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/search", h.Search)
+
+	return w, router, mockMessages
+}
+
+func TestHandler_Search(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name           string
+		url            string
+		setupMocks     func(mockMessages *MockMessageStore)
+		expectedStatus int
+	}{
+		{
+			name: "Search with filters",
+			url:  `/search?q=%22invoice+issue%22&sender=bob&label=INBOX&after=2026-01-01&before=2026-02-01T00:00:00Z&limit=5&offset=10`,
+			setupMocks: func(mockMessages *MockMessageStore) {
+				mockMessages.On("SearchMessages", "user-123", model.SearchQuery{
+					Query:  `"invoice issue"`,
+					Sender: "bob",
+					Label:  "INBOX",
+					After:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+					Before: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+					Limit:  5,
+					Offset: 10,
+				}).Return([]model.SearchResult{{ID: "msg-1", Snippet: "the <mark>invoice</mark>"}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing query",
+			url:            "/search?sender=bob",
+			setupMocks:     func(mockMessages *MockMessageStore) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid date",
+			url:            "/search?q=x&after=yesterday",
+			setupMocks:     func(mockMessages *MockMessageStore) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Limit too large",
+			url:            "/search?q=x&limit=1000",
+			setupMocks:     func(mockMessages *MockMessageStore) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Store error",
+			url:  "/search?q=x",
+			setupMocks: func(mockMessages *MockMessageStore) {
+				mockMessages.On("SearchMessages", "user-123", mock.Anything).Return(nil, errors.New("Error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, router, mockMessages := setupSearchTest()
+
+			tc.setupMocks(mockMessages)
+
+			req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code)
+			mockMessages.AssertExpectations(t)
+		})
+	}
+}
+
+// spanMessages records the span its search ran under.
+type spanMessages struct {
+	database.MessageStore
+	span trace.SpanContext
+}
+
+func (s *spanMessages) SearchMessages(ctx context.Context, userID string, q model.SearchQuery) ([]model.SearchResult, error) {
+	s.span = trace.SpanContextFromContext(ctx)
+	return s.MessageStore.SearchMessages(ctx, userID, q)
+}
+
+func TestHandler_Search_RequestSpan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prevProvider)
+
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+	messages := &spanMessages{MessageStore: database.NewMemory()}
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator, WithMessageStore(messages))
+
+	router.Use(middleware.Tracing(), func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/search", h.Search)
+
+	req, _ := http.NewRequest(http.MethodGet, "/search?q=x", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The store queries under the request span, so its DB spans are children
+	// of it.
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, spans[0].SpanContext(), messages.span)
+}
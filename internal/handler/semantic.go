@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"main/internal/apierr"
+	"main/internal/embedding"
+	"main/internal/middleware"
+	"main/internal/model"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SemanticSearch(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		middleware.Abort(c, apierr.New(apierr.BadRequest, "q is required"))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			middleware.Abort(c, apierr.New(apierr.BadRequest, "limit must be between 1 and 100"))
+			return
+		}
+		limit = n
+	}
+
+	vectors, err := h.embedder.Embed(c.Request.Context(), []string{query})
+	if err != nil {
+		middleware.Abort(c, apierr.Wrap(err, apierr.Upstream, "failed to embed the query"))
+		return
+	}
+
+	results, err := h.embeddings.SimilarMessages(c.Request.Context(), user.ID, h.embedder.Model(), vectors[0], limit)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// indexMessage embeds the stored message so it shows up in semantic search.
+func (h *Handler) indexMessage(ctx context.Context, msg *model.Message) error {
+	if h.embeddings == nil {
+		return nil
+	}
+
+	texts := embedding.Chunk(msg.Subject+"\n\n"+msg.Markdown, embedding.DefaultChunkWords, embedding.DefaultChunkOverlap)
+	if len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := h.embedder.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	chunks := make([]model.Chunk, len(texts))
+	for i := range texts {
+		chunks[i] = model.Chunk{Index: i, Content: texts[i], Embedding: vectors[i]}
+	}
+
+	return h.embeddings.SaveChunks(ctx, msg.UserID, msg.ID, h.embedder.Model(), chunks)
+}
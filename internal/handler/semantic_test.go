@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/embedding"
+	"main/internal/middleware"
+	"main/internal/model"
+)
+
+func setupSemanticTest() (*httptest.ResponseRecorder, *gin.Engine, *Handler, *database.Memory) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithMessageStore(db), WithEmbeddings(embedding.NewHashing(16), db))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/search/semantic", h.SemanticSearch)
+
+	return w, router, h, db
+}
+
+func TestHandler_SemanticSearch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Returns similar messages", func(t *testing.T) {
+		w, router, h, db := setupSemanticTest()
+
+		for _, msg := range []*model.Message{
+			{ID: "msg-1", UserID: "user-123", Subject: "Invoice", Markdown: "the invoice issue is overdue"},
+			{ID: "msg-2", UserID: "user-123", Subject: "Lunch", Markdown: "pizza on friday"},
+		} {
+			require.NoError(t, db.SaveMessage(t.Context(), msg))
+			require.NoError(t, h.indexMessage(t.Context(), msg))
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/search/semantic?q=invoice+issue&limit=1", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var results []model.SemanticResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+		require.Len(t, results, 1)
+		assert.Equal(t, "msg-1", results[0].ID)
+		assert.Positive(t, results[0].Score)
+	})
+
+	t.Run("Missing query", func(t *testing.T) {
+		w, router, _, _ := setupSemanticTest()
+
+		req, _ := http.NewRequest(http.MethodGet, "/search/semantic", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_IndexMessage(t *testing.T) {
+	_, _, h, db := setupSemanticTest()
+
+	msg := &model.Message{ID: "msg-1", UserID: "user-123", Subject: "Invoice", Markdown: "the invoice is overdue"}
+	require.NoError(t, db.SaveMessage(t.Context(), msg))
+	require.NoError(t, h.indexMessage(t.Context(), msg))
+
+	results, err := db.SimilarMessages(t.Context(), "user-123", "hashing-16", make([]float32, 16), 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "msg-1", results[0].ID)
+	assert.Equal(t, "Invoice the invoice is overdue", results[0].Passage)
+}
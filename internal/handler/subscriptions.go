@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"main/internal/apierr"
+	"main/internal/middleware"
+	"main/internal/subscriptions"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSubscriptionScan = 200
+	maxSubscriptionScan     = 500
+)
+
+func (h *Handler) Subscriptions(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	limit := defaultSubscriptionScan
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSubscriptionScan {
+			middleware.Abort(c, apierr.New(apierr.BadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSubscriptionScan)))
+			return
+		}
+		limit = n
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.client(ctx, user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	msgs, err := subscriptions.Scan(ctx, client, int64(limit))
+	if err != nil {
+		middleware.Abort(c, apierr.Gmail(err))
+		return
+	}
+
+	for _, sub := range subscriptions.Aggregate(user.ID, msgs) {
+		if _, err := h.subscriptions.SaveSubscription(ctx, &sub); err != nil {
+			middleware.Abort(c, err)
+			return
+		}
+	}
+
+	subs, err := h.subscriptions.ListSubscriptions(ctx, user.ID)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+	subscriptions.Rank(subs)
+
+	c.JSON(http.StatusOK, subs)
+}
+
+func (h *Handler) Unsubscribe(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	sub, err := h.subscriptions.FindSubscription(c.Request.Context(), user.ID, c.Param("id"))
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+	if sub == nil {
+		middleware.Abort(c, apierr.New(apierr.NotFound, "subscription not found"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.client(ctx, user)
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	method, err := subscriptions.NewUnsubscriber(h.httpClient).Unsubscribe(ctx, client, user, sub)
+	if err != nil {
+		switch {
+		case errors.Is(err, subscriptions.ErrManualUnsubscribe):
+			// The frontend opens the sender's page instead.
+			middleware.Abort(c, apierr.Wrap(err, apierr.Unprocessable, err.Error()).
+				WithDetails(gin.H{"unsubscribeUrl": sub.UnsubscribeURL}))
+		case errors.Is(err, subscriptions.ErrUnsubscribed):
+			middleware.Abort(c, apierr.Wrap(err, apierr.Conflict, err.Error()))
+		case errors.Is(err, subscriptions.ErrNoTarget):
+			middleware.Abort(c, apierr.Wrap(err, apierr.Unprocessable, err.Error()))
+		default:
+			middleware.Abort(c, apierr.Wrap(err, apierr.Upstream, "failed to unsubscribe"))
+		}
+		return
+	}
+
+	now := time.Now()
+	if err := h.subscriptions.MarkUnsubscribed(ctx, sub.ID, now); err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+	sub.UnsubscribedAt = &now
+
+	c.JSON(http.StatusOK, gin.H{
+		"method":       method,
+		"subscription": sub,
+	})
+}
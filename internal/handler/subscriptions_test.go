@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/middleware"
+	"main/internal/model"
+)
+
+func setupSubscriptionsTest(fg *fakeGmail, client *http.Client) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithSubscriptionStore(db), WithGmailClient(fg.client()), WithHTTPClient(client))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123", Email: "me@example.com"})
+	})
+	router.GET("/subscriptions", h.Subscriptions)
+	router.POST("/subscriptions/:id/unsubscribe", h.Unsubscribe)
+
+	return w, router, db
+}
+
+// seedSubscription stores a subscription of the test user and returns its
+// unsubscribe path.
+func seedSubscription(t *testing.T, db *database.Memory, sub model.Subscription) string {
+	t.Helper()
+	sub.UserID = "user-123"
+	sub.Sender = "news@example.com"
+	stored, err := db.SaveSubscription(context.Background(), &sub)
+	require.NoError(t, err)
+	if sub.UnsubscribedAt != nil {
+		require.NoError(t, db.MarkUnsubscribed(context.Background(), stored.ID, *sub.UnsubscribedAt))
+	}
+	return "/subscriptions/" + stored.ID + "/unsubscribe"
+}
+
+// unsubscribed reports whether the subscription behind path is marked
+// unsubscribed.
+func unsubscribed(t *testing.T, db *database.Memory, path string) bool {
+	t.Helper()
+	id := strings.TrimSuffix(strings.TrimPrefix(path, "/subscriptions/"), "/unsubscribe")
+	sub, err := db.FindSubscription(context.Background(), "user-123", id)
+	require.NoError(t, err)
+	require.NotNil(t, sub)
+	return sub.UnsubscribedAt != nil
+}
+
+func TestHandler_Subscriptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	header := func(name, value string) *gmail.MessagePartHeader {
+		return &gmail.MessagePartHeader{Name: name, Value: value}
+	}
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"GET /gmail/v1/users/me/messages": writeJSON(gmail.ListMessagesResponse{
+			Messages: []*gmail.Message{{Id: "m1"}, {Id: "m2"}},
+		}),
+		"GET /gmail/v1/users/me/messages/m1": writeJSON(gmail.Message{Id: "m1", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			header("From", "News <news@example.com>"), header("List-Unsubscribe", "<mailto:leave@example.com>"),
+		}}}),
+		"GET /gmail/v1/users/me/messages/m2": writeJSON(gmail.Message{Id: "m2", Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			header("From", "friend@example.com"),
+		}}}),
+	})
+	w, router, db := setupSubscriptionsTest(fg, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/subscriptions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "news@example.com")
+	// Both messages are fetched in one batch.
+	assert.Len(t, fg.requestsFor(http.MethodPost, "/batch/gmail/v1"), 1)
+	assert.Len(t, fg.requestsFor(http.MethodGet, "/gmail/v1/users/me/messages/m1"), 1)
+	assert.Len(t, fg.requestsFor(http.MethodGet, "/gmail/v1/users/me/messages/m2"), 1)
+
+	// Only the newsletter is a subscription.
+	subs, err := db.ListSubscriptions(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "news@example.com", subs[0].Sender)
+	assert.Equal(t, "mailto:leave@example.com", subs[0].UnsubscribeMailto)
+}
+
+func TestHandler_Unsubscribe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("One-click unsubscribe", func(t *testing.T) {
+		var posted string
+		sender := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = r.ParseForm()
+			posted = r.PostForm.Get("List-Unsubscribe")
+		}))
+		defer sender.Close()
+
+		fg := newFakeGmail(t, nil)
+		w, router, db := setupSubscriptionsTest(fg, sender.Client())
+		path := seedSubscription(t, db, model.Subscription{UnsubscribeURL: sender.URL, OneClick: true})
+
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "One-Click", posted)
+		assert.Contains(t, w.Body.String(), `"method":"one-click"`)
+		assert.True(t, unsubscribed(t, db, path))
+	})
+
+	t.Run("Mailto unsubscribe is sent through Gmail", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"POST /gmail/v1/users/me/messages/send": writeJSON(gmail.Message{Id: "sent-1"}),
+		})
+		w, router, db := setupSubscriptionsTest(fg, nil)
+		path := seedSubscription(t, db, model.Subscription{UnsubscribeMailto: "mailto:leave@example.com?subject=stop"})
+
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, unsubscribed(t, db, path))
+
+		calls := fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/send")
+		require.Len(t, calls, 1)
+
+		var sent gmail.Message
+		require.NoError(t, json.Unmarshal(calls[0].Body, &sent))
+		raw, err := base64.URLEncoding.DecodeString(sent.Raw)
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), "To: leave@example.com\r\n")
+		assert.Contains(t, string(raw), "Subject: stop\r\n")
+	})
+
+	t.Run("Manual unsubscribe returns the page", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, db := setupSubscriptionsTest(fg, nil)
+		path := seedSubscription(t, db, model.Subscription{UnsubscribeURL: "https://example.com/u"})
+
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.True(t, strings.Contains(w.Body.String(), "https://example.com/u"))
+		assert.False(t, unsubscribed(t, db, path))
+	})
+
+	t.Run("Already unsubscribed", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, db := setupSubscriptionsTest(fg, nil)
+
+		unsubscribedAt := time.Now()
+		path := seedSubscription(t, db, model.Subscription{UnsubscribeMailto: "mailto:leave@example.com", UnsubscribedAt: &unsubscribedAt})
+
+		req, _ := http.NewRequest(http.MethodPost, path, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Empty(t, fg.requestsFor(http.MethodPost, "/gmail/v1/users/me/messages/send"))
+	})
+
+	t.Run("Unknown subscription", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, _ := setupSubscriptionsTest(fg, nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/subscriptions/missing/unsubscribe", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
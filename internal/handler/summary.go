@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"main/internal/apierr"
+	"main/internal/mailbox"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Summary pipeline stages reported to streaming clients.
+const (
+	stageFetch     = "fetch"
+	stageConvert   = "convert"
+	stageSummarize = "summarize"
+)
+
+type progressEvent struct {
+	Stage string `json:"stage"`
+}
+
+type tokenEvent struct {
+	Text string `json:"text"`
+}
+
+type errorEvent struct {
+	Message string `json:"message"`
+}
+
+// MessageSummary summarizes a single message and returns the stored summary.
+func (h *Handler) MessageSummary(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	prefs, profile, ok := h.summaryProfile(c, user)
+	if !ok {
+		return
+	}
+
+	summary, err := h.summarizeMessage(c, user, c.Param("id"), prefs, profile, func(string) {}, func(string) error { return nil })
+	if err != nil {
+		middleware.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// StreamMessageSummary summarizes a single message and streams progress and
+// tokens as Server-Sent Events. The summary is only stored once it
+// completes; a client disconnect cancels the pipeline.
+func (h *Handler) StreamMessageSummary(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		middleware.Abort(c, apierr.New(apierr.Unauthenticated, ""))
+		return
+	}
+
+	prefs, profile, ok := h.summaryProfile(c, user)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(event string, data any) error {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+		return nil
+	}
+
+	progress := func(stage string) {
+		_ = send("progress", progressEvent{stage})
+	}
+	onToken := func(token string) error {
+		return send("token", tokenEvent{token})
+	}
+
+	summary, err := h.summarizeMessage(c, user, c.Param("id"), prefs, profile, progress, onToken)
+	if err != nil {
+		if c.Request.Context().Err() == nil {
+			_ = send("error", errorEvent{"failed to summarize message"})
+		}
+		c.Error(err)
+		return
+	}
+
+	_ = send("done", summary)
+}
+
+// summarizeMessage fetches, converts, summarizes and stores a message with
+// the given prompt profile, reporting each stage to progress and each
+// produced token to onToken.
+func (h *Handler) summarizeMessage(c *gin.Context, user *model.User, id string, prefs *model.Preferences, profile model.PromptProfile, progress func(stage string), onToken summarizer.TokenFunc) (*model.Summary, error) {
+	ctx := c.Request.Context()
+
+	progress(stageFetch)
+	client, err := h.client(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *gmail.Message
+	err = client.Call(ctx, "messages.get", func() error {
+		var err error
+		m, err = client.Service.Users.Messages.Get(mailbox.User, id).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, apierr.Gmail(err)
+	}
+
+	progress(stageConvert)
+	msg, err := mailbox.ToModel(user.ID, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.messages != nil {
+		if err := h.messages.SaveMessage(ctx, msg); err != nil {
+			return nil, err
+		}
+		if err := h.indexMessage(ctx, msg); err != nil {
+			c.Error(err)
+		}
+	}
+
+	instructions, err := summarizer.Render(profile, prefs, msg.Subject, msg.Sender)
+	if err != nil {
+		return nil, err
+	}
+
+	progress(stageSummarize)
+	res, err := summarizer.Stream(ctx, h.summarizer, summarizer.Request{
+		Instructions: instructions,
+		Content:      msg.Markdown,
+	}, onToken)
+	if err != nil {
+		return nil, apierr.Wrap(err, apierr.Upstream, "failed to summarize message")
+	}
+
+	// A client that went away mid-stream must not leave a partial summary.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	summary := &model.Summary{
+		UserID:    user.ID,
+		MessageID: msg.ID,
+		Text:      res.Text,
+		Model:     h.summarizer.Model(),
+		Profile:   profile.Name,
+	}
+	if h.summaries != nil {
+		if summary, err = h.summaries.SaveSummary(ctx, summary); err != nil {
+			return nil, err
+		}
+	}
+
+	// The summary is already stored, so a failing post-summary action is
+	// recorded on the context instead of failing the request.
+	if err := h.applyActions(ctx, client, user, m); err != nil {
+		c.Error(err)
+	}
+
+	return summary, nil
+}
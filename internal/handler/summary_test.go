@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/summarizer"
+)
+
+// cancellingSummarizer cancels the request after emitting its first token.
+type cancellingSummarizer struct {
+	cancel context.CancelFunc
+}
+
+func (s *cancellingSummarizer) Model() string { return "cancelling" }
+
+func (s *cancellingSummarizer) Summarize(ctx context.Context, req summarizer.Request) (*summarizer.Response, error) {
+	return &summarizer.Response{Text: "partial"}, nil
+}
+
+func (s *cancellingSummarizer) SummarizeStream(ctx context.Context, req summarizer.Request, onToken summarizer.TokenFunc) (*summarizer.Response, error) {
+	if err := onToken("partial"); err != nil {
+		return nil, err
+	}
+	s.cancel()
+	return &summarizer.Response{Text: "partial"}, nil
+}
+
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+func readEvents(t *testing.T, body string) []sseEvent {
+	var events []sseEvent
+	var current sseEvent
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.Event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.Data = strings.TrimPrefix(line, "data:")
+		case line == "":
+			if current.Event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func setupSummaryTest(fg *fakeGmail, s summarizer.Summarizer) (*httptest.ResponseRecorder, *gin.Engine, *database.Memory) {
+	w, router, db, mockStore, mockProvider, mockAuthenticator := setupBaseTest()
+
+	h := New(db, mockStore, &config.Config{}, mockProvider, mockAuthenticator,
+		WithGmailClient(fg.client()), WithSummarizer(s), WithMessageStore(db), WithSummaryStore(db))
+
+	router.Use(func(c *gin.Context) {
+		middleware.SetUser(c, &model.User{ID: "user-123"})
+	})
+	router.GET("/messages/:id/summary", h.MessageSummary)
+	router.GET("/messages/:id/summary/stream", h.StreamMessageSummary)
+
+	return w, router, db
+}
+
+// latestSummary returns the stored summary of msg-1, or nil.
+func latestSummary(t *testing.T, db *database.Memory) *model.Summary {
+	t.Helper()
+	summary, err := db.LatestSummary(context.Background(), "user-123", "msg-1")
+	require.NoError(t, err)
+	return summary
+}
+
+func TestHandler_StreamMessageSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Streams progress, tokens and the stored summary", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+		})
+		w, router, db := setupSummaryTest(fg, summarizer.NewOffline(1))
+
+		req, _ := http.NewRequest(http.MethodGet, "/messages/msg-1/summary/stream", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/event-stream")
+
+		events := readEvents(t, w.Body.String())
+		require.NotEmpty(t, events)
+
+		var stages, tokens []string
+		for _, e := range events {
+			switch e.Event {
+			case "progress":
+				stages = append(stages, e.Data)
+			case "token":
+				tokens = append(tokens, e.Data)
+			}
+		}
+		assert.Equal(t, []string{`{"stage":"fetch"}`, `{"stage":"convert"}`, `{"stage":"summarize"}`}, stages)
+		assert.Len(t, tokens, 8)
+		assert.Equal(t, `{"text":"Hi,"}`, tokens[0])
+
+		msg, err := db.FindMessage(context.Background(), "user-123", "msg-1")
+		require.NoError(t, err)
+		require.NotNil(t, msg)
+		assert.Equal(t, "Invoice", msg.Subject)
+
+		summary := latestSummary(t, db)
+		require.NotNil(t, summary)
+		assert.Equal(t, "Hi, can you send the invoice by Friday?", summary.Text)
+		assert.Equal(t, "offline", summary.Model)
+
+		last := events[len(events)-1]
+		assert.Equal(t, "done", last.Event)
+		assert.Contains(t, last.Data, `"id":"`+summary.ID+`"`)
+	})
+
+	t.Run("Client cancellation does not persist the summary", func(t *testing.T) {
+		fg := newFakeGmail(t, map[string]http.HandlerFunc{
+			"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w, router, db := setupSummaryTest(fg, &cancellingSummarizer{cancel})
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/messages/msg-1/summary/stream", nil)
+		router.ServeHTTP(w, req)
+
+		events := readEvents(t, w.Body.String())
+		for _, e := range events {
+			assert.NotEqual(t, "done", e.Event)
+			assert.NotEqual(t, "error", e.Event)
+		}
+		assert.Nil(t, latestSummary(t, db))
+	})
+
+	t.Run("Gmail failure emits an error event", func(t *testing.T) {
+		fg := newFakeGmail(t, nil)
+		w, router, db := setupSummaryTest(fg, summarizer.NewOffline(1))
+
+		req, _ := http.NewRequest(http.MethodGet, "/messages/msg-1/summary/stream", nil)
+		router.ServeHTTP(w, req)
+
+		events := readEvents(t, w.Body.String())
+		require.NotEmpty(t, events)
+		assert.Equal(t, "error", events[len(events)-1].Event)
+		assert.Nil(t, latestSummary(t, db))
+	})
+}
+
+func TestHandler_MessageSummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fg := newFakeGmail(t, map[string]http.HandlerFunc{
+		"GET /gmail/v1/users/me/messages/msg-1": writeJSON(originalMessage()),
+	})
+	w, router, db := setupSummaryTest(fg, summarizer.NewOffline(1))
+
+	req, _ := http.NewRequest(http.MethodGet, "/messages/msg-1/summary", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	summary := latestSummary(t, db)
+	require.NotNil(t, summary)
+	assert.Contains(t, w.Body.String(), `"id":"`+summary.ID+`"`)
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each check so a hanging dependency cannot hang the
+// probe.
+const DefaultTimeout = 2 * time.Second
+
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusDegraded means an optional dependency failed; the service still
+	// takes traffic.
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Check is a single readiness check. It reports a problem by returning an
+// error.
+type Check struct {
+	Name string
+	// Optional checks degrade the status instead of failing readiness.
+	Optional bool
+	Func     func(ctx context.Context) error
+}
+
+// Result is the outcome of a check. The probe is unauthenticated, so errors
+// are logged rather than reported.
+type Result struct {
+	Name       string  `json:"name"`
+	Status     Status  `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// Report is the outcome of every check.
+type Report struct {
+	Status     Status   `json:"status"`
+	DurationMs float64  `json:"durationMs"`
+	Checks     []Result `json:"checks"`
+}
+
+// Checker runs readiness checks concurrently.
+type Checker struct {
+	timeout time.Duration
+	checks  []Check
+}
+
+// NewChecker creates a new Checker giving each check up to timeout, or
+// DefaultTimeout when zero.
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout, checks: checks}
+}
+
+// Add registers a check.
+func (c *Checker) Add(check Check) {
+	c.checks = append(c.checks, check)
+}
+
+// Run runs every check and reports them in the order they were added. The
+// report is down when a required check failed and degraded when only
+// optional ones did.
+func (c *Checker) Run(ctx context.Context) Report {
+	start := time.Now()
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: results}
+	for i, res := range results {
+		switch {
+		case res.Status == StatusOK:
+		case c.checks[i].Optional:
+			if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		default:
+			report.Status = StatusDown
+		}
+	}
+	report.DurationMs = milliseconds(time.Since(start))
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Func(ctx)
+	res := Result{Name: check.Name, Status: StatusOK, DurationMs: milliseconds(time.Since(start))}
+	if err != nil {
+		res.Status = StatusDown
+		if check.Optional {
+			res.Status = StatusDegraded
+		}
+		slog.WarnContext(ctx, "readiness check failed",
+			slog.String("check", check.Name),
+			slog.String("status", string(res.Status)),
+			slog.Any("error", err))
+	}
+	return res
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ok(ctx context.Context) error { return nil }
+
+func fail(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestChecker_Run(t *testing.T) {
+	t.Run("All checks pass", func(t *testing.T) {
+		report := NewChecker(0, Check{Name: "database", Func: ok}, Check{Name: "summarizer", Optional: true, Func: ok}).Run(context.Background())
+
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Equal(t, []string{"database", "summarizer"}, names(report))
+	})
+
+	t.Run("Optional failure degrades", func(t *testing.T) {
+		report := NewChecker(0, Check{Name: "database", Func: ok}, Check{Name: "summarizer", Optional: true, Func: fail}).Run(context.Background())
+
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.Equal(t, StatusDegraded, report.Checks[1].Status)
+	})
+
+	t.Run("Required failure is down", func(t *testing.T) {
+		report := NewChecker(0, Check{Name: "database", Func: fail}, Check{Name: "summarizer", Optional: true, Func: fail}).Run(context.Background())
+
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusDown, report.Checks[0].Status)
+	})
+
+	t.Run("Hanging checks time out", func(t *testing.T) {
+		c := NewChecker(10 * time.Millisecond)
+		c.Add(Check{Name: "sessions", Func: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+
+		report := c.Run(context.Background())
+		assert.Equal(t, StatusDown, report.Status)
+		assert.GreaterOrEqual(t, report.Checks[0].DurationMs, 10.0)
+	})
+}
+
+func names(r Report) []string {
+	var names []string
+	for _, c := range r.Checks {
+		names = append(names, c.Name)
+	}
+	return names
+}
@@ -0,0 +1,271 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Component is a long running part of the process such as the HTTP server, a
+// background worker or a scheduler.
+type Component interface {
+	Name() string
+	// Run blocks until the component fails or ctx is cancelled. It calls
+	// ready once the component takes work, such as when the server listens.
+	Run(ctx context.Context, ready func()) error
+	// Shutdown drains the component, giving up when ctx is done.
+	Shutdown(ctx context.Context) error
+}
+
+// Manager runs components until the process is asked to stop or one of them
+// fails, then shuts them down and releases shared resources.
+type Manager struct {
+	timeout    time.Duration
+	components []Component
+	closers    []closer
+}
+
+type closer struct {
+	name string
+	fn   func() error
+}
+
+// New creates a new Manager allowing timeout for the whole shutdown. A zero
+// timeout waits for as long as the components need.
+func New(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// Add registers a component. Components are started in the order they are
+// added, each once the previous one is ready, and shut down in reverse, so
+// the HTTP server is added last to take work only after the workers it feeds
+// are open and to stop taking it before they are drained.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// OnClose registers a resource released after every component stopped, in
+// the order they are registered, even when the shutdown timed out.
+func (m *Manager) OnClose(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name, fn})
+}
+
+// Run starts the components and blocks until ctx is cancelled or one of them
+// fails, then shuts down those that were started. It returns the failure, if
+// any, joined with the errors of the shutdown.
+func (m *Manager) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	failed := make(chan error, len(m.components))
+	var (
+		wg       sync.WaitGroup
+		started  int
+		failure  error
+		stopping bool
+	)
+	for _, c := range m.components {
+		ready := make(chan struct{})
+		var once sync.Once
+		markReady := func() { once.Do(func() { close(ready) }) }
+		wg.Add(1)
+		started++
+		go func() {
+			defer wg.Done()
+			// A component returning early never blocks the next one.
+			defer markReady()
+			if err := c.Run(runCtx, markReady); err != nil {
+				failed <- fmt.Errorf("%s: %w", c.Name(), err)
+			}
+		}()
+
+		select {
+		case <-ready:
+			continue
+		case <-ctx.Done():
+		case failure = <-failed:
+		}
+		stopping = true
+		break
+	}
+	if !stopping {
+		select {
+		case <-ctx.Done():
+		case failure = <-failed:
+		}
+	}
+
+	var errs []error
+	if failure != nil {
+		slog.Error("shutting down", slog.Any("error", failure))
+		errs = append(errs, failure)
+	} else {
+		slog.Info("shutting down", slog.Duration("timeout", m.timeout))
+	}
+
+	shutdownCtx := context.Background()
+	if m.timeout > 0 {
+		var cancelShutdown context.CancelFunc
+		shutdownCtx, cancelShutdown = context.WithTimeout(shutdownCtx, m.timeout)
+		defer cancelShutdown()
+	}
+
+	for i := started - 1; i >= 0; i-- {
+		c := m.components[i]
+		if err := c.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", c.Name(), err))
+		}
+	}
+
+	// Components still running past the deadline are abandoned; the
+	// resources below are released regardless.
+	cancel()
+	if !wait(shutdownCtx, &wg) {
+		errs = append(errs, fmt.Errorf("shutdown: %w", shutdownCtx.Err()))
+	}
+
+	for _, c := range m.closers {
+		if err := c.fn(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wait reports whether wg finished before ctx was done.
+func wait(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// HTTPServer runs srv until it is shut down. In-flight requests are drained
+// on shutdown and connections still open at the deadline are closed.
+func HTTPServer(srv *http.Server) Component {
+	return &httpServer{srv}
+}
+
+type httpServer struct {
+	srv *http.Server
+}
+
+func (s *httpServer) Name() string {
+	return "http server"
+}
+
+func (s *httpServer) Run(ctx context.Context, ready func()) error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("starting server", slog.String("addr", s.srv.Addr))
+	ready()
+	if err := s.srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+		return err
+	}
+	return nil
+}
+
+// Worker is a component for work started elsewhere, such as backfills
+// started from the API. It has nothing to run: open receives the context
+// that work must be started from, and the worker drains on shutdown.
+func Worker(name string, open func(ctx context.Context), shutdown func(ctx context.Context) error) Component {
+	return &worker{name, open, shutdown}
+}
+
+type worker struct {
+	name     string
+	open     func(ctx context.Context)
+	shutdown func(ctx context.Context) error
+}
+
+func (w *worker) Name() string {
+	return w.name
+}
+
+func (w *worker) Run(ctx context.Context, ready func()) error {
+	w.open(ctx)
+	ready()
+	<-ctx.Done()
+	return nil
+}
+
+func (w *worker) Shutdown(ctx context.Context) error {
+	return w.shutdown(ctx)
+}
+
+// Scheduler is a component for a goroutine controlled by a pair of quit and
+// done channels, such as the pgstore cleanup. start launches the goroutine
+// and stop signals quit and waits on done.
+func Scheduler(name string, start func() (chan<- struct{}, <-chan struct{}), stop func(chan<- struct{}, <-chan struct{})) Component {
+	return &scheduler{name: name, start: start, stop: stop, started: make(chan struct{})}
+}
+
+type scheduler struct {
+	name  string
+	start func() (chan<- struct{}, <-chan struct{})
+	stop  func(chan<- struct{}, <-chan struct{})
+
+	started chan struct{}
+	quit    chan<- struct{}
+	done    <-chan struct{}
+}
+
+func (s *scheduler) Name() string {
+	return s.name
+}
+
+func (s *scheduler) Run(ctx context.Context, ready func()) error {
+	s.quit, s.done = s.start()
+	close(s.started)
+	ready()
+	<-ctx.Done()
+	return nil
+}
+
+func (s *scheduler) Shutdown(ctx context.Context) error {
+	select {
+	case <-s.started:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.stop(s.quit, s.done)
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,237 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects the order of lifecycle events.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type fakeComponent struct {
+	name    string
+	rec     *recorder
+	err     error
+	drain   time.Duration
+	hold    chan struct{}
+	running chan struct{}
+}
+
+func newFake(name string, rec *recorder) *fakeComponent {
+	return &fakeComponent{name: name, rec: rec, running: make(chan struct{})}
+}
+
+func (c *fakeComponent) Name() string { return c.name }
+
+func (c *fakeComponent) Run(ctx context.Context, ready func()) error {
+	c.rec.add("start " + c.name)
+	close(c.running)
+	if c.err != nil {
+		return c.err
+	}
+	if c.hold != nil {
+		<-c.hold
+	}
+	ready()
+	<-ctx.Done()
+	return nil
+}
+
+func (c *fakeComponent) Shutdown(ctx context.Context) error {
+	select {
+	case <-time.After(c.drain):
+		c.rec.add("stop " + c.name)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestManager_Run(t *testing.T) {
+	t.Run("Stops in reverse order then closes in order", func(t *testing.T) {
+		rec := &recorder{}
+		m := New(time.Second)
+		worker, srv := newFake("worker", rec), newFake("server", rec)
+		m.Add(worker)
+		m.Add(srv)
+		m.OnClose("sessions", func() error { rec.add("close sessions"); return nil })
+		m.OnClose("database", func() error { rec.add("close database"); return nil })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-worker.running
+			<-srv.running
+			cancel()
+		}()
+
+		require.NoError(t, m.Run(ctx))
+		assert.Equal(t, []string{"start worker", "start server", "stop server", "stop worker", "close sessions", "close database"}, rec.get())
+	})
+
+	t.Run("Starts each component once the previous one is ready", func(t *testing.T) {
+		rec := &recorder{}
+		m := New(time.Second)
+		worker, srv := newFake("worker", rec), newFake("server", rec)
+		worker.hold = make(chan struct{})
+		m.Add(worker)
+		m.Add(srv)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- m.Run(ctx) }()
+
+		<-worker.running
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, []string{"start worker"}, rec.get())
+
+		close(worker.hold)
+		<-srv.running
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("Does not start components after a failed one", func(t *testing.T) {
+		rec := &recorder{}
+		m := New(time.Second)
+		failing := newFake("worker", rec)
+		failing.err = errors.New("boom")
+		m.Add(failing)
+		m.Add(newFake("server", rec))
+
+		err := m.Run(context.Background())
+		assert.ErrorContains(t, err, "worker: boom")
+		assert.Equal(t, []string{"start worker", "stop worker"}, rec.get())
+	})
+
+	t.Run("Shuts down when a component fails", func(t *testing.T) {
+		rec := &recorder{}
+		m := New(time.Second)
+		failing := newFake("server", rec)
+		failing.err = errors.New("address already in use")
+		m.Add(newFake("worker", rec))
+		m.Add(failing)
+
+		err := m.Run(context.Background())
+		assert.ErrorContains(t, err, "server: address already in use")
+		assert.Contains(t, rec.get(), "stop worker")
+	})
+
+	t.Run("Closes resources after the deadline", func(t *testing.T) {
+		rec := &recorder{}
+		m := New(20 * time.Millisecond)
+		slow := newFake("worker", rec)
+		slow.drain = time.Minute
+		m.Add(slow)
+		m.OnClose("database", func() error { rec.add("close database"); return nil })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-slow.running
+			cancel()
+		}()
+
+		err := m.Run(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "shutdown worker")
+		assert.Equal(t, []string{"start worker", "close database"}, rec.get())
+	})
+}
+
+func TestHTTPServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})}
+
+	m := New(time.Second)
+	m.Add(HTTPServer(srv))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- m.Run(ctx) }()
+
+	// Wait for the server to accept connections.
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		res, err := http.Get("http://" + addr)
+		assert.NoError(t, err)
+		responses <- res
+	}()
+
+	<-started
+	cancel()
+	// The in-flight request is drained before Run returns.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-done)
+	res := <-responses
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	res.Body.Close()
+}
+
+func TestScheduler(t *testing.T) {
+	rec := &recorder{}
+	s := Scheduler("cleanup",
+		func() (chan<- struct{}, <-chan struct{}) {
+			quit, done := make(chan struct{}), make(chan struct{})
+			go func() {
+				<-quit
+				rec.add("cleanup stopped")
+				close(done)
+			}()
+			return quit, done
+		},
+		func(quit chan<- struct{}, done <-chan struct{}) {
+			quit <- struct{}{}
+			<-done
+		})
+
+	m := New(time.Second)
+	m.Add(s)
+	m.OnClose("database", func() error { rec.add("close database"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, m.Run(ctx))
+	assert.Equal(t, []string{"cleanup stopped", "close database"}, rec.get())
+}
@@ -0,0 +1,89 @@
+// Package logging configures structured JSON logging with log/slog. Values
+// of sensitive attributes are redacted and attributes stored in a context,
+// such as the request and user ids, are added to every record logged with
+// it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitive are attribute keys whose values are never logged: credentials
+// and message content.
+var sensitive = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"password":      true,
+	"secret":        true,
+	"body":          true,
+	"markdown":      true,
+	"html":          true,
+	"raw":           true,
+	"content":       true,
+}
+
+// New creates a JSON logger writing records at level or above to w.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(&contextHandler{h})
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("must be debug, info, warn or error, got %q", s)
+	}
+	return level, nil
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(strings.ReplaceAll(a.Key, "-", "_"))
+	if sensitive[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret") {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+type ctxKey struct{}
+
+// With returns a context whose records carry attrs in addition to the
+// attributes already in ctx.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	all := make([]slog.Attr, 0, len(prev)+len(attrs))
+	return context.WithValue(ctx, ctxKey{}, append(append(all, prev...), attrs...))
+}
+
+// contextHandler adds the attributes of the record context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
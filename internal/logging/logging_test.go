@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/model"
+)
+
+func record(t *testing.T, buf *bytes.Buffer) map[string]any {
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+	buf.Reset()
+	return rec
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	t.Run("Redacts sensitive attributes", func(t *testing.T) {
+		logger.Info("refreshed",
+			slog.String("access_token", "ya29.secret"),
+			slog.String("refresh_token", "1//secret"),
+			slog.String("Authorization", "Bearer x"),
+			slog.String("markdown", "Dear Alice"),
+			slog.Group("gmail", slog.String("body", "Hello")),
+			slog.String("method", "messages.get"),
+		)
+
+		rec := record(t, &buf)
+		assert.Equal(t, Redacted, rec["access_token"])
+		assert.Equal(t, Redacted, rec["refresh_token"])
+		assert.Equal(t, Redacted, rec["Authorization"])
+		assert.Equal(t, Redacted, rec["markdown"])
+		assert.Equal(t, map[string]any{"body": Redacted}, rec["gmail"])
+		assert.Equal(t, "messages.get", rec["method"])
+	})
+
+	t.Run("Logs models without tokens or content", func(t *testing.T) {
+		logger.Info("user",
+			slog.Any("user", &model.User{ID: "user-123", Email: "a@example.com", AccessToken: "ya29.secret"}),
+			slog.Any("message", &model.Message{ID: "msg-1", Markdown: "Dear Alice"}),
+		)
+
+		out := buf.String()
+		rec := record(t, &buf)
+		assert.Equal(t, map[string]any{"id": "user-123"}, rec["user"])
+		assert.NotContains(t, out, "secret")
+		assert.NotContains(t, out, "Alice")
+	})
+
+	t.Run("Adds context attributes", func(t *testing.T) {
+		ctx := With(context.Background(), slog.String("request_id", "req-1"))
+		ctx = With(ctx, slog.String("user_id", "user-123"))
+		logger.InfoContext(ctx, "request")
+
+		rec := record(t, &buf)
+		assert.Equal(t, "req-1", rec["request_id"])
+		assert.Equal(t, "user-123", rec["user_id"])
+	})
+
+	t.Run("Honours the level", func(t *testing.T) {
+		logger.Debug("hidden")
+		assert.Zero(t, buf.Len())
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,337 @@
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/internal/metrics"
+	"main/internal/model"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// DefaultEndpoint is the Gmail API root.
+const DefaultEndpoint = "https://gmail.googleapis.com/"
+
+// MaxBatchSize is the largest number of calls Gmail accepts in one batch.
+const MaxBatchSize = 100
+
+// Client wraps a Gmail service with per-user quota limiting, retries and
+// batched message fetching.
+type Client struct {
+	// Service is the underlying Gmail service. Calls made on it directly
+	// should go through Call.
+	Service *gmail.Service
+
+	http     *http.Client
+	endpoint string
+	userID   string
+	limiter  *Limiter
+	backoff  Backoff
+}
+
+// ClientFunc builds a Gmail client authorised as the given user.
+type ClientFunc func(ctx context.Context, u *model.User) (*Client, error)
+
+// NewClient creates a client sending requests through httpClient to
+// endpoint, or DefaultEndpoint when empty. limiter may be nil to disable
+// quota limiting.
+func NewClient(ctx context.Context, httpClient *http.Client, endpoint, userID string, limiter *Limiter, backoff Backoff) (*Client, error) {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+
+	svc, err := gmail.NewService(ctx, option.WithEndpoint(endpoint), option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		Service:  svc,
+		http:     httpClient,
+		endpoint: endpoint,
+		userID:   userID,
+		limiter:  limiter,
+		backoff:  backoff,
+	}, nil
+}
+
+// TokenStore saves the tokens of a user refreshed by a client.
+type TokenStore interface {
+	UpdateUserTokens(ctx context.Context, userID, accessToken, refreshToken string, tokenExpiry time.Time) error
+}
+
+// NewClientFunc returns a ClientFunc using the user's stored OAuth tokens,
+// refreshed with oauth once they expire and saved to tokens, and sharing
+// limiter between every client it creates.
+func NewClientFunc(oauth *oauth2.Config, tokens TokenStore, limiter *Limiter, backoff Backoff) ClientFunc {
+	return func(ctx context.Context, u *model.User) (*Client, error) {
+		return NewClient(ctx, oauthClient(ctx, oauth, tokens, u), "", u.ID, limiter, backoff)
+	}
+}
+
+// Call charges the quota of method and runs fn, retrying rate limited and
+// server errors with backoff.
+func (c *Client) Call(ctx context.Context, method string, fn func() error) error {
+	return Retry(ctx, c.backoff, func() error {
+		if err := c.wait(ctx, QuotaCost(method)); err != nil {
+			return err
+		}
+		return fn()
+	})
+}
+
+func (c *Client) wait(ctx context.Context, units int) error {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.Wait(ctx, c.userID, units)
+}
+
+// MessageResult is the outcome of fetching one message in a batch.
+type MessageResult struct {
+	Message *gmail.Message
+	Err     error
+}
+
+// GetMessages fetches messages in the given format, with only
+// metadataHeaders in the metadata format, using the batch endpoint,
+// MaxBatchSize at a time. Results are in the order of ids;
+// messages that fail are reported in their result rather than failing the
+// whole call, and rate limited ones are retried with backoff.
+func (c *Client) GetMessages(ctx context.Context, ids []string, format string, metadataHeaders ...string) ([]MessageResult, error) {
+	results := make([]MessageResult, len(ids))
+
+	query := url.Values{}
+	if format != "" {
+		query.Set("format", format)
+	}
+	for _, h := range metadataHeaders {
+		query.Add("metadataHeaders", h)
+	}
+
+	for start := 0; start < len(ids); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(ids))
+		if err := c.getBatch(ctx, ids[start:end], query, results[start:end]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// getBatch fills results for ids, resending only the calls that failed with
+// a retryable error. Calls still failing once the attempts run out keep
+// their error in results; only a failure of the batch itself is returned.
+func (c *Client) getBatch(ctx context.Context, ids []string, query url.Values, results []MessageResult) error {
+	pending := make([]int, len(ids))
+	for i := range ids {
+		pending[i] = i
+	}
+
+	var partial bool
+	err := Retry(ctx, c.backoff, func() error {
+		partial = false
+		if err := c.wait(ctx, QuotaCost("messages.get")*len(pending)); err != nil {
+			return err
+		}
+
+		calls := make([]string, len(pending))
+		for i, idx := range pending {
+			calls[i] = ids[idx]
+		}
+		got, err := c.batchGet(ctx, calls, query)
+		if err != nil {
+			return err
+		}
+
+		var retry []int
+		var retryErr error
+		for i, idx := range pending {
+			results[idx] = got[i]
+			if got[i].Err != nil && IsRetryable(got[i].Err) {
+				retry = append(retry, idx)
+				retryErr = got[i].Err
+			}
+		}
+		pending = retry
+		partial = retryErr != nil
+		return retryErr
+	})
+	if partial {
+		return nil
+	}
+	return err
+}
+
+// batchGet sends one multipart batch of Messages.Get calls.
+func (c *Client) batchGet(ctx context.Context, ids []string, query url.Values) ([]MessageResult, error) {
+	base, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, id := range ids {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<item" + strconv.Itoa(i) + ">"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		path := base.JoinPath("gmail/v1/users", User, "messages", url.PathEscape(id)).EscapedPath()
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+		fmt.Fprintf(part, "GET %s HTTP/1.1\r\n\r\n", path)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base.JoinPath("batch/gmail/v1").String(), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	return parseBatch(res, len(ids))
+}
+
+// parseBatch reads a multipart batch response, matching parts to calls by
+// their Content-ID.
+func parseBatch(res *http.Response, n int) ([]MessageResult, error) {
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("unexpected batch response type %q", res.Header.Get("Content-Type"))
+	}
+
+	results := make([]MessageResult, n)
+	seen := make([]bool, n)
+
+	mr := multipart.NewReader(res.Body, params["boundary"])
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		idx := i
+		id := strings.Trim(part.Header.Get("Content-Id"), "<>")
+		if v, ok := strings.CutPrefix(id, "response-item"); ok {
+			if parsed, err := strconv.Atoi(v); err == nil {
+				idx = parsed
+			}
+		}
+		if idx < 0 || idx >= n {
+			continue
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(part), nil)
+		if err != nil {
+			return nil, err
+		}
+		results[idx] = readMessage(resp)
+		seen[idx] = true
+	}
+
+	for i := range results {
+		if !seen[i] {
+			results[i].Err = &googleapi.Error{Code: http.StatusInternalServerError, Message: "missing from batch response"}
+		}
+	}
+	return results, nil
+}
+
+func readMessage(resp *http.Response) MessageResult {
+	defer resp.Body.Close()
+
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return MessageResult{Err: err}
+	}
+
+	var m gmail.Message
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return MessageResult{Err: err}
+	}
+	return MessageResult{Message: &m}
+}
+
+// oauthClient returns an HTTP client authorised with the user's tokens. A
+// client may outlive ctx, as a backfill keeps using it after the request
+// that started it, so tokens are refreshed without its cancellation.
+func oauthClient(ctx context.Context, oauth *oauth2.Config, tokens TokenStore, u *model.User) *http.Client {
+	ctx = context.WithoutCancel(ctx)
+	token := &oauth2.Token{
+		AccessToken:  u.AccessToken,
+		RefreshToken: u.RefreshToken,
+		Expiry:       u.TokenExpiry,
+		TokenType:    "Bearer",
+	}
+	src := oauth2.ReuseTokenSource(token, &savingTokenSource{
+		ctx:    ctx,
+		src:    oauth.TokenSource(ctx, &oauth2.Token{RefreshToken: u.RefreshToken}),
+		tokens: tokens,
+		userID: u.ID,
+	})
+	return instrument(oauth2.NewClient(ctx, src))
+}
+
+// savingTokenSource saves every token refreshed by src. It is only called
+// once the previous token expired.
+type savingTokenSource struct {
+	ctx    context.Context
+	src    oauth2.TokenSource
+	tokens TokenStore
+	userID string
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.src.Token()
+	if err != nil {
+		metrics.TokenRefreshes.WithLabelValues("provider_error").Inc()
+		return nil, err
+	}
+
+	// The refreshed token is still good for this client when it cannot be
+	// saved; the next one refreshes again.
+	if err := s.tokens.UpdateUserTokens(s.ctx, s.userID, token.AccessToken, token.RefreshToken, token.Expiry); err != nil {
+		metrics.TokenRefreshes.WithLabelValues("store_error").Inc()
+		return token, nil
+	}
+	metrics.TokenRefreshes.WithLabelValues("success").Inc()
+	return token, nil
+}
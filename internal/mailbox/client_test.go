@@ -0,0 +1,279 @@
+package mailbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+
+	"main/internal/model"
+)
+
+var fastBackoff = Backoff{Base: time.Millisecond, Max: 5 * time.Millisecond, Attempts: 3}
+
+// batchServer answers Gmail batch requests with handle, which returns the
+// status and body of each Messages.Get call.
+func batchServer(t *testing.T, handle func(id string) (int, string)) (*httptest.Server, *[]int) {
+	var mu sync.Mutex
+	var sizes []int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/batch/gmail/v1", r.URL.Path)
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+
+		// The whole request is read before answering, as the server
+		// closes the request body once the response starts.
+		type reply struct {
+			cid    string
+			status int
+			body   string
+		}
+		var replies []reply
+
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			require.NoError(t, err)
+			assert.Equal(t, "full", req.URL.Query().Get("format"))
+
+			id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+			status, body := handle(id)
+			replies = append(replies, reply{"<response-" + strings.Trim(part.Header.Get("Content-Id"), "<>") + ">", status, body})
+		}
+
+		mu.Lock()
+		sizes = append(sizes, len(replies))
+		mu.Unlock()
+
+		out := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+out.Boundary())
+		for _, rep := range replies {
+			pw, err := out.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/http"}, "Content-Id": {rep.cid}})
+			require.NoError(t, err)
+			fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", rep.status, http.StatusText(rep.status), len(rep.body), rep.body)
+		}
+		require.NoError(t, out.Close())
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &sizes
+}
+
+func TestClient_GetMessages(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Splits into batches and keeps order", func(t *testing.T) {
+		srv, sizes := batchServer(t, func(id string) (int, string) {
+			return http.StatusOK, fmt.Sprintf(`{"id":%q}`, id)
+		})
+		c, err := NewClient(ctx, srv.Client(), srv.URL, "user-123", nil, fastBackoff)
+		require.NoError(t, err)
+
+		ids := make([]string, 150)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("msg-%d", i)
+		}
+
+		results, err := c.GetMessages(ctx, ids, "full")
+		require.NoError(t, err)
+		require.Len(t, results, 150)
+		for i, r := range results {
+			require.NoError(t, r.Err)
+			assert.Equal(t, ids[i], r.Message.Id)
+		}
+		assert.Equal(t, []int{100, 50}, *sizes)
+	})
+
+	t.Run("Retries rate limited calls and reports other failures", func(t *testing.T) {
+		var mu sync.Mutex
+		limited := true
+		srv, sizes := batchServer(t, func(id string) (int, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case id == "busy" && limited:
+				limited = false
+				return http.StatusTooManyRequests, `{"error":{"code":429,"message":"Too many concurrent requests for user"}}`
+			case id == "gone":
+				return http.StatusNotFound, `{"error":{"code":404,"message":"Requested entity was not found."}}`
+			}
+			return http.StatusOK, fmt.Sprintf(`{"id":%q}`, id)
+		})
+		c, err := NewClient(ctx, srv.Client(), srv.URL, "user-123", nil, fastBackoff)
+		require.NoError(t, err)
+
+		results, err := c.GetMessages(ctx, []string{"a", "busy", "gone"}, "full")
+		require.NoError(t, err)
+
+		assert.Equal(t, "a", results[0].Message.Id)
+		assert.Equal(t, "busy", results[1].Message.Id)
+		var gerr *googleapi.Error
+		require.True(t, errors.As(results[2].Err, &gerr))
+		assert.Equal(t, http.StatusNotFound, gerr.Code)
+		// Only the rate limited call is sent again.
+		assert.Equal(t, []int{3, 1}, *sizes)
+	})
+
+	t.Run("Reports calls still rate limited after every attempt", func(t *testing.T) {
+		srv, sizes := batchServer(t, func(id string) (int, string) {
+			if id == "busy" {
+				return http.StatusTooManyRequests, `{"error":{"code":429,"message":"Too many concurrent requests for user"}}`
+			}
+			return http.StatusOK, fmt.Sprintf(`{"id":%q}`, id)
+		})
+		c, err := NewClient(ctx, srv.Client(), srv.URL, "user-123", nil, fastBackoff)
+		require.NoError(t, err)
+
+		results, err := c.GetMessages(ctx, []string{"a", "busy"}, "full")
+		require.NoError(t, err)
+
+		assert.Equal(t, "a", results[0].Message.Id)
+		var gerr *googleapi.Error
+		require.True(t, errors.As(results[1].Err, &gerr))
+		assert.Equal(t, http.StatusTooManyRequests, gerr.Code)
+		assert.Equal(t, []int{2, 1, 1}, *sizes)
+	})
+}
+
+// tokenStore records the tokens saved by a client.
+type tokenStore struct {
+	mu    sync.Mutex
+	saved []string
+}
+
+func (s *tokenStore) UpdateUserTokens(ctx context.Context, userID, accessToken, refreshToken string, tokenExpiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, userID+":"+accessToken+":"+refreshToken)
+	return nil
+}
+
+func TestOAuthClient(t *testing.T) {
+	var refreshes int
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			refreshes++
+			assert.Equal(t, "refresh", r.FormValue("refresh_token"))
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`))
+			return
+		}
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(srv.Close)
+
+	store := &tokenStore{}
+	oauth := &oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{TokenURL: srv.URL + "/token"}}
+	u := &model.User{ID: "user-123", AccessToken: "stale", RefreshToken: "refresh", TokenExpiry: time.Now().Add(-time.Minute)}
+
+	// The client outlives the request it was created for.
+	ctx, cancel := context.WithCancel(context.Background())
+	client := oauthClient(ctx, oauth, store, u)
+	cancel()
+
+	for range 2 {
+		res, err := client.Get(srv.URL + "/gmail/v1/users/me/profile")
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+
+	assert.Equal(t, 1, refreshes)
+	assert.Equal(t, []string{"Bearer fresh", "Bearer fresh"}, auth)
+	assert.Equal(t, []string{"user-123:fresh:refresh"}, store.saved)
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	calls := 0
+	err := Retry(ctx, fastBackoff, func() error {
+		calls++
+		return &googleapi.Error{Code: http.StatusServiceUnavailable}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Retry(ctx, fastBackoff, func() error {
+		calls++
+		return &googleapi.Error{Code: http.StatusBadRequest}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	assert.True(t, IsRetryable(&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}))
+	assert.False(t, IsRetryable(&googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}))
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(10, 20)
+	l.now = func() time.Time { return now }
+
+	assert.Zero(t, l.reserve("a", 20))
+	assert.Equal(t, 500*time.Millisecond, l.reserve("a", 5))
+	// Users have separate buckets.
+	assert.Zero(t, l.reserve("b", 20))
+
+	now = now.Add(time.Second)
+	assert.Zero(t, l.reserve("a", float64(QuotaCost("messages.get"))))
+	assert.Equal(t, 100, QuotaCost("messages.send"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.reserve("a", 20)
+	assert.ErrorIs(t, l.Wait(ctx, "a", 20), context.Canceled)
+
+	// Buckets back at the burst are dropped once a sweep is due.
+	now = now.Add(sweepInterval)
+	l.reserve("c", 1)
+	assert.Equal(t, []string{"c"}, slices.Collect(maps.Keys(l.buckets)))
+}
+
+func TestLimiter_ChargesMoreThanBurst(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(DefaultQuotaRate, DefaultQuotaBurst)
+	l.now = func() time.Time { return now }
+
+	// A batch of 100 Messages.Get costs 500 units, twice the burst. Once the
+	// bucket is empty it takes 2s at 250 units/s, and the next call waits
+	// behind it.
+	assert.Zero(t, l.reserve("a", DefaultQuotaBurst))
+	batch := float64(QuotaCost("messages.get") * MaxBatchSize)
+	assert.Equal(t, 2*time.Second, l.reserve("a", batch))
+	assert.Equal(t, 2*time.Second+20*time.Millisecond, l.reserve("a", 5))
+
+	now = now.Add(3 * time.Second)
+	assert.Zero(t, l.reserve("a", 5))
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := NewLimiter(1000, 10)
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(t, l.Wait(ctx, "a", 10))
+	require.NoError(t, l.Wait(ctx, "a", 100))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
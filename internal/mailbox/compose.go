@@ -0,0 +1,60 @@
+package mailbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Outgoing is a plain text message to be sent or saved as a draft.
+type Outgoing struct {
+	From       string
+	To         string
+	Subject    string
+	Body       string
+	InReplyTo  string
+	References string
+	Date       time.Time
+}
+
+// Bytes renders the message in RFC 2822 format with CRLF line endings.
+func (o *Outgoing) Bytes() []byte {
+	date := o.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var b bytes.Buffer
+	writeHeader(&b, "From", o.From)
+	writeHeader(&b, "To", o.To)
+	writeHeader(&b, "Subject", mime.QEncoding.Encode("utf-8", o.Subject))
+	writeHeader(&b, "Date", date.Format(time.RFC1123Z))
+	writeHeader(&b, "In-Reply-To", o.InReplyTo)
+	writeHeader(&b, "References", o.References)
+	writeHeader(&b, "MIME-Version", "1.0")
+	writeHeader(&b, "Content-Type", `text/plain; charset="UTF-8"`)
+	writeHeader(&b, "Content-Transfer-Encoding", "8bit")
+	b.WriteString("\r\n")
+
+	body := strings.ReplaceAll(o.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return b.Bytes()
+}
+
+// Raw returns the message encoded for the Gmail API raw field.
+func (o *Outgoing) Raw() string {
+	return base64.URLEncoding.EncodeToString(o.Bytes())
+}
+
+func writeHeader(b *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	// Header values must not smuggle in additional headers.
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	fmt.Fprintf(b, "%s: %s\r\n", name, value)
+}
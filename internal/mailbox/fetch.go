@@ -0,0 +1,119 @@
+package mailbox
+
+import (
+	"context"
+	"fmt"
+	"main/internal/model"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// DefaultFetchWorkers bounds concurrent conversions when none is configured.
+const DefaultFetchWorkers = 8
+
+// Stages of the fetch pipeline reported in a FetchError.
+const (
+	StageFetch   = "fetch"
+	StageConvert = "convert"
+)
+
+// FetchError reports the message and stage that failed.
+type FetchError struct {
+	ID    string
+	Stage string
+	Err   error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("%s message %s: %v", e.Stage, e.ID, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// FetchResult is the outcome of fetching and converting one message.
+type FetchResult struct {
+	ID      string
+	Message *model.Message
+	// Raw is the message as returned by Gmail.
+	Raw *gmail.Message
+	Err error
+}
+
+// toModel converts a fetched message; tests replace it to observe the
+// conversion workers.
+var toModel = ToModel
+
+// Fetch gets messages with batched calls of up to MaxBatchSize messages and
+// converts them using up to workers goroutines, converting a batch while the
+// next one is fetched. Results are in the order of ids and a failing message
+// only fails its own result. Once ctx is done, messages not yet fetched or
+// converted fail with its error.
+func Fetch(ctx context.Context, c *Client, userID string, ids []string, workers int) []FetchResult {
+	results := make([]FetchResult, len(ids))
+	if len(ids) == 0 {
+		return results
+	}
+	if workers <= 0 {
+		workers = DefaultFetchWorkers
+	}
+
+	raw := make([]*gmail.Message, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = convert(userID, ids[i], raw[i])
+			}
+		}()
+	}
+
+feed:
+	for start := 0; start < len(ids); start += MaxBatchSize {
+		if ctx.Err() != nil {
+			break
+		}
+		end := min(start+MaxBatchSize, len(ids))
+		got, err := c.GetMessages(ctx, ids[start:end], "full")
+
+		for i := start; i < end; i++ {
+			switch {
+			case err != nil:
+				results[i] = FetchResult{ID: ids[i], Err: &FetchError{ids[i], StageFetch, err}}
+				continue
+			case got[i-start].Err != nil:
+				results[i] = FetchResult{ID: ids[i], Err: &FetchError{ids[i], StageFetch, got[i-start].Err}}
+				continue
+			}
+
+			raw[i] = got[i-start].Message
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, r := range results {
+		if r.ID == "" {
+			results[i] = FetchResult{ID: ids[i], Err: &FetchError{ids[i], StageFetch, ctx.Err()}}
+		}
+	}
+	return results
+}
+
+func convert(userID, id string, m *gmail.Message) FetchResult {
+	msg, err := toModel(userID, m)
+	if err != nil {
+		return FetchResult{ID: id, Raw: m, Err: &FetchError{id, StageConvert, err}}
+	}
+	return FetchResult{ID: id, Message: msg, Raw: m}
+}
@@ -0,0 +1,114 @@
+package mailbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+
+	"main/internal/model"
+)
+
+// message answers a Messages.Get call of a batch with an HTML message, or
+// the failures the tests expect for the ids "missing" and "broken".
+func message(id string) (int, string) {
+	m := &gmail.Message{Id: id, Payload: &gmail.MessagePart{
+		MimeType: "text/html",
+		Headers:  []*gmail.MessagePartHeader{{Name: "Subject", Value: "Subject " + id}},
+		Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString([]byte("<p><b>" + id + "</b></p>"))},
+	}}
+	switch id {
+	case "missing":
+		return http.StatusNotFound, `{"error":{"code":404,"message":"Requested entity was not found."}}`
+	case "broken":
+		m.Payload.Body.Data = "not base64!"
+	}
+	b, _ := json.Marshal(m)
+	return http.StatusOK, string(b)
+}
+
+func TestFetch(t *testing.T) {
+	srv, sizes := batchServer(t, message)
+	c, err := NewClient(context.Background(), srv.Client(), srv.URL, "user-123", nil, fastBackoff)
+	require.NoError(t, err)
+
+	t.Run("Keeps order and batches calls", func(t *testing.T) {
+		*sizes = nil
+		ids := make([]string, 250)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("msg-%d", i)
+		}
+
+		results := Fetch(context.Background(), c, "user-123", ids, 2)
+		require.Len(t, results, len(ids))
+		for i, r := range results {
+			require.NoError(t, r.Err)
+			assert.Equal(t, ids[i], r.ID)
+			assert.Equal(t, "**"+ids[i]+"**", r.Message.Markdown)
+			assert.Equal(t, "user-123", r.Message.UserID)
+		}
+		assert.ElementsMatch(t, []int{100, 100, 50}, *sizes)
+	})
+
+	t.Run("Converts in parallel up to workers", func(t *testing.T) {
+		var inFlight, peak atomic.Int32
+		toModel = func(userID string, m *gmail.Message) (*model.Message, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return ToModel(userID, m)
+		}
+		t.Cleanup(func() { toModel = ToModel })
+
+		ids := []string{"msg-0", "msg-1", "msg-2", "msg-3", "msg-4", "msg-5", "msg-6", "msg-7"}
+		results := Fetch(context.Background(), c, "user-123", ids, 3)
+		for i, r := range results {
+			require.NoError(t, r.Err)
+			assert.Equal(t, ids[i], r.Message.ID)
+		}
+		assert.Equal(t, int32(3), peak.Load())
+	})
+
+	t.Run("Reports failures per message", func(t *testing.T) {
+		results := Fetch(context.Background(), c, "user-123", []string{"msg-0", "missing", "broken"}, 2)
+
+		assert.NoError(t, results[0].Err)
+
+		var ferr *FetchError
+		require.True(t, errors.As(results[1].Err, &ferr))
+		assert.Equal(t, StageFetch, ferr.Stage)
+		var gerr *googleapi.Error
+		require.True(t, errors.As(results[1].Err, &gerr))
+		assert.Equal(t, http.StatusNotFound, gerr.Code)
+
+		require.True(t, errors.As(results[2].Err, &ferr))
+		assert.Equal(t, StageConvert, ferr.Stage)
+		assert.Equal(t, "broken", ferr.ID)
+	})
+
+	t.Run("Stops on cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results := Fetch(ctx, c, "user-123", []string{"msg-0", "msg-1"}, 1)
+		for _, r := range results {
+			assert.ErrorIs(t, r.Err, context.Canceled)
+		}
+	})
+}
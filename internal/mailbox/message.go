@@ -0,0 +1,107 @@
+package mailbox
+
+import (
+	"encoding/base64"
+	"main/internal/model"
+	"strings"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Header returns the first value of the named header, or "" if absent.
+func Header(m *gmail.Message, name string) string {
+	if m == nil || m.Payload == nil {
+		return ""
+	}
+	for _, h := range m.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// HasLabel reports whether the message carries the given label id.
+func HasLabel(m *gmail.Message, id string) bool {
+	for _, l := range m.LabelIds {
+		if l == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Body returns the decoded body of the message, preferring HTML over plain
+// text for multipart messages.
+func Body(m *gmail.Message) (string, error) {
+	if m == nil || m.Payload == nil {
+		return "", nil
+	}
+
+	part := findPart(m.Payload, "text/html")
+	if part == nil {
+		part = findPart(m.Payload, "text/plain")
+	}
+	if part == nil {
+		part = m.Payload
+	}
+	if part.Body == nil || part.Body.Data == "" {
+		return "", nil
+	}
+
+	data, err := decode(part.Body.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Markdown returns the message body converted to markdown.
+func Markdown(m *gmail.Message) (string, error) {
+	body, err := Body(m)
+	if err != nil {
+		return "", err
+	}
+
+	converter := md.NewConverter("", true, nil)
+	return converter.ConvertString(body)
+}
+
+func findPart(p *gmail.MessagePart, mimeType string) *gmail.MessagePart {
+	if strings.EqualFold(p.MimeType, mimeType) && p.Body != nil && p.Body.Data != "" {
+		return p
+	}
+	for _, child := range p.Parts {
+		if found := findPart(child, mimeType); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// decode handles both padded and unpadded base64url as returned by Gmail.
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
+
+// ToModel converts a fully fetched Gmail message into its stored form.
+func ToModel(userID string, m *gmail.Message) (*model.Message, error) {
+	markdown, err := Markdown(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Message{
+		ID:         m.Id,
+		UserID:     userID,
+		ThreadID:   m.ThreadId,
+		Subject:    Header(m, "Subject"),
+		Sender:     Header(m, "From"),
+		Snippet:    m.Snippet,
+		Markdown:   markdown,
+		LabelIDs:   m.LabelIds,
+		ReceivedAt: time.UnixMilli(m.InternalDate),
+	}, nil
+}
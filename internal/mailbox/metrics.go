@@ -0,0 +1,106 @@
+package mailbox
+
+import (
+	"main/internal/metrics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// instrumented records metrics of every Gmail API call, whichever of the
+// Service or the batch endpoint sends it.
+type instrumented struct {
+	next http.RoundTripper
+}
+
+// instrument wraps the transport of client with Gmail call metrics and a
+// client span per call, named after the API method.
+func instrument(client *http.Client) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	cp := *client
+	cp.Transport = otelhttp.NewTransport(&instrumented{next},
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "gmail " + gmailMethod(r)
+		}))
+	return &cp
+}
+
+func (t *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := gmailMethod(req)
+	start := time.Now()
+
+	res, err := t.next.RoundTrip(req)
+	metrics.GmailDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.GmailCalls.WithLabelValues(method, "error").Inc()
+		return nil, err
+	}
+	metrics.GmailCalls.WithLabelValues(method, strconv.Itoa(res.StatusCode)).Inc()
+	return res, nil
+}
+
+// gmailVerbs are the custom methods that follow a resource in a path, such
+// as messages/{id}/modify or messages/batchModify.
+var gmailVerbs = map[string]bool{
+	"modify": true, "trash": true, "untrash": true, "send": true, "import": true,
+	"batchModify": true, "batchDelete": true, "watch": true, "stop": true,
+}
+
+// gmailMethod names the API method of a request the way QuotaCosts does,
+// such as messages.get or labels.create, from its path and HTTP method.
+func gmailMethod(req *http.Request) string {
+	path := req.URL.Path
+	if strings.HasPrefix(path, "/batch/") {
+		return "batch"
+	}
+
+	// /gmail/v1/users/{userId}/messages/{id}, optionally under /upload.
+	_, rest, ok := strings.Cut(path, "/gmail/v1/users/")
+	if !ok {
+		return "other"
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")[1:]
+	if len(parts) == 0 {
+		return "other"
+	}
+	if len(parts) == 1 && parts[0] == "profile" {
+		return "getProfile"
+	}
+
+	var names []string
+	item := false
+	for i, p := range parts {
+		if gmailVerbs[p] {
+			return strings.Join(append(names, p), ".")
+		}
+		// Resources and ids alternate.
+		if item = i%2 == 1; !item {
+			names = append(names, p)
+		}
+	}
+
+	var verb string
+	switch {
+	case !item && req.Method == http.MethodGet:
+		verb = "list"
+	case !item && req.Method == http.MethodPost:
+		verb = "create"
+	case item && req.Method == http.MethodGet:
+		verb = "get"
+	case item && req.Method == http.MethodPut:
+		verb = "update"
+	case item && req.Method == http.MethodPatch:
+		verb = "patch"
+	case item && req.Method == http.MethodDelete:
+		verb = "delete"
+	default:
+		return "other"
+	}
+	return strings.Join(append(names, verb), ".")
+}
@@ -0,0 +1,30 @@
+package mailbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGmailMethod(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/gmail/v1/users/me/messages", "messages.list"},
+		{http.MethodGet, "/gmail/v1/users/me/messages/abc", "messages.get"},
+		{http.MethodPost, "/gmail/v1/users/me/messages/abc/modify", "messages.modify"},
+		{http.MethodPost, "/gmail/v1/users/me/messages/batchModify", "messages.batchModify"},
+		{http.MethodPost, "/upload/gmail/v1/users/me/messages/send", "messages.send"},
+		{http.MethodGet, "/gmail/v1/users/me/messages/abc/attachments/def", "messages.attachments.get"},
+		{http.MethodPost, "/gmail/v1/users/me/labels", "labels.create"},
+		{http.MethodPost, "/gmail/v1/users/me/drafts", "drafts.create"},
+		{http.MethodGet, "/gmail/v1/users/me/profile", "getProfile"},
+		{http.MethodPost, "/batch/gmail/v1", "batch"},
+		{http.MethodGet, "/oauth2/v2/userinfo", "other"},
+	} {
+		req := httptest.NewRequest(tc.method, "https://gmail.googleapis.com"+tc.path, nil)
+		assert.Equal(t, tc.want, gmailMethod(req), tc.path)
+	}
+}
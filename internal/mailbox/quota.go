@@ -0,0 +1,147 @@
+package mailbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Gmail allows 250 quota units per user per second.
+const (
+	DefaultQuotaRate  = 250
+	DefaultQuotaBurst = 250
+)
+
+// QuotaCosts are the quota units charged by Gmail per method. Methods that
+// are not listed cost DefaultQuotaCost.
+var QuotaCosts = map[string]int{
+	"drafts.create":        10,
+	"getProfile":           1,
+	"history.list":         2,
+	"labels.create":        5,
+	"labels.get":           1,
+	"labels.list":          1,
+	"messages.batchModify": 50,
+	"messages.get":         5,
+	"messages.list":        5,
+	"messages.modify":      5,
+	"messages.send":        100,
+	"threads.get":          10,
+}
+
+const DefaultQuotaCost = 5
+
+// QuotaCost returns the units charged for a call to method.
+func QuotaCost(method string) int {
+	if cost, ok := QuotaCosts[method]; ok {
+		return cost
+	}
+	return DefaultQuotaCost
+}
+
+// sweepInterval is how often buckets that refilled up to the burst, which
+// are the same as new ones, are dropped so idle users do not accumulate.
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket per user, refilled with quota units over time.
+// It is shared by every client so that concurrent requests of the same user
+// draw from the same budget. A charge larger than what is available puts the
+// bucket into debt, so later callers also wait until it is paid back.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter allows rate units per second per user, up to burst at once.
+func NewLimiter(rate, burst int) *Limiter {
+	if rate <= 0 {
+		rate = DefaultQuotaRate
+	}
+	if burst <= 0 {
+		burst = DefaultQuotaBurst
+	}
+	return &Limiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Wait charges units to userID and blocks until they are paid for, or ctx is
+// done in which case they are given back.
+func (l *Limiter) Wait(ctx context.Context, userID string, units int) error {
+	delay := l.reserve(userID, float64(units))
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	select {
+	case <-ctx.Done():
+		t.Stop()
+		l.refund(userID, float64(units))
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// reserve takes need units, going into debt if they are not available, and
+// returns how long to wait until the debt is paid back.
+func (l *Limiter) reserve(userID string, need float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[userID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	b.tokens -= need
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// refund gives back units reserved by a caller that stopped waiting.
+func (l *Limiter) refund(userID string, units float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[userID]; ok {
+		b.tokens = min(l.burst, b.tokens+units)
+	}
+}
+
+// sweep drops the buckets that refilled up to the burst, at most once per
+// sweepInterval.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for userID, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, userID)
+		}
+	}
+}
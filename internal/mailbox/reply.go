@@ -0,0 +1,40 @@
+package mailbox
+
+import (
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Reply builds a reply to original that Gmail threads correctly: it
+// answers the Reply-To or From address, prefixes the subject with "Re:"
+// and carries the In-Reply-To and References headers.
+func Reply(original *gmail.Message, from, body string) *Outgoing {
+	to := Header(original, "Reply-To")
+	if to == "" {
+		to = Header(original, "From")
+	}
+
+	subject := strings.TrimSpace(Header(original, "Subject"))
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = strings.TrimSpace("Re: " + subject)
+	}
+
+	messageID := Header(original, "Message-ID")
+	references := Header(original, "References")
+	if references == "" {
+		references = Header(original, "In-Reply-To")
+	}
+	if messageID != "" {
+		references = strings.TrimSpace(references + " " + messageID)
+	}
+
+	return &Outgoing{
+		From:       from,
+		To:         to,
+		Subject:    subject,
+		Body:       body,
+		InReplyTo:  messageID,
+		References: references,
+	}
+}
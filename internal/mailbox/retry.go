@@ -0,0 +1,96 @@
+package mailbox
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Backoff configures retries of rate limited and failed Gmail calls.
+type Backoff struct {
+	// Base is the delay ceiling of the first retry; it doubles every retry.
+	Base time.Duration
+	// Max caps the delay ceiling.
+	Max time.Duration
+	// Attempts is the total number of calls, including the first one.
+	Attempts int
+}
+
+// DefaultBackoff follows the Gmail guidance for exponential backoff.
+var DefaultBackoff = Backoff{Base: 500 * time.Millisecond, Max: 32 * time.Second, Attempts: 5}
+
+// delay returns a random duration up to the ceiling of attempt (full jitter).
+func (b Backoff) delay(attempt int) time.Duration {
+	ceiling := b.Base << attempt
+	if ceiling <= 0 || ceiling > b.Max {
+		ceiling = b.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// IsRetryable reports whether err is a rate limit or a server error.
+func IsRetryable(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+
+	switch {
+	case gerr.Code == http.StatusTooManyRequests, gerr.Code >= 500:
+		return true
+	case gerr.Code == http.StatusForbidden:
+		// Per-user limits are reported as 403 with a rate limit reason.
+		for _, item := range gerr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Retry calls fn until it succeeds, fails with an error that is not
+// retryable, runs out of attempts or ctx is done.
+func Retry(ctx context.Context, b Backoff, fn func() error) error {
+	attempts := max(b.Attempts, 1)
+
+	var err error
+	for attempt := range attempts {
+		if err = fn(); err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt == attempts-1 {
+			break
+		}
+
+		t := time.NewTimer(max(b.delay(attempt), retryAfter(err)))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return err
+}
+
+// retryAfter honours a Retry-After header given in seconds.
+func retryAfter(err error) time.Duration {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) || gerr.Header == nil {
+		return 0
+	}
+	secs, convErr := strconv.Atoi(gerr.Header.Get("Retry-After"))
+	if convErr != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
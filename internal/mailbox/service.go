@@ -0,0 +1,4 @@
+package mailbox
+
+// User is the Gmail user id for the authenticated account.
+const User = "me"
@@ -0,0 +1,88 @@
+// Package metrics declares the Prometheus metrics of the server. They are
+// registered in the default registry, served with promhttp.Handler.
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 1 minute to
+// cover both database queries and LLM calls.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics of the server.
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sumnotes_http_requests_total",
+		Help: "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sumnotes_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: DefaultBuckets,
+	}, []string{"method", "route"})
+
+	GmailCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sumnotes_gmail_calls_total",
+		Help: "Gmail API calls by method and HTTP status, or error when no response was received.",
+	}, []string{"method", "status"})
+	GmailDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sumnotes_gmail_call_duration_seconds",
+		Help:    "Gmail API call latency by method.",
+		Buckets: DefaultBuckets,
+	}, []string{"method"})
+
+	TokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sumnotes_token_refreshes_total",
+		Help: "OAuth token refreshes by outcome: success, provider_error or store_error.",
+	}, []string{"outcome"})
+
+	SummarizerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sumnotes_summarizer_duration_seconds",
+		Help:    "Summarizer backend call latency by model and outcome.",
+		Buckets: DefaultBuckets,
+	}, []string{"model", "outcome"})
+	SummarizerTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sumnotes_summarizer_tokens_total",
+		Help: "Tokens used by the summarizer backend by model and kind: prompt or completion.",
+	}, []string{"model", "kind"})
+
+	SummaryCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sumnotes_summary_cache_lookups_total",
+		Help: "Summary cache lookups by result: memory or store hits, or miss.",
+	}, []string{"result"})
+	SummaryCacheErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sumnotes_summary_cache_errors_total",
+		Help: "Failed summary cache store reads and writes.",
+	})
+
+	JobsRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sumnotes_jobs_running",
+		Help: "Background jobs running by kind.",
+	}, []string{"job"})
+)
+
+// pools are the collectors of the registered database pools.
+var pools = struct {
+	sync.Mutex
+	m map[string]prometheus.Collector
+}{m: map[string]prometheus.Collector{}}
+
+// RegisterDB reports the statistics of db as the go_sql metrics labelled
+// with pool, replacing a pool registered with the same name.
+func RegisterDB(pool string, db *sql.DB) {
+	pools.Lock()
+	defer pools.Unlock()
+
+	if c, ok := pools.m[pool]; ok {
+		prometheus.Unregister(c)
+	}
+	c := collectors.NewDBStatsCollector(db, pool)
+	prometheus.MustRegister(c)
+	pools.m[pool] = c
+}
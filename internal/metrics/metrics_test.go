@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterDB(t *testing.T) {
+	db := sql.OpenDB(nil)
+	db.SetMaxOpenConns(3)
+
+	// Registering a pool again replaces it instead of panicking.
+	RegisterDB("test", sql.OpenDB(nil))
+	RegisterDB("test", db)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, f := range families {
+		if f.GetName() != "go_sql_max_open_connections" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if m.GetLabel()[0].GetValue() == "test" {
+				found = true
+				assert.Equal(t, 3.0, m.GetGauge().GetValue())
+			}
+		}
+	}
+	assert.True(t, found)
+}
@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"log/slog"
+	"main/internal/apierr"
 	"main/internal/auth"
 	"main/internal/database"
+	"main/internal/logging"
 	"main/internal/model"
-	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -32,29 +34,34 @@ func Auth(store sessions.Store, db database.UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session, err := auth.GetSession(store, c.Request)
 		if err != nil {
-			c.AbortWithStatus(http.StatusInternalServerError)
+			Abort(c, err)
 			return
 		}
 
 		userID, ok := session.Values["user_id"].(string)
 
 		if !ok || userID == "" {
-			c.AbortWithStatus(http.StatusNotFound)
+			Abort(c, apierr.New(apierr.Unauthenticated, ""))
 			return
 		}
 
-		u, err := db.FindUserByID(userID)
+		u, err := db.FindUserByID(c.Request.Context(), userID)
 		if err != nil {
-			c.AbortWithStatus(http.StatusNotFound)
+			Abort(c, err)
+			return
+		}
+		if u == nil {
+			Abort(c, apierr.New(apierr.Unauthenticated, ""))
 			return
 		}
 
 		if time.Now().After(u.TokenExpiry) || time.Now().Equal(u.TokenExpiry) {
-			c.AbortWithStatus(http.StatusUnauthorized)
+			Abort(c, apierr.New(apierr.TokenExpired, ""))
 			return
 		}
 
 		SetUser(c, u)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String("user_id", u.ID)))
 
 		c.Next()
 	}
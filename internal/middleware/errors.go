@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"main/internal/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errCtxKey = "apiError"
+
+// ErrorResponse is the body of every failed API response.
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code      apierr.Code `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"requestId,omitempty"`
+	Details   any         `json:"details,omitempty"`
+}
+
+// Abort stops the request with err, which Errors renders. Errors that are
+// not an *apierr.Error are reported as internal errors; their text is only
+// logged. The status is set right away so that middlewares running inside
+// Errors, such as Metrics, see it.
+func Abort(c *gin.Context, err error) {
+	e := apierr.From(err)
+	c.Set(errCtxKey, e)
+	_ = c.Error(e)
+	if !c.Writer.Written() {
+		c.Status(e.Status())
+	}
+	c.Abort()
+}
+
+// Errors renders the error a request was aborted with, or the status of a
+// response that was not written such as for unknown routes, as an
+// ErrorResponse.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Written() {
+			return
+		}
+
+		var e *apierr.Error
+		if v, ok := c.Get(errCtxKey); ok {
+			e = v.(*apierr.Error)
+		} else if status := c.Writer.Status(); status >= 400 {
+			e = apierr.FromStatus(status)
+		} else {
+			return
+		}
+
+		c.JSON(e.Status(), ErrorResponse{ErrorBody{
+			Code:      e.Code,
+			Message:   e.Message,
+			RequestID: GetRequestID(c),
+			Details:   e.Details,
+		}})
+	}
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/apierr"
+	"main/internal/auth"
+	"main/internal/database"
+	"main/internal/model"
+)
+
+func TestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RequestID(), Errors(), Recovery())
+	r.GET("/internal", func(c *gin.Context) {
+		Abort(c, errors.New("pq: password authentication failed for user sumnotes"))
+	})
+	r.GET("/conflict", func(c *gin.Context) {
+		Abort(c, apierr.New(apierr.Conflict, "a backfill is already running"))
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	r.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		Abort(c, errors.New("late failure"))
+	})
+
+	do := func(path string) (*httptest.ResponseRecorder, ErrorResponse) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var res ErrorResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
+
+	t.Run("Hides internal errors", func(t *testing.T) {
+		w, res := do("/internal")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, ErrorBody{Code: apierr.Internal, Message: "internal server error", RequestID: "req-1"}, res.Error)
+		assert.NotContains(t, w.Body.String(), "pq:")
+	})
+
+	t.Run("Renders API errors", func(t *testing.T) {
+		w, res := do("/conflict")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, ErrorBody{Code: apierr.Conflict, Message: "a backfill is already running", RequestID: "req-1"}, res.Error)
+	})
+
+	t.Run("Renders panics", func(t *testing.T) {
+		w, res := do("/panic")
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, apierr.Internal, res.Error.Code)
+		assert.NotContains(t, w.Body.String(), "boom")
+	})
+
+	t.Run("Renders unknown routes", func(t *testing.T) {
+		w, res := do("/missing")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, apierr.NotFound, res.Error.Code)
+	})
+
+	t.Run("Keeps written responses", func(t *testing.T) {
+		w, _ := do("/written")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "partial", w.Body.String())
+	})
+}
+
+type fakeUsers struct {
+	database.UserStore
+	users map[string]*model.User
+}
+
+func (f *fakeUsers) FindUserByID(_ context.Context, id string) (*model.User, error) {
+	return f.users[id], nil
+}
+
+func TestAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	users := &fakeUsers{users: map[string]*model.User{
+		"active":  {ID: "active", TokenExpiry: time.Now().Add(time.Hour)},
+		"expired": {ID: "expired", TokenExpiry: time.Now().Add(-time.Hour)},
+	}}
+
+	r := gin.New()
+	r.Use(Errors())
+	r.GET("/login/:id", func(c *gin.Context) {
+		session, _ := auth.GetSession(store, c.Request)
+		session.Values["user_id"] = c.Param("id")
+		require.NoError(t, session.Save(c.Request, c.Writer))
+	})
+	r.GET("/me", Auth(store, users), func(c *gin.Context) {
+		user, _ := GetUser(c)
+		c.String(http.StatusOK, user.ID)
+	})
+
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if id != "" {
+			login := httptest.NewRecorder()
+			r.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/login/"+id, nil))
+			for _, cookie := range login.Result().Cookies() {
+				req.AddCookie(cookie)
+			}
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	code := func(w *httptest.ResponseRecorder) apierr.Code {
+		var res ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return res.Error.Code
+	}
+
+	w := get("active")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "active", w.Body.String())
+
+	for id, want := range map[string]apierr.Code{
+		"":        apierr.Unauthenticated,
+		"deleted": apierr.Unauthenticated,
+		"expired": apierr.TokenExpired,
+	} {
+		w := get(id)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, id)
+		assert.Equal(t, want, code(w), id)
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"main/internal/apierr"
+	"main/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Metrics runs inside Errors, as in the server.
+	r := gin.New()
+	r.Use(Errors())
+	r.Use(Metrics())
+	r.GET("/metrics-test/:id", func(c *gin.Context) {
+		Abort(c, apierr.New(apierr.NotFound, "message not found"))
+	})
+
+	notFound := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/metrics-test/:id", "404")
+	ok := metrics.HTTPRequests.WithLabelValues(http.MethodGet, "/metrics-test/:id", "200")
+	before := testutil.ToFloat64(notFound)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics-test/abc", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(notFound))
+	assert.Zero(t, testutil.ToFloat64(ok))
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"main/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request id from the client or proxy and back
+// in the response.
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDCtxKey = "requestID"
+
+// RequestID reuses a well formed X-Request-ID header or generates an id,
+// echoes it in the response and adds it to the log context.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDCtxKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String("request_id", id)))
+
+		c.Next()
+	}
+}
+
+// GetRequestID returns the id set by RequestID, or an empty string.
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDCtxKey)
+}
+
+// validRequestID accepts ids of up to 128 letters, digits, dashes,
+// underscores and dots, so clients cannot inject arbitrary log content.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
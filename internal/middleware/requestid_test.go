@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(logging.New(&buf, slog.LevelInfo))
+	defer slog.SetDefault(prev)
+
+	r := gin.New()
+	r.Use(RequestID(), Logger())
+	r.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+
+	t.Run("Echoes a valid id", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/ping?code=secret", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+		assert.Equal(t, "abc-123", w.Body.String())
+
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &rec))
+		assert.Equal(t, "abc-123", rec["request_id"])
+		assert.Equal(t, "/ping", rec["path"])
+		assert.Equal(t, "/ping", rec["route"])
+		assert.EqualValues(t, http.StatusOK, rec["status"])
+		assert.NotContains(t, buf.String(), "secret")
+	})
+
+	t.Run("Replaces missing or invalid ids", func(t *testing.T) {
+		for _, id := range []string{"", "bad id\n{\"level\":\"ERROR\"}"} {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.Header.Set(RequestIDHeader, id)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			assert.Len(t, got, 32)
+			assert.Equal(t, got, w.Body.String())
+		}
+	})
+}
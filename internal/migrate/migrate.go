@@ -0,0 +1,378 @@
+// Package migrate applies goose-format SQL migrations and records them in
+// goose's goose_db_version table, so the goose CLI and this package can be
+// used interchangeably on the same database.
+package migrate
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionTable is the bookkeeping table used by goose.
+const VersionTable = "goose_db_version"
+
+// Migration is a single versioned SQL file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// NoTx migrations run outside a transaction, for statements such as
+	// CREATE INDEX CONCURRENTLY.
+	NoTx bool
+}
+
+// Status reports whether a migration is applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load parses every <version>_<name>.sql file at the root of fsys, sorted by
+// version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, file := range files {
+		version, name, ok := strings.Cut(strings.TrimSuffix(path.Base(file), ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>.sql", file)
+		}
+		v, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		m, err := parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+		m.Version = v
+		m.Name = name
+
+		if i := slices.IndexFunc(migrations, func(o Migration) bool { return o.Version == v }); i >= 0 {
+			return nil, fmt.Errorf("migration %s: duplicate version %d", file, v)
+		}
+		migrations = append(migrations, m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// parse splits a goose file into its up and down sections.
+func parse(data string) (Migration, error) {
+	var m Migration
+	var up, down strings.Builder
+	var section *strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose"); ok {
+			switch strings.ToUpper(strings.TrimSpace(annotation)) {
+			case "UP":
+				section = &up
+			case "DOWN":
+				section = &down
+			case "NO TRANSACTION":
+				m.NoTx = true
+			}
+			// StatementBegin and StatementEnd only matter to goose's
+			// statement splitter; sections are sent whole.
+			continue
+		}
+		if section != nil {
+			section.WriteString(line)
+			section.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return m, err
+	}
+	if section == nil {
+		return m, fmt.Errorf("missing -- +goose Up annotation")
+	}
+
+	m.Up = strings.TrimSpace(up.String())
+	m.Down = strings.TrimSpace(down.String())
+	return m, nil
+}
+
+// LockID is the Postgres advisory lock key held while applying migrations,
+// so that replicas starting together do not race.
+const LockID int64 = 0x73756d6e6f746573 // "sumnotes"
+
+// ErrSchemaNewer is returned when the database has migrations this binary
+// does not know, meaning a newer release already migrated it.
+var ErrSchemaNewer = errors.New("database schema is newer than this binary")
+
+// conn is satisfied by both *sql.DB and *sql.Conn.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	conn       conn
+	migrations []Migration
+}
+
+// New creates a Migrator for the migrations in fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, conn: db, migrations: migrations}, nil
+}
+
+// Apply checks that the schema is not newer than the known migrations and
+// applies the pending ones, holding the advisory lock throughout.
+func (m *Migrator) Apply(ctx context.Context) ([]Migration, error) {
+	c, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	// Session level advisory locks belong to a connection, so everything
+	// runs on the one holding it.
+	if _, err := c.ExecContext(ctx, "SELECT pg_advisory_lock($1)", LockID); err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer c.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", LockID)
+
+	locked := &Migrator{db: m.db, conn: c, migrations: m.migrations}
+	if err := locked.Check(ctx); err != nil {
+		return nil, err
+	}
+	return locked.Up(ctx)
+}
+
+// Check returns ErrSchemaNewer if the database has applied migrations that
+// are unknown to this binary. It does not create the version table.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	var unknown []int64
+	for v := range applied {
+		if !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == v }) {
+			unknown = append(unknown, v)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("%w: unknown applied versions %v", ErrSchemaNewer, unknown)
+	}
+	return nil
+}
+
+// Pending returns the migrations not applied yet, without creating the
+// version table.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Migrations returns the known migrations, sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in order and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.run(ctx, mig, mig.Up, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest applied migration. It returns nil when nothing
+// is applied.
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if err := m.run(ctx, mig, mig.Down, false); err != nil {
+			return nil, err
+		}
+		return &mig, nil
+	}
+	return nil, nil
+}
+
+// Status lists every known migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		at, ok := applied[mig.Version]
+		statuses[i] = Status{Migration: mig, Applied: ok, AppliedAt: at}
+	}
+	return statuses, nil
+}
+
+// run executes a section and records it, in one transaction unless the
+// migration opts out.
+func (m *Migrator) run(ctx context.Context, mig Migration, query string, up bool) error {
+	record := func(exec func(ctx context.Context, query string, args ...any) (sql.Result, error)) error {
+		if query != "" {
+			if _, err := exec(ctx, query); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+		var err error
+		if up {
+			_, err = exec(ctx, "INSERT INTO "+VersionTable+" (version_id, is_applied) VALUES ($1, TRUE)", mig.Version)
+		} else {
+			_, err = exec(ctx, "DELETE FROM "+VersionTable+" WHERE version_id = $1", mig.Version)
+		}
+		return err
+	}
+
+	if mig.NoTx {
+		return record(m.conn.ExecContext)
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := record(tx.ExecContext); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied creates the version table the way goose does when it is missing
+// and returns the applied versions.
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	_, err := m.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+VersionTable+` (
+		id SERIAL PRIMARY KEY,
+		version_id BIGINT NOT NULL,
+		is_applied BOOLEAN NOT NULL,
+		tstamp TIMESTAMP DEFAULT NOW()
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	// goose seeds the table with version 0.
+	_, err = m.conn.ExecContext(ctx, `INSERT INTO `+VersionTable+` (version_id, is_applied)
+		SELECT 0, TRUE WHERE NOT EXISTS (SELECT 1 FROM `+VersionTable+`)`)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.appliedVersions(ctx)
+}
+
+// appliedVersions returns the applied versions and when they were applied.
+// A missing version table means nothing is applied.
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.conn.QueryContext(ctx, "SELECT to_regclass($1) IS NOT NULL", VersionTable)
+	if err != nil {
+		return nil, err
+	}
+	var exists bool
+	if rows.Next() {
+		err = rows.Scan(&exists)
+	}
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]time.Time{}, nil
+	}
+
+	rows, err = m.conn.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM "+VersionTable+" ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The latest row of a version decides whether it is applied, as older
+	// goose versions recorded rollbacks as is_applied = false.
+	seen := map[int64]bool{}
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var at sql.NullTime
+		if err := rows.Scan(&version, &isApplied, &at); err != nil {
+			return nil, err
+		}
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied && version != 0 {
+			applied[version] = at.Time
+		}
+	}
+	return applied, rows.Err()
+}
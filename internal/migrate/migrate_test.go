@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20250101000000_second.sql": {Data: []byte("-- +goose Up\n-- +goose StatementBegin\nCREATE TABLE b (id INT);\n-- +goose StatementEnd\n\n-- +goose Down\nDROP TABLE b;\n")},
+		"20240101000000_first.sql":  {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n")},
+		"README.md":                 {Data: []byte("not a migration")},
+	}
+
+	ms, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+
+	assert.Equal(t, int64(20240101000000), ms[0].Version)
+	assert.Equal(t, "first", ms[0].Name)
+	assert.True(t, ms[0].NoTx)
+	assert.Empty(t, ms[0].Down)
+
+	assert.Equal(t, "second", ms[1].Name)
+	assert.Equal(t, "CREATE TABLE b (id INT);", ms[1].Up)
+	assert.Equal(t, "DROP TABLE b;", ms[1].Down)
+	assert.False(t, ms[1].NoTx)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	_, err := Load(fstest.MapFS{"nounderscore.sql": {Data: []byte("-- +goose Up\n")}})
+	assert.Error(t, err)
+
+	_, err = Load(fstest.MapFS{"1_a.sql": {Data: []byte("SELECT 1;")}})
+	assert.ErrorContains(t, err, "goose Up")
+
+	_, err = Load(fstest.MapFS{
+		"1_a.sql": {Data: []byte("-- +goose Up\n")},
+		"1_b.sql": {Data: []byte("-- +goose Up\n")},
+	})
+	assert.ErrorContains(t, err, "duplicate")
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	assert.Equal(t, "create_users_table", ms[0].Name)
+	for _, m := range ms {
+		assert.NotEmpty(t, m.Up, m.Name)
+		assert.NotEmpty(t, m.Down, m.Name)
+	}
+}
+
+// TestMigrator_Postgres runs against the database in TEST_DATABASE_URL and
+// is skipped when it is not set. It uses its own schema so it can run next
+// to real data.
+func TestMigrator_Postgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "DROP SCHEMA IF EXISTS migrate_test CASCADE; CREATE SCHEMA migrate_test; SET search_path TO migrate_test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.ExecContext(ctx, "DROP SCHEMA IF EXISTS migrate_test CASCADE") })
+
+	fsys := fstest.MapFS{
+		"1_a.sql": {Data: []byte("-- +goose Up\nCREATE TABLE a (id INT);\n-- +goose Down\nDROP TABLE a;\n")},
+		"2_b.sql": {Data: []byte("-- +goose Up\nCREATE TABLE b (id INT);\n-- +goose Down\nDROP TABLE b;\n")},
+	}
+	m, err := New(db, fsys)
+	require.NoError(t, err)
+
+	pending, err := m.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	applied, err := m.Apply(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+
+	applied, err = m.Apply(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	down, err := m.Down(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), down.Version)
+
+	// A binary that only knows the first migration refuses a schema
+	// migrated further by a newer one.
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	older, err := New(db, fstest.MapFS{"1_a.sql": fsys["1_a.sql"]})
+	require.NoError(t, err)
+	assert.ErrorIs(t, older.Check(ctx), ErrSchemaNewer)
+	_, err = older.Apply(ctx)
+	assert.ErrorIs(t, err, ErrSchemaNewer)
+}
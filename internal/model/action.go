@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// ActionSettings holds the post-summary mailbox actions a user has enabled.
+type ActionSettings struct {
+	UserID              string    `db:"user_id" json:"-"`
+	AddSummarizedLabel  bool      `db:"add_summarized_label" json:"addSummarizedLabel"`
+	ApplyCategoryLabels bool      `db:"apply_category_labels" json:"applyCategoryLabels"`
+	MarkRead            bool      `db:"mark_read" json:"markRead"`
+	Archive             bool      `db:"archive" json:"archive"`
+	UpdatedAt           time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// Enabled reports whether any action would modify the mailbox.
+func (s *ActionSettings) Enabled() bool {
+	return s.AddSummarizedLabel || s.ApplyCategoryLabels || s.MarkRead || s.Archive
+}
+
+// LabelOperation is a single Users.Messages.BatchModify call. Only labels
+// that actually changed are recorded so the operation can be reversed exactly.
+type LabelOperation struct {
+	MessageIDs     []string `json:"messageIds"`
+	AddLabelIDs    []string `json:"addLabelIds,omitempty"`
+	RemoveLabelIDs []string `json:"removeLabelIds,omitempty"`
+}
+
+// ActionBatch is an entry in a user's action log.
+type ActionBatch struct {
+	ID         string           `db:"id" json:"id"`
+	UserID     string           `db:"user_id" json:"-"`
+	Operations []LabelOperation `db:"operations" json:"operations"`
+	CreatedAt  time.Time        `db:"created_at" json:"createdAt"`
+	UndoneAt   *time.Time       `db:"undone_at" json:"undoneAt,omitempty"`
+}
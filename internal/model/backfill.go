@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Backfill statuses.
+const (
+	BackfillRunning = "running"
+	BackfillDone    = "done"
+	BackfillFailed  = "failed"
+	// BackfillStopped runs were interrupted and resume from their checkpoint.
+	BackfillStopped = "stopped"
+)
+
+// Backfill is the checkpoint of a historical import of a user's mailbox.
+type Backfill struct {
+	UserID string    `db:"user_id" json:"-"`
+	Since  time.Time `db:"since" json:"since"`
+	Status string    `db:"status" json:"status"`
+	// PageToken is the Messages.List page being processed, empty for the
+	// first page.
+	PageToken string `db:"page_token" json:"-"`
+	// LastMessageID is the last message of that page already processed.
+	LastMessageID string    `db:"last_message_id" json:"lastMessageId"`
+	Processed     int       `db:"processed" json:"processed"`
+	Failed        int       `db:"failed" json:"failed"`
+	Error         string    `db:"error" json:"error,omitempty"`
+	StartedAt     time.Time `db:"started_at" json:"startedAt"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updatedAt"`
+}
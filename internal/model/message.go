@@ -0,0 +1,90 @@
+package model
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Message is a Gmail message stored locally for search and summarization.
+type Message struct {
+	ID         string    `db:"id" json:"id"`
+	UserID     string    `db:"user_id" json:"-"`
+	ThreadID   string    `db:"thread_id" json:"threadId"`
+	Subject    string    `db:"subject" json:"subject"`
+	Sender     string    `db:"sender" json:"sender"`
+	Snippet    string    `db:"snippet" json:"snippet"`
+	Markdown   string    `db:"markdown" json:"markdown,omitempty"`
+	LabelIDs   []string  `db:"label_ids" json:"labelIds"`
+	ReceivedAt time.Time `db:"received_at" json:"receivedAt"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// LogValue keeps message content out of logs.
+func (m *Message) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", m.ID), slog.String("thread_id", m.ThreadID))
+}
+
+// Summary is a generated summary of a stored message.
+type Summary struct {
+	ID        string    `db:"id" json:"id"`
+	UserID    string    `db:"user_id" json:"-"`
+	MessageID string    `db:"message_id" json:"messageId"`
+	Text      string    `db:"text" json:"text"`
+	Model     string    `db:"model" json:"model"`
+	Profile   string    `db:"profile" json:"profile"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+// SearchQuery describes a full-text search over stored messages. Query
+// accepts web search syntax: "quoted phrases", OR and -exclusions.
+type SearchQuery struct {
+	Query  string
+	Sender string
+	Label  string
+	After  time.Time
+	Before time.Time
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a ranked message with highlighted snippets.
+type SearchResult struct {
+	ID             string    `json:"id"`
+	ThreadID       string    `json:"threadId"`
+	Subject        string    `json:"subject"`
+	Sender         string    `json:"sender"`
+	LabelIDs       []string  `json:"labelIds"`
+	ReceivedAt     time.Time `json:"receivedAt"`
+	Rank           float64   `json:"rank"`
+	Snippet        string    `json:"snippet"`
+	SummarySnippet string    `json:"summarySnippet,omitempty"`
+}
+
+// Chunk is an embedded passage of a stored message.
+type Chunk struct {
+	Index     int       `db:"chunk_index" json:"index"`
+	Content   string    `db:"content" json:"content"`
+	Embedding []float32 `db:"embedding" json:"-"`
+}
+
+// SemanticResult is a message ranked by similarity to a query.
+type SemanticResult struct {
+	ID         string    `json:"id"`
+	ThreadID   string    `json:"threadId"`
+	Subject    string    `json:"subject"`
+	Sender     string    `json:"sender"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	Score      float64   `json:"score"`
+	Passage    string    `json:"passage"`
+}
+
+// CachedSummary is a summarizer output keyed by a hash of its inputs.
+type CachedSummary struct {
+	Key              string    `db:"key" json:"key"`
+	Model            string    `db:"model" json:"model"`
+	Text             string    `db:"text" json:"text"`
+	PromptTokens     int       `db:"prompt_tokens" json:"promptTokens"`
+	CompletionTokens int       `db:"completion_tokens" json:"completionTokens"`
+	CreatedAt        time.Time `db:"created_at" json:"createdAt"`
+}
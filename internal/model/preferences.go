@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Preferences controls how summaries are written for a user.
+type Preferences struct {
+	UserID           string          `db:"user_id" json:"-"`
+	Format           string          `db:"format" json:"format"`
+	Length           string          `db:"length" json:"length"`
+	FocusActionItems bool            `db:"focus_action_items" json:"focusActionItems"`
+	Language         string          `db:"language" json:"language"`
+	DefaultProfile   string          `db:"default_profile" json:"defaultProfile"`
+	Profiles         []PromptProfile `db:"profiles" json:"profiles"`
+	UpdatedAt        time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// PromptProfile is a named Go template rendering summarizer instructions.
+type PromptProfile struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Subscription is a mailing list sender aggregated from List-Unsubscribe headers.
+type Subscription struct {
+	ID                string     `db:"id" json:"id"`
+	UserID            string     `db:"user_id" json:"-"`
+	Sender            string     `db:"sender" json:"sender"`
+	SenderName        string     `db:"sender_name" json:"senderName"`
+	UnsubscribeURL    string     `db:"unsubscribe_url" json:"unsubscribeUrl,omitempty"`
+	UnsubscribeMailto string     `db:"unsubscribe_mailto" json:"unsubscribeMailto,omitempty"`
+	OneClick          bool       `db:"one_click" json:"oneClick"`
+	MessageCount      int        `db:"message_count" json:"messageCount"`
+	ReadCount         int        `db:"read_count" json:"readCount"`
+	LastSeenAt        time.Time  `db:"last_seen_at" json:"lastSeenAt"`
+	UnsubscribedAt    *time.Time `db:"unsubscribed_at" json:"unsubscribedAt,omitempty"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// ReadRate is the fraction of messages from the sender that were opened.
+func (s *Subscription) ReadRate() float64 {
+	if s.MessageCount == 0 {
+		return 0
+	}
+	return float64(s.ReadCount) / float64(s.MessageCount)
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 type User struct {
 	ID           string    `db:"id"`
@@ -13,3 +16,8 @@ type User struct {
 	CreatedAt    time.Time `db:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at"`
 }
+
+// LogValue keeps tokens and personal details out of logs.
+func (u *User) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.ID))
+}
@@ -0,0 +1,23 @@
+// Command gen writes the frontend's typed API client generated from the
+// OpenAPI document.
+package main
+
+import (
+	"flag"
+	"log"
+	"main/internal/openapi"
+	"os"
+)
+
+func main() {
+	out := flag.String("o", "api.gen.ts", "output file")
+	flag.Parse()
+
+	src, err := openapi.TypeScript(openapi.Spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
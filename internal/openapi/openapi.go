@@ -0,0 +1,24 @@
+// Package openapi embeds the OpenAPI 3 document describing the /api routes
+// and generates the frontend's typed client from it.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+//go:generate go run ./gen -o ../../frontend/src/lib/api.gen.ts
+
+// Spec is the OpenAPI document. It is written by hand; the contract test in
+// the server package keeps it in line with the registered routes.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves Spec.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(Spec)
+	})
+}
@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(Spec), w.Body.String())
+}
+
+func TestTypeScriptIsGenerated(t *testing.T) {
+	want, err := TypeScript(Spec)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile("../../frontend/src/lib/api.gen.ts")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "api.gen.ts is stale, run go generate ./internal/openapi")
+}
+
+func TestTypeScript(t *testing.T) {
+	src, err := TypeScript(Spec)
+	require.NoError(t, err)
+
+	ts := string(src)
+	assert.Contains(t, ts, "export interface ErrorResponse {")
+	assert.Contains(t, ts, "getMe: async (init?: RequestInit): Promise<User> => {")
+	// Redirects and event streams are left to the browser.
+	assert.NotContains(t, ts, "signIn")
+	assert.NotContains(t, ts, "summary/stream")
+
+	_, err = TypeScript([]byte(`{"paths": {"/x": {"get": {"responses": {}}}}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,345 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// document is the subset of OpenAPI the client generator reads.
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Parameters map[string]*parameter `json:"parameters"`
+		Schemas    map[string]*schema    `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary"`
+	Parameters  []*parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                  `json:"required"`
+		Content  map[string]*mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type response struct {
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Description          string             `json:"description"`
+	Enum                 []string           `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	ReadOnly             bool               `json:"readOnly"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties any                `json:"additionalProperties"`
+}
+
+// methods are generated in this order for each path.
+var methods = []string{"get", "post", "put", "delete"}
+
+// TypeScript generates a TypeScript module from spec with an interface per
+// schema and a createClient function with a method per operation. Only
+// operations answering with JSON, text or nothing get a method; redirects
+// and event streams are left to the browser.
+func TypeScript(spec []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by internal/openapi from openapi.json. DO NOT EDIT.
+
+`)
+
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		s := doc.Components.Schemas[name]
+		writeDoc(&b, "", s.Description)
+		if s.Type == "object" && s.Properties != nil {
+			fmt.Fprintf(&b, "export interface %s %s\n\n", name, tsType(s, ""))
+		} else {
+			fmt.Fprintf(&b, "export type %s = %s\n\n", name, tsType(s, ""))
+		}
+	}
+
+	b.WriteString(`export class ApiError extends Error {
+  readonly status: number
+  readonly error?: ErrorResponse['error']
+
+  constructor(status: number, error?: ErrorResponse['error']) {
+    super(error?.message ?? ` + "`request failed with status ${status}`" + `)
+    this.status = status
+    this.error = error
+  }
+}
+
+type Query = Record<string, string | number | boolean | undefined>
+
+type Options = {
+  query?: Query
+  body?: unknown
+  init?: RequestInit
+}
+
+/**
+ * createClient returns the API methods, sending requests to baseUrl with
+ * defaults. Failed requests reject with an ApiError.
+ */
+export function createClient(baseUrl = '', defaults: RequestInit = {}) {
+  async function request(
+    method: string,
+    path: string,
+    { query, body, init }: Options,
+  ): Promise<Response> {
+    const params = new URLSearchParams()
+    for (const [key, value] of Object.entries(query ?? {})) {
+      if (value !== undefined) params.set(key, String(value))
+    }
+    const search = params.toString() ? ` + "`?${params}`" + ` : ''
+
+    const headers = new Headers(defaults.headers)
+    new Headers(init?.headers).forEach((value, key) => headers.set(key, value))
+    if (body !== undefined) headers.set('Content-Type', 'application/json')
+
+    const response = await fetch(baseUrl + path + search, {
+      ...defaults,
+      ...init,
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    })
+    if (!response.ok) {
+      let error: ErrorResponse['error'] | undefined
+      try {
+        error = ((await response.json()) as ErrorResponse).error
+      } catch {
+        // Not an API error, such as a proxy failure.
+      }
+      throw new ApiError(response.status, error)
+    }
+    return response
+  }
+
+  return {
+`)
+
+	for _, path := range sortedKeys(doc.Paths) {
+		for _, method := range methods {
+			op, ok := doc.Paths[path][method]
+			if !ok {
+				continue
+			}
+			if err := writeOperation(&b, &doc, path, method, op); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b.WriteString("  }\n}\n")
+	return b.Bytes(), nil
+}
+
+func writeOperation(b *bytes.Buffer, doc *document, path, method string, op *operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("openapi: %s %s has no operationId", strings.ToUpper(method), path)
+	}
+
+	result, read, ok := success(op)
+	if !ok {
+		return nil
+	}
+
+	var args, pathArgs, queryFields []string
+	queryRequired := false
+	for _, p := range op.Parameters {
+		if p.Ref != "" {
+			p = doc.Components.Parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+			if p == nil {
+				return fmt.Errorf("openapi: %s %s: unknown parameter", strings.ToUpper(method), path)
+			}
+		}
+		switch p.In {
+		case "path":
+			args = append(args, fmt.Sprintf("%s: %s", p.Name, tsType(p.Schema, "    ")))
+			pathArgs = append(pathArgs, p.Name)
+		case "query":
+			optional := "?"
+			if p.Required {
+				optional = ""
+				queryRequired = true
+			}
+			queryFields = append(queryFields, fmt.Sprintf("%s%s: %s", p.Name, optional, tsType(p.Schema, "    ")))
+		}
+	}
+
+	var opts []string
+	if rb := op.RequestBody; rb != nil {
+		body := rb.Content["application/json"]
+		if body == nil {
+			return fmt.Errorf("openapi: %s %s: only JSON bodies are supported", strings.ToUpper(method), path)
+		}
+		optional := "?"
+		if rb.Required {
+			optional = ""
+		}
+		args = append(args, fmt.Sprintf("body%s: %s", optional, tsType(body.Schema, "    ")))
+		opts = append(opts, "body")
+	}
+	if len(queryFields) > 0 {
+		optional := "?"
+		if queryRequired {
+			optional = ""
+		}
+		args = append(args, fmt.Sprintf("query%s: { %s }", optional, strings.Join(queryFields, "; ")))
+		opts = append(opts, "query")
+	}
+	args = append(args, "init?: RequestInit")
+	opts = append(opts, "init")
+
+	url := path
+	for _, name := range pathArgs {
+		url = strings.Replace(url, "{"+name+"}", "${encodeURIComponent("+name+")}", 1)
+	}
+
+	call := fmt.Sprintf("request('%s', `%s`, { %s })", strings.ToUpper(method), url, strings.Join(opts, ", "))
+
+	writeDoc(b, "    ", op.Summary)
+	fmt.Fprintf(b, "    %s: async (%s): Promise<%s> => {\n", op.OperationID, strings.Join(args, ", "), result)
+	switch read {
+	case "json":
+		fmt.Fprintf(b, "      const response = await %s\n", call)
+		fmt.Fprintf(b, "      return (await response.json()) as %s\n", result)
+	case "text":
+		fmt.Fprintf(b, "      const response = await %s\n", call)
+		b.WriteString("      return response.text()\n")
+	default:
+		fmt.Fprintf(b, "      await %s\n", call)
+	}
+	b.WriteString("    },\n")
+	return nil
+}
+
+// success returns the result type of the first 2xx response and how to read
+// it, and false when the operation is not generated.
+func success(op *operation) (result, read string, ok bool) {
+	for _, status := range sortedKeys(op.Responses) {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		res := op.Responses[status]
+		if len(res.Content) == 0 {
+			return "void", "", true
+		}
+		if json, ok := res.Content["application/json"]; ok {
+			return tsType(json.Schema, "    "), "json", true
+		}
+		for typ := range res.Content {
+			if strings.HasPrefix(typ, "text/") && typ != "text/event-stream" {
+				return "string", "text", true
+			}
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+// tsType returns the TypeScript type of s, indenting object members one
+// level deeper than indent.
+func tsType(s *schema, indent string) string {
+	if s == nil {
+		return "unknown"
+	}
+
+	var t string
+	switch {
+	case s.Ref != "":
+		t = s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, v := range s.Enum {
+			values[i] = "'" + v + "'"
+		}
+		t = strings.Join(values, " | ")
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer", s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsType(s.Items, indent)
+		if strings.ContainsAny(t, " |") && !strings.HasPrefix(t, "{") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	case s.Type == "object" && s.Properties != nil:
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, name := range sortedKeys(s.Properties) {
+			p := s.Properties[name]
+			// Read only properties are left out of requests.
+			optional := "?"
+			if slices.Contains(s.Required, name) && !p.ReadOnly {
+				optional = ""
+			}
+			writeDocString(&b, indent+"  ", p.Description)
+			fmt.Fprintf(&b, "%s  %s%s: %s\n", indent, name, optional, tsType(p, indent+"  "))
+		}
+		b.WriteString(indent + "}")
+		t = b.String()
+	case s.Type == "object":
+		t = "Record<string, unknown>"
+	default:
+		t = "unknown"
+	}
+
+	if s.Nullable {
+		t += " | null"
+	}
+	return t
+}
+
+func writeDoc(b *bytes.Buffer, indent, text string) {
+	var sb strings.Builder
+	writeDocString(&sb, indent, text)
+	b.WriteString(sb.String())
+}
+
+func writeDocString(b *strings.Builder, indent, text string) {
+	if text != "" {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, text)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
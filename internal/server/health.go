@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"main/internal/backfill"
+	"main/internal/database"
+	"main/internal/health"
+	"main/internal/migrate"
+	"main/internal/summarizer"
+	"main/migrations"
+	"time"
+
+	"github.com/antonlindstrom/pgstore"
+)
+
+// backfillStallTimeout is how long a running backfill may go without
+// storing a message before the server is reported as degraded.
+const backfillStallTimeout = 10 * time.Minute
+
+// newChecker creates the readiness checks of the server dependencies. The
+// summarizer backend and the backfills are optional: a restart fixes neither,
+// and everything else keeps working. The database checks are left out for
+// the memory store.
+func newChecker(db database.Store, sessions *pgstore.PGStore, runner *backfill.Runner, backend summarizer.Summarizer) (*health.Checker, error) {
+	c := health.NewChecker(health.DefaultTimeout)
+
+	if sqlDB, ok := db.(*database.DB); ok {
+		m, err := migrate.New(sqlDB.DB, migrations.FS)
+		if err != nil {
+			return nil, err
+		}
+
+		c.Add(health.Check{Name: "database", Func: sqlDB.PingContext})
+		c.Add(health.Check{Name: "migrations", Func: func(ctx context.Context) error {
+			if err := m.Check(ctx); err != nil {
+				return err
+			}
+			pending, err := m.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending migrations, latest %d_%s", len(pending), pending[len(pending)-1].Version, pending[len(pending)-1].Name)
+			}
+			return nil
+		}})
+	}
+
+	if sessions != nil {
+		c.Add(health.Check{Name: "sessions", Func: func(ctx context.Context) error {
+			_, err := sessions.DbPool.ExecContext(ctx, "SELECT 1 FROM http_sessions LIMIT 1")
+			return err
+		}})
+	}
+
+	c.Add(health.Check{Name: "backfills", Optional: true, Func: func(ctx context.Context) error {
+		if stalled := runner.Stalled(backfillStallTimeout); len(stalled) > 0 {
+			return fmt.Errorf("%d backfills made no progress for %s", len(stalled), backfillStallTimeout)
+		}
+		return nil
+	}})
+
+	if p, ok := backend.(interface{ Ping(context.Context) error }); ok {
+		c.Add(health.Check{Name: "summarizer", Optional: true, Func: p.Ping})
+	}
+	return c, nil
+}
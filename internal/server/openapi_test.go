@@ -0,0 +1,216 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"main/internal/auth"
+	"main/internal/backfill"
+	"main/internal/config"
+	"main/internal/database"
+	"main/internal/handler"
+	"main/internal/middleware"
+	"main/internal/model"
+	"main/internal/openapi"
+	"main/internal/summarizer"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type contract struct {
+	t      *testing.T
+	engine *gin.Engine
+	doc    *openapi3.T
+	router routers.Router
+	cookie []*http.Cookie
+}
+
+func newContract(t *testing.T) *contract {
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	doc, err := openapi3.NewLoader().LoadFromData(openapi.Spec)
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(ctx))
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	db := database.NewMemory()
+	user, err := db.UpsertUser(ctx, &model.User{Email: "ada@example.com", Name: "Ada", TokenExpiry: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NoError(t, db.SaveMessage(ctx, &model.Message{ID: "m1", UserID: user.ID, Subject: "Invoice", Sender: "billing@example.com", LabelIDs: []string{"INBOX"}, ReceivedAt: time.Now()}))
+	store := sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	h := handler.New(db, store, &config.Config{}, nil, nil,
+		handler.WithActionStore(db),
+		handler.WithMessageStore(db),
+		handler.WithPreferenceStore(db),
+		handler.WithSummaryCache(summarizer.NewCached(summarizer.NewOffline(0), db, 10)),
+		handler.WithBackfill(backfill.NewRunner(db, db, 1, nil)),
+	)
+
+	r := gin.New()
+	r.Use(middleware.Errors())
+	routes(r, h, middleware.Auth(store, db))
+
+	// Sign in outside /api so the route walk ignores it.
+	r.GET("/test/login", func(c *gin.Context) {
+		session, _ := auth.GetSession(store, c.Request)
+		session.Values["user_id"] = user.ID
+		require.NoError(t, session.Save(c.Request, c.Writer))
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/login", nil))
+
+	return &contract{t: t, engine: r, doc: doc, router: router, cookie: w.Result().Cookies()}
+}
+
+// call sends a request, signed in when signedIn is set, and validates the
+// request and response against the document.
+func (c *contract) call(method, target string, body any, signedIn bool) *httptest.ResponseRecorder {
+	t := c.t
+
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(raw))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if signedIn {
+		for _, cookie := range c.cookie {
+			req.AddCookie(cookie)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c.engine.ServeHTTP(w, req)
+
+	route, params, err := c.router.FindRoute(httptest.NewRequest(method, target, bytes.NewReader(raw)))
+	require.NoError(t, err, "%s %s is not in openapi.json", method, target)
+
+	ctx := context.Background()
+	in := &openapi3filter.RequestValidationInput{
+		Request:    httptest.NewRequest(method, target, bytes.NewReader(raw)),
+		PathParams: params,
+		Route:      route,
+		Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}
+	in.Request.Header = req.Header
+	if w.Code < http.StatusBadRequest {
+		require.NoError(t, openapi3filter.ValidateRequest(ctx, in), "%s %s", method, target)
+	}
+
+	opts := &openapi3filter.Options{IncludeResponseStatus: true}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		// Markdown and event streams have no schema to check.
+		opts.ExcludeResponseBody = true
+	}
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: in,
+		Status:                 w.Code,
+		Header:                 w.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+		Options:                opts,
+	})
+	assert.NoError(t, err, "%s %s answered %d: %s", method, target, w.Code, w.Body.String())
+	return w
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+func TestRoutesAreDescribed(t *testing.T) {
+	c := newContract(t)
+
+	routed := map[string]bool{}
+	for _, route := range c.engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		routed[route.Method+" "+path] = true
+
+		item := c.doc.Paths.Find(path)
+		if assert.NotNil(t, item, "%s is missing from openapi.json", path) {
+			assert.NotNil(t, item.GetOperation(route.Method), "%s %s is missing from openapi.json", route.Method, path)
+		}
+	}
+
+	for path, item := range c.doc.Paths.Map() {
+		for method := range item.Operations() {
+			assert.True(t, routed[method+" "+path], "openapi.json describes %s %s, which is not routed", method, path)
+		}
+	}
+}
+
+func TestUnauthenticatedResponses(t *testing.T) {
+	c := newContract(t)
+
+	for _, route := range c.engine.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		op := c.doc.Paths.Find(path)
+		if op == nil || op.GetOperation(route.Method) == nil {
+			continue
+		}
+		if security := op.GetOperation(route.Method).Security; security != nil && len(*security) == 0 {
+			continue
+		}
+
+		target := ginParam.ReplaceAllString(route.Path, "x")
+		w := c.call(route.Method, target, nil, false)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", route.Method, target)
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	c := newContract(t)
+
+	defaults := summarizer.DefaultPreferences("u1")
+	prefs := map[string]any{
+		"format":           defaults.Format,
+		"length":           defaults.Length,
+		"focusActionItems": true,
+		"language":         defaults.Language,
+		"defaultProfile":   defaults.DefaultProfile,
+		"profiles":         []model.PromptProfile{},
+	}
+
+	tests := []struct {
+		method, target string
+		body           any
+		want           int
+	}{
+		{http.MethodGet, "/api/", nil, http.StatusOK},
+		{http.MethodGet, "/api/openapi.json", nil, http.StatusOK},
+		{http.MethodGet, "/api/me", nil, http.StatusOK},
+		{http.MethodGet, "/api/me/preferences", nil, http.StatusOK},
+		{http.MethodPut, "/api/me/preferences", prefs, http.StatusOK},
+		{http.MethodPut, "/api/me/preferences", map[string]any{"format": "haiku"}, http.StatusBadRequest},
+		{http.MethodGet, "/api/actions/settings", nil, http.StatusOK},
+		{http.MethodGet, "/api/actions/log", nil, http.StatusOK},
+		{http.MethodGet, "/api/search?q=invoice", nil, http.StatusOK},
+		{http.MethodGet, "/api/search?after=yesterday", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/search/semantic", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/backfill", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := c.call(tt.method, tt.target, tt.body, true)
+		assert.Equal(t, tt.want, w.Code, "%s %s: %s", tt.method, tt.target, w.Body.String())
+	}
+}
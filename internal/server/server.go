@@ -2,68 +2,234 @@ package server
 
 import (
 	"main/internal/auth"
+	"main/internal/backfill"
 	"main/internal/config"
 	"main/internal/database"
+	"main/internal/embedding"
 	"main/internal/handler"
+	"main/internal/lifecycle"
+	"main/internal/mailbox"
+	"main/internal/metrics"
 	"main/internal/middleware"
+	"main/internal/openapi"
+	"main/internal/summarizer"
+	"net/http"
 	"time"
 
+	"github.com/antonlindstrom/pgstore"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/google"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
 	*gin.Engine
-	db    database.UserStore
-	store sessions.Store
+	cfg *config.Config
+	db  database.Store
+	// pgSessions is nil when sessions are kept in memory.
+	pgSessions *pgstore.PGStore
+	backfill   *backfill.Runner
 }
 
-func New(cfg *config.Config, db database.UserStore) (*Server, error) {
-	r := gin.Default()
+func New(cfg *config.Config, db database.Store) (*Server, error) {
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Tracing(), middleware.Logger(), middleware.Errors(), middleware.Recovery())
 
-	store, err := auth.NewStore(cfg.DatabaseURL, []byte(cfg.SessionSecret))
-	if err != nil {
-		return nil, err
+	cookie := auth.CookieOptions(cfg.CookieSecure, cfg.SameSite())
+	var store sessions.Store
+	var pgSessions *pgstore.PGStore
+	if cfg.Store == config.StoreMemory {
+		store = auth.NewMemoryStore(cookie, []byte(cfg.SessionSecret))
+	} else {
+		var err error
+		pgSessions, err = auth.NewStore(cfg.DatabaseURL, cookie, []byte(cfg.SessionSecret))
+		if err != nil {
+			return nil, err
+		}
+		store = pgSessions
 	}
 
-	googleScope := []string{"https://www.googleapis.com/auth/gmail.modify", "https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"}
-
-	gp := google.New(cfg.ClientID, cfg.ClientSecret, cfg.ClientCallbackURL, googleScope...)
-
-	gp.SetPrompt("consent")
+	gp := NewProvider(cfg)
 
 	goth.UseProviders(gp)
+	// gothic only reads its state store from this package variable.
+	gothic.Store = auth.NewStateStore(cookie, []byte(cfg.SessionSecret))
 
 	auth := auth.NewGothicAuthenticator()
 
 	r.LoadHTMLGlob("templates/*")
 
+	r.Use(middleware.Metrics())
+
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
+		AllowOrigins:     cfg.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	h := handler.New(db, store, cfg, gp, auth)
+	backend := newBackend(cfg)
+	sum := newSummarizer(cfg, backend, db)
+	runner := backfill.NewRunner(db, db, cfg.FetchWorkers, backfill.Summarize(sum, db, db))
+
+	checker, err := newChecker(db, pgSessions, runner, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	h := handler.New(db, store, cfg, gp, auth,
+		handler.WithGmailClient(mailbox.NewClientFunc(cfg.OAuth2(), db, mailbox.NewLimiter(0, 0), mailbox.DefaultBackoff)),
+		handler.WithActionStore(db),
+		handler.WithSubscriptionStore(db),
+		handler.WithSummarizer(summarizer.NewInstrumented(backend)),
+		handler.WithSummaryCache(sum),
+		handler.WithMessageStore(db),
+		handler.WithEmbeddings(newEmbedder(cfg), db),
+		handler.WithSummaryStore(db),
+		handler.WithPreferenceStore(db),
+		handler.WithBackfill(runner),
+		handler.WithHealth(checker),
+	)
+
+	if sqlDB, ok := db.(*database.DB); ok {
+		metrics.RegisterDB("app", sqlDB.DB)
+	}
+	if pgSessions != nil {
+		metrics.RegisterDB("sessions", pgSessions.DbPool)
+	}
+
+	routes(r, h, middleware.Auth(store, db))
+
+	return &Server{r, cfg, db, pgSessions, runner}, nil
+}
+
+// routes registers the probes and the API on r, guarding the user's routes
+// with authorize. Every /api route must be described in openapi.json.
+func routes(r *gin.Engine, h *handler.Handler, authorize gin.HandlerFunc) {
+	// Probes are unauthenticated and outside /api, where load balancers
+	// expect them.
+	r.GET("/healthz", h.Healthz)
+	r.GET("/readyz", h.Readyz)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	api := r.Group("/api")
 	api.GET("/", h.Home)
 	api.GET("/auth/:provider", h.SignInWithProvider)
 	api.GET("/auth/:provider/callback", h.CallbackHandler)
 	api.GET("/auth/refresh", h.Refresh)
+	api.GET("/openapi.json", gin.WrapH(openapi.Handler()))
 
 	authorized := api.Group("/")
-	authorized.Use(middleware.Auth(store, db))
+	authorized.Use(authorize)
 	{
 		authorized.GET("/me", h.Me)
+		authorized.GET("/me/preferences", h.Preferences)
+		authorized.PUT("/me/preferences", h.UpdatePreferences)
 		authorized.GET("/success", h.Success)
 		authorized.GET("/summaries", h.Summaries)
+		authorized.GET("/actions/settings", h.ActionSettings)
+		authorized.PUT("/actions/settings", h.UpdateActionSettings)
+		authorized.GET("/actions/log", h.ActionLog)
+		authorized.POST("/actions/undo", h.UndoActions)
+		authorized.GET("/subscriptions", h.Subscriptions)
+		authorized.POST("/subscriptions/:id/unsubscribe", h.Unsubscribe)
+		authorized.POST("/messages/fetch", h.FetchMessages)
+		authorized.GET("/messages/:id/summary", h.MessageSummary)
+		authorized.GET("/messages/:id/summary/stream", h.StreamMessageSummary)
+		authorized.DELETE("/messages/:id/summary/cache", h.InvalidateMessageSummaryCache)
+		authorized.POST("/messages/:id/draft-reply", h.DraftReply)
+		authorized.GET("/search", h.Search)
+		authorized.GET("/search/semantic", h.SemanticSearch)
+		authorized.GET("/backfill", h.BackfillStatus)
+		authorized.POST("/backfill", h.StartBackfill)
+		authorized.DELETE("/backfill", h.StopBackfill)
+	}
+}
+
+// Components returns what the server runs in the background, for the
+// lifecycle manager: the expired session cleanup, the backfill workers and
+// the HTTP server itself, in start order.
+func (s *Server) Components() []lifecycle.Component {
+	var components []lifecycle.Component
+	// The memory session store drops expired sessions itself.
+	if s.pgSessions != nil && s.cfg.SessionCleanupInterval > 0 {
+		components = append(components, lifecycle.Scheduler("session cleanup",
+			func() (chan<- struct{}, <-chan struct{}) {
+				return s.pgSessions.Cleanup(s.cfg.SessionCleanupInterval)
+			},
+			s.pgSessions.StopCleanup))
+	}
+	return append(components,
+		lifecycle.Worker("backfills", s.backfill.Open, s.backfill.Shutdown),
+		lifecycle.HTTPServer(s.HTTPServer()),
+	)
+}
+
+// Close releases the connection pool of the session store. It must be
+// called after the components stopped.
+func (s *Server) Close() error {
+	if s.pgSessions != nil {
+		s.pgSessions.Close()
+	}
+	return nil
+}
+
+// HTTPServer returns an http.Server for the engine with the configured
+// address and timeouts.
+func (s *Server) HTTPServer() *http.Server {
+	return &http.Server{
+		Addr:              s.cfg.Addr(),
+		Handler:           s.Engine,
+		ReadTimeout:       s.cfg.ReadTimeout,
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		WriteTimeout:      s.cfg.WriteTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+	}
+}
+
+// NewProvider creates the Google OAuth provider with the configured scopes.
+func NewProvider(cfg *config.Config) *google.Provider {
+	gp := google.New(cfg.ClientID, cfg.ClientSecret, cfg.ClientCallbackURL, cfg.GmailScopes...)
+	gp.SetPrompt("consent")
+	return gp
+}
+
+// NewSummarizer creates the configured summarizer behind the summary cache.
+func NewSummarizer(cfg *config.Config, cache database.SummaryCacheStore) *summarizer.Cached {
+	return newSummarizer(cfg, newBackend(cfg), cache)
+}
+
+// newBackend uses the configured LLM endpoint, or the offline summarizer
+// when no API key is set.
+func newBackend(cfg *config.Config) summarizer.Summarizer {
+	if cfg.SummarizerAPIKey == "" {
+		return summarizer.NewOffline(0)
 	}
+	return summarizer.NewOpenAI(cfg.SummarizerURL, cfg.SummarizerAPIKey, cfg.SummarizerModel, nil)
+}
 
-	return &Server{r, db, store}, nil
+// newSummarizer summarizes long content with backend in chunks, behind the
+// summary cache.
+func newSummarizer(cfg *config.Config, backend summarizer.Summarizer, cache database.SummaryCacheStore) *summarizer.Cached {
+	chunked := summarizer.NewChunked(summarizer.NewInstrumented(backend), summarizer.ChunkOptions{
+		ChunkTokens:   cfg.SummaryChunkTokens,
+		OverlapTokens: cfg.SummaryChunkOverlap,
+		Workers:       cfg.SummaryWorkers,
+	})
+	return summarizer.NewCached(chunked, cache, cfg.SummaryCacheSize)
+}
+
+// newEmbedder uses the configured embeddings endpoint, or the local hashing
+// embedder when no API key is set.
+func newEmbedder(cfg *config.Config) embedding.Embedder {
+	if cfg.EmbeddingsAPIKey == "" {
+		return embedding.NewHashing(0)
+	}
+	return embedding.NewOpenAI(cfg.EmbeddingsURL, cfg.EmbeddingsAPIKey, cfg.EmbeddingsModel, nil)
 }
@@ -0,0 +1,165 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"main/internal/mailbox"
+	"main/internal/model"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const (
+	headerListUnsubscribe     = "List-Unsubscribe"
+	headerListUnsubscribePost = "List-Unsubscribe-Post"
+	oneClickValue             = "List-Unsubscribe=One-Click"
+)
+
+// Target is where and how a sender accepts unsubscribe requests.
+type Target struct {
+	URL      string
+	Mailto   string
+	OneClick bool
+}
+
+// ParseHeaders extracts unsubscribe targets from the List-Unsubscribe and
+// List-Unsubscribe-Post header values (RFC 2369, RFC 8058). Only https URLs
+// are kept since one-click requests must not be sent in the clear.
+func ParseHeaders(listUnsubscribe, listUnsubscribePost string) Target {
+	var t Target
+
+	for _, part := range strings.Split(listUnsubscribe, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "<") || !strings.HasSuffix(part, ">") {
+			continue
+		}
+		raw := strings.TrimSpace(part[1 : len(part)-1])
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			if t.URL == "" {
+				t.URL = raw
+			}
+		case "mailto":
+			if t.Mailto == "" {
+				t.Mailto = raw
+			}
+		}
+	}
+
+	t.OneClick = t.URL != "" && strings.EqualFold(strings.TrimSpace(listUnsubscribePost), oneClickValue)
+
+	return t
+}
+
+// Aggregate groups messages carrying a List-Unsubscribe header by sender.
+// Messages must be fetched with at least the From and List-Unsubscribe
+// headers and their label ids.
+func Aggregate(userID string, msgs []*gmail.Message) []model.Subscription {
+	bySender := map[string]*model.Subscription{}
+
+	for _, m := range msgs {
+		t := ParseHeaders(mailbox.Header(m, headerListUnsubscribe), mailbox.Header(m, headerListUnsubscribePost))
+		if t.URL == "" && t.Mailto == "" {
+			continue
+		}
+
+		from, err := mail.ParseAddress(mailbox.Header(m, "From"))
+		if err != nil {
+			continue
+		}
+		sender := strings.ToLower(from.Address)
+		seen := time.UnixMilli(m.InternalDate)
+
+		s, ok := bySender[sender]
+		if !ok {
+			s = &model.Subscription{UserID: userID, Sender: sender}
+			bySender[sender] = s
+		}
+
+		s.MessageCount++
+		if !mailbox.HasLabel(m, "UNREAD") {
+			s.ReadCount++
+		}
+
+		// Keep the details of the most recent message since senders rotate
+		// their unsubscribe links.
+		if !seen.Before(s.LastSeenAt) {
+			s.LastSeenAt = seen
+			s.SenderName = from.Name
+			s.UnsubscribeURL = t.URL
+			s.UnsubscribeMailto = t.Mailto
+			s.OneClick = t.OneClick
+		}
+	}
+
+	subs := make([]model.Subscription, 0, len(bySender))
+	for _, s := range bySender {
+		subs = append(subs, *s)
+	}
+	Rank(subs)
+
+	return subs
+}
+
+// Rank orders subscriptions so that high volume senders that are rarely
+// read come first.
+func Rank(subs []model.Subscription) {
+	sort.SliceStable(subs, func(i, j int) bool {
+		si, sj := score(&subs[i]), score(&subs[j])
+		if si != sj {
+			return si > sj
+		}
+		if subs[i].MessageCount != subs[j].MessageCount {
+			return subs[i].MessageCount > subs[j].MessageCount
+		}
+		return subs[i].Sender < subs[j].Sender
+	})
+}
+
+func score(s *model.Subscription) float64 {
+	return float64(s.MessageCount) * (1 - s.ReadRate())
+}
+
+// Scan fetches the headers of the most recent messages in the mailbox.
+func Scan(ctx context.Context, c *mailbox.Client, limit int64) ([]*gmail.Message, error) {
+	var list *gmail.ListMessagesResponse
+	err := c.Call(ctx, "messages.list", func() error {
+		var err error
+		list, err = c.Service.Users.Messages.List(mailbox.User).MaxResults(limit).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	ids := make([]string, len(list.Messages))
+	for i, ref := range list.Messages {
+		ids[i] = ref.Id
+	}
+
+	results, err := c.GetMessages(ctx, ids, "metadata", "From", headerListUnsubscribe, headerListUnsubscribePost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	msgs := make([]*gmail.Message, 0, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("failed to get message %s: %w", ids[i], r.Err)
+		}
+		msgs = append(msgs, r.Message)
+	}
+
+	return msgs, nil
+}
@@ -0,0 +1,217 @@
+package subscriptions
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/gmail/v1"
+
+	"main/internal/model"
+)
+
+func TestParseHeaders(t *testing.T) {
+	testCases := []struct {
+		name     string
+		list     string
+		post     string
+		expected Target
+	}{
+		{
+			name:     "One-click https and mailto",
+			list:     "<mailto:leave@example.com?subject=unsub>, <https://example.com/u?id=1>",
+			post:     "List-Unsubscribe=One-Click",
+			expected: Target{URL: "https://example.com/u?id=1", Mailto: "mailto:leave@example.com?subject=unsub", OneClick: true},
+		},
+		{
+			name:     "Https without post header",
+			list:     "<https://example.com/u>",
+			expected: Target{URL: "https://example.com/u"},
+		},
+		{
+			name:     "Plain http is ignored",
+			list:     "<http://example.com/u>, <mailto:leave@example.com>",
+			post:     "List-Unsubscribe=One-Click",
+			expected: Target{Mailto: "mailto:leave@example.com"},
+		},
+		{
+			name:     "Malformed entries",
+			list:     "mailto:leave@example.com, <>",
+			expected: Target{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseHeaders(tc.list, tc.post))
+		})
+	}
+}
+
+func message(id, from, list string, unread bool, date int64) *gmail.Message {
+	m := &gmail.Message{
+		Id:           id,
+		InternalDate: date,
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: from},
+			{Name: "List-Unsubscribe", Value: list},
+		}},
+	}
+	if unread {
+		m.LabelIds = []string{"UNREAD"}
+	}
+	return m
+}
+
+func TestAggregate(t *testing.T) {
+	msgs := []*gmail.Message{
+		message("1", "News <news@example.com>", "<mailto:u@example.com>", true, 1),
+		message("2", "News <NEWS@example.com>", "<mailto:u2@example.com>", true, 2),
+		message("3", "News <news@example.com>", "<mailto:u@example.com>", false, 0),
+		message("4", "Friend <friend@example.com>", "", true, 3),
+		message("5", "Shop <shop@example.com>", "<https://shop.example.com/u>", false, 4),
+	}
+
+	subs := Aggregate("user-123", msgs)
+	require.Len(t, subs, 2)
+
+	assert.Equal(t, "news@example.com", subs[0].Sender)
+	assert.Equal(t, 3, subs[0].MessageCount)
+	assert.Equal(t, 1, subs[0].ReadCount)
+	assert.Equal(t, "mailto:u2@example.com", subs[0].UnsubscribeMailto, "latest message wins")
+
+	assert.Equal(t, "shop@example.com", subs[1].Sender)
+	assert.Equal(t, 1.0, subs[1].ReadRate())
+}
+
+func TestUnsubscriber_OneClick(t *testing.T) {
+	var body, contentType string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		contentType = r.Header.Get("Content-Type")
+		assert.Equal(t, http.MethodPost, r.Method)
+	}))
+	defer srv.Close()
+
+	sub := &model.Subscription{UnsubscribeURL: srv.URL + "/u", OneClick: true, UnsubscribeMailto: "mailto:u@example.com"}
+
+	method, err := NewUnsubscriber(srv.Client()).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	require.NoError(t, err)
+
+	assert.Equal(t, MethodOneClick, method)
+	assert.Equal(t, "List-Unsubscribe=One-Click", body)
+	assert.Equal(t, "application/x-www-form-urlencoded", contentType)
+}
+
+func TestUnsubscriber_OneClickFailure(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	sub := &model.Subscription{UnsubscribeURL: srv.URL, OneClick: true}
+
+	_, err := NewUnsubscriber(srv.Client()).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	assert.ErrorContains(t, err, "410")
+}
+
+func TestUnsubscriber_Unsubscribed(t *testing.T) {
+	now := time.Now()
+	sub := &model.Subscription{UnsubscribeMailto: "mailto:u@example.com", UnsubscribedAt: &now}
+
+	_, err := NewUnsubscriber(nil).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	assert.ErrorIs(t, err, ErrUnsubscribed)
+}
+
+func TestUnsubscriber_NotPublic(t *testing.T) {
+	var called bool
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// The default client refuses the loopback address of the test server.
+	sub := &model.Subscription{UnsubscribeURL: srv.URL, OneClick: true}
+	_, err := NewUnsubscriber(nil).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	assert.ErrorIs(t, err, ErrNotPublic)
+	assert.False(t, called)
+}
+
+func TestNewClient_NoRedirects(t *testing.T) {
+	var followed bool
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			followed = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	client := NewClient()
+	// Reach the test server, keeping the redirect policy.
+	client.Transport = srv.Client().Transport
+
+	sub := &model.Subscription{UnsubscribeURL: srv.URL, OneClick: true}
+	_, err := NewUnsubscriber(client).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	assert.ErrorContains(t, err, "307")
+	assert.False(t, followed)
+}
+
+func TestPublicOnly(t *testing.T) {
+	testCases := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:443", false},
+		{"[::1]:443", false},
+		{"10.0.0.8:443", false},
+		{"172.16.3.4:443", false},
+		{"192.168.1.1:443", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:443", false},
+		{"0.0.0.0:443", false},
+		{"[fd00::1]:443", false},
+		{"[fe80::1]:443", false},
+		{"[::ffff:127.0.0.1]:443", false},
+		{"224.0.0.1:443", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			err := publicOnly("tcp", tc.address, nil)
+			if tc.public {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrNotPublic)
+			}
+		})
+	}
+}
+
+func TestUnsubscriber_Manual(t *testing.T) {
+	sub := &model.Subscription{UnsubscribeURL: "https://example.com/u"}
+
+	_, err := NewUnsubscriber(nil).Unsubscribe(context.Background(), nil, &model.User{}, sub)
+	assert.ErrorIs(t, err, ErrManualUnsubscribe)
+}
+
+func TestComposeMailto(t *testing.T) {
+	out, err := ComposeMailto("me@example.com", "mailto:leave%2Blist@example.com?subject=Remove%20me")
+	require.NoError(t, err)
+
+	raw := string(out.Bytes())
+	assert.Contains(t, raw, "From: me@example.com\r\n")
+	assert.Contains(t, raw, "To: leave+list@example.com\r\n")
+	assert.Contains(t, raw, "Subject: Remove me\r\n")
+	assert.True(t, strings.HasSuffix(raw, "\r\n\r\nunsubscribe"))
+}
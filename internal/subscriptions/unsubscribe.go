@@ -0,0 +1,197 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"main/internal/mailbox"
+	"main/internal/model"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Method describes how an unsubscribe request was delivered.
+type Method string
+
+const (
+	MethodOneClick Method = "one-click"
+	MethodMailto   Method = "mailto"
+)
+
+var (
+	// ErrManualUnsubscribe is returned when the sender only offers a web
+	// page that the user has to visit themselves.
+	ErrManualUnsubscribe = errors.New("sender requires a manual unsubscribe")
+	ErrNoTarget          = errors.New("sender has no unsubscribe target")
+	// ErrUnsubscribed is returned for a subscription that was already
+	// unsubscribed from.
+	ErrUnsubscribed = errors.New("already unsubscribed")
+	// ErrNotPublic is returned when a one-click URL resolves to an address
+	// that is not on the public internet.
+	ErrNotPublic = errors.New("address is not public")
+)
+
+// oneClickTimeout bounds a one-click request, connection included.
+const oneClickTimeout = 10 * time.Second
+
+// nonPublic lists the special-purpose ranges that net/netip does not
+// classify on its own.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Unsubscriber sends unsubscribe requests on behalf of a user.
+type Unsubscriber struct {
+	client *http.Client
+}
+
+// NewUnsubscriber creates a new Unsubscriber. The client is used for RFC 8058
+// one-click requests; NewClient is used when it is nil.
+func NewUnsubscriber(client *http.Client) *Unsubscriber {
+	if client == nil {
+		client = NewClient()
+	}
+	return &Unsubscriber{client}
+}
+
+// NewClient returns a client for one-click requests. Their URL comes from
+// the sender, so it only connects to public addresses, checked after DNS
+// resolution, and does not follow redirects.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{Timeout: oneClickTimeout, Control: publicOnly}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, past the check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   oneClickTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicOnly is a net.Dialer Control refusing to connect to loopback,
+// private, link-local and other special-purpose addresses.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return fmt.Errorf("%s: %w", ip, ErrNotPublic)
+	}
+	for _, p := range nonPublic {
+		if p.Contains(ip) {
+			return fmt.Errorf("%s: %w", ip, ErrNotPublic)
+		}
+	}
+	return nil
+}
+
+// Unsubscribe prefers a one-click POST and falls back to sending the mailto
+// request from the user's mailbox.
+func (u *Unsubscriber) Unsubscribe(ctx context.Context, c *mailbox.Client, user *model.User, sub *model.Subscription) (Method, error) {
+	switch {
+	case sub.UnsubscribedAt != nil:
+		return "", ErrUnsubscribed
+	case sub.OneClick && sub.UnsubscribeURL != "":
+		return MethodOneClick, u.oneClick(ctx, sub.UnsubscribeURL)
+	case sub.UnsubscribeMailto != "":
+		return MethodMailto, u.mailto(ctx, c, user, sub.UnsubscribeMailto)
+	case sub.UnsubscribeURL != "":
+		return "", ErrManualUnsubscribe
+	default:
+		return "", ErrNoTarget
+	}
+}
+
+func (u *Unsubscriber) oneClick(ctx context.Context, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(oneClickValue))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("one-click unsubscribe failed: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("one-click unsubscribe failed with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (u *Unsubscriber) mailto(ctx context.Context, c *mailbox.Client, user *model.User, target string) error {
+	out, err := ComposeMailto(user.Email, target)
+	if err != nil {
+		return err
+	}
+
+	err = c.Call(ctx, "messages.send", func() error {
+		_, err := c.Service.Users.Messages.Send(mailbox.User, &gmail.Message{Raw: out.Raw()}).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send unsubscribe email: %w", err)
+	}
+	return nil
+}
+
+// ComposeMailto builds the message described by a mailto: URL (RFC 6068).
+func ComposeMailto(from, target string) (*mailbox.Outgoing, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Scheme, "mailto") {
+		return nil, fmt.Errorf("not a mailto url: %q", target)
+	}
+
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	subject := q.Get("subject")
+	if subject == "" {
+		subject = "unsubscribe"
+	}
+	body := q.Get("body")
+	if body == "" {
+		body = "unsubscribe"
+	}
+
+	return &mailbox.Outgoing{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	}, nil
+}
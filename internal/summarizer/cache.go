@@ -0,0 +1,216 @@
+package summarizer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"main/internal/database"
+	"main/internal/metrics"
+	"main/internal/model"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultCacheSize is the number of summaries kept in memory.
+const DefaultCacheSize = 1000
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// CleanMarkdown normalizes markdown so that content differing only in line
+// endings or surrounding whitespace hashes to the same key.
+func CleanMarkdown(markdown string) string {
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+
+	lines := strings.Split(markdown, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// CacheKey identifies the output of a model for a request. The rendered
+// instructions carry the prompt profile, so changing the content, the
+// profile or the model all produce a new key.
+func CacheKey(model string, req Request) string {
+	h := sha256.New()
+	for _, part := range []string{model, req.Instructions, strconv.Itoa(req.MaxTokens), CleanMarkdown(req.Content)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CacheStats reports how the cache has been used since startup.
+type CacheStats struct {
+	// MemoryHits were served from the in-memory LRU.
+	MemoryHits int64 `json:"memoryHits"`
+	// StoreHits were served from the persistent store.
+	StoreHits int64 `json:"storeHits"`
+	Misses    int64 `json:"misses"`
+	// Errors counts failed store reads and writes. They never fail a
+	// summary, the backend is used instead.
+	Errors  int64   `json:"errors"`
+	Entries int     `json:"entries"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// Cached serves repeated requests from an in-memory LRU backed by a
+// persistent store, and only calls the inner summarizer on a miss.
+type Cached struct {
+	inner  Summarizer
+	store  database.SummaryCacheStore
+	memory *lru[string, *Response]
+
+	memoryHits atomic.Int64
+	storeHits  atomic.Int64
+	misses     atomic.Int64
+	errors     atomic.Int64
+}
+
+// NewCached wraps inner with a cache of size entries in memory. store may be
+// nil to only cache in memory.
+func NewCached(inner Summarizer, store database.SummaryCacheStore, size int) *Cached {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Cached{inner: inner, store: store, memory: newLRU[string, *Response](size)}
+}
+
+func (c *Cached) Model() string {
+	return c.inner.Model()
+}
+
+// Key returns the cache key of req for the wrapped model.
+func (c *Cached) Key(req Request) string {
+	return CacheKey(c.Model(), req)
+}
+
+func (c *Cached) Summarize(ctx context.Context, req Request) (*Response, error) {
+	key := c.Key(req)
+	if res, ok := c.lookup(ctx, key); ok {
+		return res, nil
+	}
+
+	res, err := c.inner.Summarize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.save(ctx, key, res)
+	return res, nil
+}
+
+// SummarizeStream emits a cached summary as a single token and streams the
+// inner summarizer on a miss.
+func (c *Cached) SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error) {
+	key := c.Key(req)
+	if res, ok := c.lookup(ctx, key); ok {
+		if err := onToken(res.Text); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	res, err := Stream(ctx, c.inner, req, onToken)
+	if err != nil {
+		return nil, err
+	}
+	// A stream cancelled by the client may have returned early; only keep
+	// complete output.
+	if ctx.Err() == nil {
+		c.save(ctx, key, res)
+	}
+	return res, nil
+}
+
+// Invalidate drops a single entry.
+func (c *Cached) Invalidate(ctx context.Context, key string) error {
+	c.memory.remove(key)
+	if c.store == nil {
+		return nil
+	}
+	return c.store.DeleteCachedSummary(ctx, key)
+}
+
+// InvalidateModel drops every entry produced by model, or every entry when
+// model is empty, and returns how many were removed from the store.
+func (c *Cached) InvalidateModel(ctx context.Context, model string) (int64, error) {
+	// The memory cache is small and cheap to refill, so it is cleared
+	// entirely rather than scanned for the model.
+	c.memory.clear()
+	if c.store == nil {
+		return 0, nil
+	}
+	return c.store.DeleteCachedSummaries(ctx, model)
+}
+
+// Stats returns the hit and miss counters.
+func (c *Cached) Stats() CacheStats {
+	s := CacheStats{
+		MemoryHits: c.memoryHits.Load(),
+		StoreHits:  c.storeHits.Load(),
+		Misses:     c.misses.Load(),
+		Errors:     c.errors.Load(),
+		Entries:    c.memory.len(),
+	}
+	if total := s.MemoryHits + s.StoreHits + s.Misses; total > 0 {
+		s.HitRate = float64(s.MemoryHits+s.StoreHits) / float64(total)
+	}
+	return s
+}
+
+func (c *Cached) lookup(ctx context.Context, key string) (*Response, bool) {
+	if res, ok := c.memory.get(key); ok {
+		c.memoryHits.Add(1)
+		metrics.SummaryCacheLookups.WithLabelValues("memory").Inc()
+		return copyResponse(res), true
+	}
+
+	if c.store != nil {
+		e, err := c.store.GetCachedSummary(ctx, key)
+		if err != nil {
+			c.errors.Add(1)
+			metrics.SummaryCacheErrors.Inc()
+		} else if e != nil {
+			res := &Response{
+				Text:  e.Text,
+				Usage: Usage{PromptTokens: e.PromptTokens, CompletionTokens: e.CompletionTokens},
+			}
+			c.memory.put(key, res)
+			c.storeHits.Add(1)
+			metrics.SummaryCacheLookups.WithLabelValues("store").Inc()
+			return copyResponse(res), true
+		}
+	}
+
+	c.misses.Add(1)
+	metrics.SummaryCacheLookups.WithLabelValues("miss").Inc()
+	return nil, false
+}
+
+func (c *Cached) save(ctx context.Context, key string, res *Response) {
+	c.memory.put(key, copyResponse(res))
+	if c.store == nil {
+		return
+	}
+
+	err := c.store.SaveCachedSummary(ctx, &model.CachedSummary{
+		Key:              key,
+		Model:            c.Model(),
+		Text:             res.Text,
+		PromptTokens:     res.Usage.PromptTokens,
+		CompletionTokens: res.Usage.CompletionTokens,
+	})
+	if err != nil {
+		c.errors.Add(1)
+		metrics.SummaryCacheErrors.Inc()
+	}
+}
+
+// copyResponse keeps callers from mutating cached values.
+func copyResponse(res *Response) *Response {
+	cp := *res
+	return &cp
+}
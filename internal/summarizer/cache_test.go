@@ -0,0 +1,169 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/database"
+	"main/internal/model"
+)
+
+// countingSummarizer counts backend calls.
+type countingSummarizer struct {
+	model string
+	calls int
+}
+
+func (s *countingSummarizer) Model() string { return s.model }
+
+func (s *countingSummarizer) Summarize(ctx context.Context, req Request) (*Response, error) {
+	s.calls++
+	return &Response{Text: "summary of " + req.Content, Usage: Usage{PromptTokens: 10, CompletionTokens: 2}}, nil
+}
+
+// mapCacheStore is an in-memory SummaryCacheStore.
+type mapCacheStore struct {
+	entries map[string]*model.CachedSummary
+	err     error
+}
+
+var _ database.SummaryCacheStore = (*mapCacheStore)(nil)
+
+func (s *mapCacheStore) GetCachedSummary(ctx context.Context, key string) (*model.CachedSummary, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.entries[key], nil
+}
+
+func (s *mapCacheStore) SaveCachedSummary(ctx context.Context, e *model.CachedSummary) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.entries[e.Key] = e
+	return nil
+}
+
+func (s *mapCacheStore) DeleteCachedSummary(ctx context.Context, key string) error {
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *mapCacheStore) DeleteCachedSummaries(ctx context.Context, model string) (int64, error) {
+	var n int64
+	for k, e := range s.entries {
+		if model == "" || e.Model == model {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func TestCacheKey(t *testing.T) {
+	req := Request{Instructions: "Summarize.", Content: "# Title\r\n\r\nBody   \n\n\n\nEnd\n"}
+
+	assert.Equal(t, CacheKey("m1", req), CacheKey("m1", Request{Instructions: "Summarize.", Content: "# Title\n\nBody\n\nEnd"}))
+	assert.NotEqual(t, CacheKey("m1", req), CacheKey("m2", req))
+	assert.NotEqual(t, CacheKey("m1", req), CacheKey("m1", Request{Instructions: "Be brief.", Content: req.Content}))
+	assert.NotEqual(t, CacheKey("m1", req), CacheKey("m1", Request{Instructions: req.Instructions, Content: "Other"}))
+}
+
+func TestCached_Summarize(t *testing.T) {
+	ctx := context.Background()
+	req := Request{Instructions: "Summarize.", Content: "Body"}
+
+	t.Run("Memory and store hits skip the backend", func(t *testing.T) {
+		backend := &countingSummarizer{model: "m1"}
+		store := &mapCacheStore{entries: map[string]*model.CachedSummary{}}
+		c := NewCached(backend, store, 10)
+
+		res, err := c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "summary of Body", res.Text)
+
+		res, err = c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "summary of Body", res.Text)
+		assert.Equal(t, 1, backend.calls)
+
+		// A fresh process only has the persistent store.
+		c = NewCached(backend, store, 10)
+		res, err = c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.Usage.CompletionTokens)
+		assert.Equal(t, 1, backend.calls)
+
+		stats := c.Stats()
+		assert.Equal(t, int64(1), stats.StoreHits)
+		assert.Equal(t, 1.0, stats.HitRate)
+	})
+
+	t.Run("Invalidation forces regeneration", func(t *testing.T) {
+		backend := &countingSummarizer{model: "m1"}
+		store := &mapCacheStore{entries: map[string]*model.CachedSummary{}}
+		c := NewCached(backend, store, 10)
+
+		_, err := c.Summarize(ctx, req)
+		require.NoError(t, err)
+		require.NoError(t, c.Invalidate(ctx, c.Key(req)))
+		_, err = c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 2, backend.calls)
+
+		removed, err := c.InvalidateModel(ctx, "m1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), removed)
+		_, err = c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, 3, backend.calls)
+	})
+
+	t.Run("Store errors fall back to the backend", func(t *testing.T) {
+		backend := &countingSummarizer{model: "m1"}
+		c := NewCached(backend, &mapCacheStore{err: errors.New("db down")}, 10)
+
+		res, err := c.Summarize(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, "summary of Body", res.Text)
+		assert.Equal(t, int64(2), c.Stats().Errors)
+	})
+
+	t.Run("Least recently used entries are evicted", func(t *testing.T) {
+		backend := &countingSummarizer{model: "m1"}
+		c := NewCached(backend, nil, 1)
+
+		_, _ = c.Summarize(ctx, Request{Content: "a"})
+		_, _ = c.Summarize(ctx, Request{Content: "b"})
+		_, _ = c.Summarize(ctx, Request{Content: "a"})
+		assert.Equal(t, 3, backend.calls)
+		assert.Equal(t, 1, c.Stats().Entries)
+	})
+}
+
+func TestCached_SummarizeStream(t *testing.T) {
+	ctx := context.Background()
+	c := NewCached(NewOffline(0), nil, 10)
+	req := Request{Content: "First sentence. Second sentence."}
+
+	var first []string
+	_, err := Stream(ctx, c, req, func(token string) error {
+		first = append(first, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Greater(t, len(first), 1)
+
+	var cached []string
+	res, err := Stream(ctx, c, req, func(token string) error {
+		cached = append(cached, token)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{res.Text}, cached)
+	assert.Equal(t, int64(1), c.Stats().MemoryHits)
+}
@@ -0,0 +1,199 @@
+package summarizer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Defaults for chunked summarization, in estimated tokens.
+const (
+	DefaultChunkTokens   = 3000
+	DefaultOverlapTokens = 150
+	DefaultWorkers       = 4
+)
+
+// ChunkOptions configures a Chunked summarizer.
+type ChunkOptions struct {
+	// ChunkTokens is the largest input sent to the backend in one call.
+	ChunkTokens int
+	// OverlapTokens of the previous chunk are repeated at the start of the
+	// next one so that context is not lost at the boundary.
+	OverlapTokens int
+	// Workers bounds how many chunks are summarized at the same time.
+	Workers int
+}
+
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	if o.ChunkTokens <= 0 {
+		o.ChunkTokens = DefaultChunkTokens
+	}
+	if o.OverlapTokens < 0 || o.OverlapTokens >= o.ChunkTokens {
+		o.OverlapTokens = 0
+	}
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+	return o
+}
+
+// Chunked summarizes content larger than a model context window with
+// map-reduce: the content is split into chunks that are summarized
+// concurrently, and the partial summaries are combined hierarchically
+// until they fit in a single final call.
+type Chunked struct {
+	inner Summarizer
+	opts  ChunkOptions
+}
+
+// NewChunked wraps inner with chunked summarization.
+func NewChunked(inner Summarizer, opts ChunkOptions) *Chunked {
+	return &Chunked{inner, opts.withDefaults()}
+}
+
+func (c *Chunked) Model() string {
+	return c.inner.Model()
+}
+
+func (c *Chunked) Summarize(ctx context.Context, req Request) (*Response, error) {
+	reduced, usage, err := c.reduce(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.inner.Summarize(ctx, reduced)
+	if err != nil {
+		return nil, err
+	}
+	res.Usage = addUsage(res.Usage, usage)
+	return res, nil
+}
+
+// SummarizeStream runs the map and reduce passes silently and streams the
+// final pass.
+func (c *Chunked) SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error) {
+	reduced, usage, err := c.reduce(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := Stream(ctx, c.inner, reduced, onToken)
+	if err != nil {
+		return nil, err
+	}
+	res.Usage = addUsage(res.Usage, usage)
+	return res, nil
+}
+
+// reduce shrinks the request content until it fits in a single chunk and
+// returns the request for the final pass.
+func (c *Chunked) reduce(ctx context.Context, req Request) (Request, Usage, error) {
+	var usage Usage
+	content := req.Content
+
+	for level := 0; EstimateTokens(content) > c.opts.ChunkTokens; level++ {
+		chunks := SplitMarkdown(content, c.opts.ChunkTokens, c.opts.OverlapTokens)
+		if len(chunks) <= 1 {
+			break
+		}
+
+		instructions := mapInstructions
+		if level > 0 {
+			instructions = reduceInstructions
+		}
+
+		partials, u, err := c.summarizeAll(ctx, req.Instructions, instructions, chunks)
+		if err != nil {
+			return req, usage, err
+		}
+		usage = addUsage(usage, u)
+
+		next := strings.Join(partials, "\n\n")
+		if EstimateTokens(next) >= EstimateTokens(content) {
+			// The backend is not shrinking its input; stop rather than loop.
+			content = next
+			break
+		}
+		content = next
+	}
+
+	if content != req.Content {
+		req.Instructions = req.Instructions + "\n\n" + finalInstructions
+	}
+	req.Content = content
+	return req, usage, nil
+}
+
+const (
+	mapInstructions    = "The content is part %d of %d of a longer email. Summarize only this part; keep names, dates, amounts and action items."
+	reduceInstructions = "The content is group %d of %d of partial summaries of a longer email. Merge them into one summary without repeating points."
+	finalInstructions  = "The content is a set of partial summaries of a longer email, in order."
+)
+
+// summarizeAll summarizes every chunk with at most Workers calls in flight
+// and returns the partial summaries in chunk order.
+func (c *Chunked) summarizeAll(ctx context.Context, base, format string, chunks []string) ([]string, Usage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partials := make([]string, len(chunks))
+	usages := make([]Usage, len(chunks))
+
+	sem := make(chan struct{}, c.opts.Workers)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	// The first failure cancels the remaining chunks and is the one reported.
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res, err := c.inner.Summarize(ctx, Request{
+				Instructions: strings.TrimSpace(base + "\n\n" + fmt.Sprintf(format, i+1, len(chunks))),
+				Content:      chunk,
+			})
+			if err != nil {
+				fail(fmt.Errorf("failed to summarize chunk %d of %d: %w", i+1, len(chunks), err))
+				return
+			}
+			partials[i] = res.Text
+			usages[i] = res.Usage
+		}()
+	}
+	wg.Wait()
+
+	var usage Usage
+	if firstErr != nil {
+		return nil, usage, firstErr
+	}
+	for _, u := range usages {
+		usage = addUsage(usage, u)
+	}
+
+	return partials, usage, nil
+}
+
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+	}
+}
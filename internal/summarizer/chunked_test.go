@@ -0,0 +1,163 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// paragraph returns a paragraph of n sentences tagged with id.
+func paragraph(id string, n int) string {
+	sentences := make([]string, n)
+	for i := range sentences {
+		sentences[i] = fmt.Sprintf("Sentence %d of section %s.", i+1, id)
+	}
+	return strings.Join(sentences, " ")
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	t.Run("Small content is one chunk", func(t *testing.T) {
+		assert.Equal(t, []string{"# Title\n\nBody text."}, SplitMarkdown("# Title\n\nBody text.", 100, 0))
+	})
+
+	t.Run("Cuts at headings and paragraphs", func(t *testing.T) {
+		content := "# One\n\n" + paragraph("a", 4) + "\n\n" + paragraph("b", 4) + "\n\n# Two\n\n" + paragraph("c", 4)
+
+		chunks := SplitMarkdown(content, 40, 0)
+		require.Len(t, chunks, 3)
+		assert.True(t, strings.HasPrefix(chunks[0], "# One\n\nSentence 1 of section a."))
+		assert.True(t, strings.HasPrefix(chunks[1], "Sentence 1 of section b."))
+		assert.True(t, strings.HasPrefix(chunks[2], "# Two\n\nSentence 1 of section c."))
+		for _, c := range chunks {
+			assert.LessOrEqual(t, EstimateTokens(c), 40)
+		}
+	})
+
+	t.Run("Splits oversized paragraphs by words", func(t *testing.T) {
+		chunks := SplitMarkdown(paragraph("a", 20), 30, 0)
+		assert.Greater(t, len(chunks), 1)
+		for _, c := range chunks {
+			assert.LessOrEqual(t, EstimateTokens(c), 30)
+		}
+		assert.Equal(t, strings.Fields(paragraph("a", 20)), strings.Fields(strings.Join(chunks, " ")))
+	})
+
+	t.Run("Overlap repeats the previous tail", func(t *testing.T) {
+		content := paragraph("a", 4) + "\n\n" + paragraph("b", 4)
+
+		chunks := SplitMarkdown(content, 50, 10)
+		require.Len(t, chunks, 2)
+		assert.True(t, strings.HasPrefix(chunks[1], "4 of section a.\n\nSentence 1 of section b."), chunks[1])
+	})
+}
+
+// recordingSummarizer wraps the offline summarizer and records concurrency.
+type recordingSummarizer struct {
+	*Offline
+	mu       sync.Mutex
+	requests []Request
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	fail     string
+}
+
+func (r *recordingSummarizer) Summarize(ctx context.Context, req Request) (*Response, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		p := r.peak.Load()
+		if n <= p || r.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.mu.Unlock()
+
+	if r.fail != "" && strings.Contains(req.Content, r.fail) {
+		return nil, errors.New("backend failure")
+	}
+	return r.Offline.Summarize(ctx, req)
+}
+
+func TestChunked_Summarize(t *testing.T) {
+	var sections []string
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		sections = append(sections, "## "+id+"\n\n"+paragraph(id, 6))
+	}
+	content := strings.Join(sections, "\n\n")
+
+	t.Run("Small content goes straight to the backend", func(t *testing.T) {
+		inner := &recordingSummarizer{Offline: NewOffline(1)}
+		res, err := NewChunked(inner, ChunkOptions{ChunkTokens: 1000}).Summarize(context.Background(), Request{Content: paragraph("a", 3)})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Sentence 1 of section a.", res.Text)
+		assert.Len(t, inner.requests, 1)
+	})
+
+	t.Run("Map-reduce is deterministic and order preserving", func(t *testing.T) {
+		run := func() (*Response, *recordingSummarizer) {
+			inner := &recordingSummarizer{Offline: NewOffline(1)}
+			res, err := NewChunked(inner, ChunkOptions{ChunkTokens: 60, Workers: 3}).Summarize(context.Background(), Request{
+				Instructions: "Summarize.",
+				Content:      content,
+			})
+			require.NoError(t, err)
+			return res, inner
+		}
+
+		res, inner := run()
+		again, _ := run()
+
+		assert.Equal(t, res.Text, again.Text)
+		assert.LessOrEqual(t, int(inner.peak.Load()), 3)
+		assert.Greater(t, len(inner.requests), 8, "every chunk plus reduce passes")
+
+		final := inner.requests[len(inner.requests)-1]
+		assert.Contains(t, final.Instructions, finalInstructions)
+		assert.LessOrEqual(t, EstimateTokens(final.Content), 60)
+		assert.Equal(t, "a", strings.Fields(res.Text)[0], "first section stays first")
+		assert.Positive(t, res.Usage.PromptTokens)
+	})
+
+	t.Run("Hierarchical reduce when partials do not fit", func(t *testing.T) {
+		inner := &recordingSummarizer{Offline: NewOffline(2)}
+		_, err := NewChunked(inner, ChunkOptions{ChunkTokens: 40, Workers: 2}).Summarize(context.Background(), Request{Content: content})
+		require.NoError(t, err)
+
+		var reduces int
+		for _, r := range inner.requests {
+			if strings.Contains(r.Instructions, "partial summaries of a longer email. Merge") {
+				reduces++
+			}
+		}
+		assert.Positive(t, reduces)
+	})
+
+	t.Run("Chunk failure fails the summary", func(t *testing.T) {
+		inner := &recordingSummarizer{Offline: NewOffline(1), fail: "section c"}
+		_, err := NewChunked(inner, ChunkOptions{ChunkTokens: 60}).Summarize(context.Background(), Request{Content: content})
+		assert.ErrorContains(t, err, "backend failure")
+	})
+
+	t.Run("Streams only the final pass", func(t *testing.T) {
+		var tokens []string
+		res, err := NewChunked(NewOffline(1), ChunkOptions{ChunkTokens: 60}).SummarizeStream(context.Background(), Request{Content: content}, func(token string) error {
+			tokens = append(tokens, token)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, res.Text, strings.Join(tokens, ""))
+	})
+}
@@ -0,0 +1,78 @@
+package summarizer
+
+import (
+	"context"
+	"main/internal/metrics"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "main/internal/summarizer"
+
+// Instrumented records a span, the latency and the token usage of every
+// call to the wrapped backend.
+type Instrumented struct {
+	inner Summarizer
+}
+
+// NewInstrumented wraps inner with metrics and tracing.
+func NewInstrumented(inner Summarizer) *Instrumented {
+	return &Instrumented{inner}
+}
+
+func (i *Instrumented) Model() string {
+	return i.inner.Model()
+}
+
+func (i *Instrumented) Summarize(ctx context.Context, req Request) (*Response, error) {
+	ctx, span := i.start(ctx, "summarize", req)
+	start := time.Now()
+	res, err := i.inner.Summarize(ctx, req)
+	i.observe(span, start, res, err)
+	return res, err
+}
+
+func (i *Instrumented) SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error) {
+	ctx, span := i.start(ctx, "summarize stream", req)
+	start := time.Now()
+	res, err := Stream(ctx, i.inner, req, onToken)
+	i.observe(span, start, res, err)
+	return res, err
+}
+
+func (i *Instrumented) start(ctx context.Context, name string, req Request) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("summarizer.model", i.Model()),
+			attribute.Int("summarizer.input_tokens_estimate", EstimateTokens(req.Content)),
+			attribute.Int("summarizer.max_tokens", req.MaxTokens),
+		),
+	)
+}
+
+func (i *Instrumented) observe(span trace.Span, start time.Time, res *Response, err error) {
+	defer span.End()
+
+	model := i.Model()
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "summarizer failed")
+	}
+	metrics.SummarizerDuration.WithLabelValues(model, outcome).Observe(time.Since(start).Seconds())
+
+	if res != nil {
+		metrics.SummarizerTokens.WithLabelValues(model, "prompt").Add(float64(res.Usage.PromptTokens))
+		metrics.SummarizerTokens.WithLabelValues(model, "completion").Add(float64(res.Usage.CompletionTokens))
+		span.SetAttributes(
+			attribute.Int("summarizer.prompt_tokens", res.Usage.PromptTokens),
+			attribute.Int("summarizer.completion_tokens", res.Usage.CompletionTokens),
+		)
+	}
+}
@@ -0,0 +1,99 @@
+package summarizer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+const defaultOfflineSentences = 3
+
+var (
+	markdownLink   = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	markdownSyntax = regexp.MustCompile("[*_`#>]+")
+	sentenceEnd    = regexp.MustCompile(`([.!?])\s+`)
+)
+
+// Offline is a deterministic extractive summarizer that needs no network
+// access. It keeps the leading sentences of the content and is used for
+// local development and tests.
+type Offline struct {
+	sentences int
+}
+
+// NewOffline creates a new Offline summarizer keeping the given number of
+// sentences, or a small default when n is not positive.
+func NewOffline(n int) *Offline {
+	if n <= 0 {
+		n = defaultOfflineSentences
+	}
+	return &Offline{n}
+}
+
+func (o *Offline) Model() string {
+	return "offline"
+}
+
+func (o *Offline) Summarize(ctx context.Context, req Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sentences := Sentences(req.Content)
+	if len(sentences) == 0 {
+		return nil, ErrEmptyContent
+	}
+	if len(sentences) > o.sentences {
+		sentences = sentences[:o.sentences]
+	}
+
+	text := strings.Join(sentences, " ")
+	if req.MaxTokens > 0 {
+		text = truncateTokens(text, req.MaxTokens)
+	}
+
+	return &Response{
+		Text: text,
+		Usage: Usage{
+			PromptTokens:     EstimateTokens(req.Instructions) + EstimateTokens(req.Content),
+			CompletionTokens: EstimateTokens(text),
+		},
+	}, nil
+}
+
+// Sentences strips markdown syntax and splits the content into sentences.
+func Sentences(content string) []string {
+	text := markdownLink.ReplaceAllString(content, "$1")
+	text = markdownSyntax.ReplaceAllString(text, "")
+	text = strings.Join(strings.Fields(text), " ")
+	text = sentenceEnd.ReplaceAllString(text, "$1\n")
+
+	var out []string
+	for _, s := range strings.Split(text, "\n") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// EstimateTokens approximates the token count of text at roughly four
+// characters per token, which is close enough for budgeting.
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
+
+func truncateTokens(text string, tokens int) string {
+	limit := tokens * 4
+	if len(text) <= limit {
+		return text
+	}
+	cut := strings.LastIndex(text[:limit], " ")
+	if cut <= 0 {
+		cut = limit
+	}
+	return text[:cut]
+}
@@ -0,0 +1,230 @@
+package summarizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultOpenAIURL   = "https://api.openai.com/v1"
+	DefaultOpenAIModel = "gpt-4o-mini"
+)
+
+// OpenAI summarizes through any OpenAI compatible chat completions endpoint.
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAI creates a new OpenAI summarizer. Empty baseURL and model fall
+// back to the OpenAI defaults and a nil client to http.DefaultClient.
+func NewOpenAI(baseURL, apiKey, model string, client *http.Client) *OpenAI {
+	if baseURL == "" {
+		baseURL = DefaultOpenAIURL
+	}
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenAI{strings.TrimSuffix(baseURL, "/"), apiKey, model, client}
+}
+
+func (o *OpenAI) Model() string {
+	return "openai:" + o.model
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage chatUsage `json:"usage"`
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta chatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *chatUsage `json:"usage"`
+}
+
+func (o *OpenAI) Summarize(ctx context.Context, req Request) (*Response, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, ErrEmptyContent
+	}
+
+	res, err := o.post(ctx, o.chatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var out chatResponse
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode summarizer response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("summarizer returned no choices")
+	}
+
+	return &Response{
+		Text: strings.TrimSpace(out.Choices[0].Message.Content),
+		Usage: Usage{
+			PromptTokens:     out.Usage.PromptTokens,
+			CompletionTokens: out.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// SummarizeStream requests a streamed completion and forwards each content
+// delta to onToken.
+func (o *OpenAI) SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error) {
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, ErrEmptyContent
+	}
+
+	chat := o.chatRequest(req)
+	chat.Stream = true
+	chat.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	res, err := o.post(ctx, chat)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var text strings.Builder
+	out := &Response{}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode summarizer stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			out.Usage = Usage{PromptTokens: chunk.Usage.PromptTokens, CompletionTokens: chunk.Usage.CompletionTokens}
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if err := onToken(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("summarizer stream failed: %w", err)
+	}
+
+	out.Text = strings.TrimSpace(text.String())
+	return out, nil
+}
+
+func (o *OpenAI) post(ctx context.Context, chat chatRequest) (*http.Response, error) {
+	body, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	res, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("summarizer request failed: %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("summarizer returned status %d: %s", res.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	return res, nil
+}
+
+func (o *OpenAI) chatRequest(req Request) chatRequest {
+	instructions := req.Instructions
+	if instructions == "" {
+		instructions = DefaultInstructions
+	}
+
+	return chatRequest{
+		Model: o.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: instructions},
+			{Role: "user", Content: req.Content},
+		},
+		MaxTokens: req.MaxTokens,
+	}
+}
+
+// Ping checks that the endpoint is reachable and accepts the API key by
+// listing the models, which costs no tokens.
+func (o *OpenAI) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("summarizer request failed: %w", err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("summarizer returned status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package summarizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"main/internal/model"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// DefaultProfile is used when neither the request nor the user picks one.
+const DefaultProfile = "default"
+
+const (
+	maxProfiles        = 20
+	maxTemplateLength  = 4000
+	maxLanguageLength  = 35
+	defaultLanguage    = "English"
+	defaultFormat      = "bullets"
+	defaultLengthValue = "paragraph"
+)
+
+var (
+	Formats = []string{"bullets", "prose"}
+	Lengths = []string{"line", "paragraph", "detailed"}
+
+	profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+	ErrUnknownProfile = errors.New("unknown prompt profile")
+)
+
+// BuiltinProfiles are available to every user and cannot be overridden.
+var BuiltinProfiles = []model.PromptProfile{
+	{
+		Name: DefaultProfile,
+		Template: `You summarize emails.
+{{if eq .Format "bullets"}}Write the summary as markdown bullet points.{{else}}Write the summary as prose.{{end}}
+{{if eq .Length "line"}}Keep it to a single line.{{else if eq .Length "paragraph"}}Keep it to one short paragraph.{{else}}Be thorough but concise.{{end}}
+Keep names, dates, amounts and links that matter.
+{{- if .FocusActionItems}} Start with the action items the reader must take, with their deadlines.{{end}}
+Write in {{.Language}}.`,
+	},
+	{
+		Name: "action-items",
+		Template: `You extract action items from emails.
+List every task the reader must do as a markdown checklist, each with its deadline and who asked for it.
+If there is nothing to do, say so in one line.
+Write in {{.Language}}.`,
+	},
+	{
+		Name: "tldr",
+		Template: `You summarize emails in a single sentence of at most 25 words that tells the reader whether they need to act.
+Write in {{.Language}}.`,
+	},
+}
+
+// PromptData is available to profile templates.
+type PromptData struct {
+	Format           string
+	Length           string
+	FocusActionItems bool
+	Language         string
+	Subject          string
+	Sender           string
+}
+
+// DefaultPreferences are used for users that never saved their own.
+func DefaultPreferences(userID string) *model.Preferences {
+	return &model.Preferences{
+		UserID:         userID,
+		Format:         defaultFormat,
+		Length:         defaultLengthValue,
+		Language:       defaultLanguage,
+		DefaultProfile: DefaultProfile,
+		Profiles:       []model.PromptProfile{},
+	}
+}
+
+// ValidatePreferences fills in defaults and checks every field, including
+// that each custom profile template parses and renders.
+func ValidatePreferences(p *model.Preferences) error {
+	var errs []error
+
+	if p.Format == "" {
+		p.Format = defaultFormat
+	}
+	if p.Length == "" {
+		p.Length = defaultLengthValue
+	}
+	if p.Language = strings.TrimSpace(p.Language); p.Language == "" {
+		p.Language = defaultLanguage
+	}
+	if p.DefaultProfile == "" {
+		p.DefaultProfile = DefaultProfile
+	}
+	if p.Profiles == nil {
+		p.Profiles = []model.PromptProfile{}
+	}
+
+	if !slices.Contains(Formats, p.Format) {
+		errs = append(errs, fmt.Errorf("format must be one of %s", strings.Join(Formats, ", ")))
+	}
+	if !slices.Contains(Lengths, p.Length) {
+		errs = append(errs, fmt.Errorf("length must be one of %s", strings.Join(Lengths, ", ")))
+	}
+	if len(p.Language) > maxLanguageLength {
+		errs = append(errs, fmt.Errorf("language must be at most %d characters", maxLanguageLength))
+	}
+	if len(p.Profiles) > maxProfiles {
+		errs = append(errs, fmt.Errorf("at most %d profiles are allowed", maxProfiles))
+	}
+
+	seen := map[string]bool{}
+	for _, b := range BuiltinProfiles {
+		seen[b.Name] = true
+	}
+	for _, profile := range p.Profiles {
+		if err := validateProfile(profile); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if seen[profile.Name] {
+			errs = append(errs, fmt.Errorf("profile %q already exists", profile.Name))
+			continue
+		}
+		seen[profile.Name] = true
+	}
+
+	if !seen[p.DefaultProfile] {
+		errs = append(errs, fmt.Errorf("default profile %q does not exist", p.DefaultProfile))
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateProfile(p model.PromptProfile) error {
+	if !profileName.MatchString(p.Name) {
+		return fmt.Errorf("profile name %q must be 1-32 lowercase letters, digits or dashes", p.Name)
+	}
+	if strings.TrimSpace(p.Template) == "" {
+		return fmt.Errorf("profile %q has an empty template", p.Name)
+	}
+	if len(p.Template) > maxTemplateLength {
+		return fmt.Errorf("profile %q template must be at most %d characters", p.Name, maxTemplateLength)
+	}
+
+	tmpl, err := parse(p)
+	if err != nil {
+		return fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	// Rendering against sample data catches unknown fields.
+	if err := tmpl.Execute(io.Discard, PromptData{Format: defaultFormat, Length: defaultLengthValue, Language: defaultLanguage}); err != nil {
+		return fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	return nil
+}
+
+// FindProfile looks up a profile by name among the builtin and the user's
+// profiles. An empty name selects the user's default profile.
+func FindProfile(prefs *model.Preferences, name string) (model.PromptProfile, error) {
+	if name == "" {
+		name = prefs.DefaultProfile
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	for _, p := range BuiltinProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	for _, p := range prefs.Profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return model.PromptProfile{}, fmt.Errorf("%w: %q", ErrUnknownProfile, name)
+}
+
+// Render executes the profile template with the user's preferences.
+func Render(profile model.PromptProfile, prefs *model.Preferences, subject, sender string) (string, error) {
+	tmpl, err := parse(profile)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, PromptData{
+		Format:           prefs.Format,
+		Length:           prefs.Length,
+		FocusActionItems: prefs.FocusActionItems,
+		Language:         prefs.Language,
+		Subject:          subject,
+		Sender:           sender,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func parse(p model.PromptProfile) (*template.Template, error) {
+	return template.New(p.Name).Option("missingkey=error").Parse(p.Template)
+}
@@ -0,0 +1,80 @@
+package summarizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"main/internal/model"
+)
+
+func TestValidatePreferences(t *testing.T) {
+	t.Run("Fills in defaults", func(t *testing.T) {
+		prefs := &model.Preferences{}
+		require.NoError(t, ValidatePreferences(prefs))
+
+		assert.Equal(t, DefaultPreferences(""), prefs)
+	})
+
+	t.Run("Accepts custom profiles", func(t *testing.T) {
+		prefs := &model.Preferences{
+			DefaultProfile: "boss",
+			Profiles: []model.PromptProfile{
+				{Name: "boss", Template: "Summarize {{.Subject}} for my manager in {{.Language}}."},
+			},
+		}
+		assert.NoError(t, ValidatePreferences(prefs))
+	})
+
+	t.Run("Reports every problem", func(t *testing.T) {
+		prefs := &model.Preferences{
+			Format:         "haiku",
+			Length:         "epic",
+			DefaultProfile: "missing",
+			Profiles: []model.PromptProfile{
+				{Name: "Bad Name", Template: "x"},
+				{Name: "broken", Template: "{{.Subject"},
+				{Name: "unknown-field", Template: "{{.Recipient}}"},
+				{Name: "tldr", Template: "override"},
+			},
+		}
+
+		err := ValidatePreferences(prefs)
+		require.Error(t, err)
+
+		for _, msg := range []string{"format must be", "length must be", `"Bad Name"`, `profile "broken"`, `profile "unknown-field"`, `profile "tldr" already exists`, `default profile "missing"`} {
+			assert.ErrorContains(t, err, msg)
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	prefs := DefaultPreferences("user-123")
+	prefs.Format = "prose"
+	prefs.Length = "line"
+	prefs.FocusActionItems = true
+	prefs.Language = "German"
+
+	profile, err := FindProfile(prefs, "")
+	require.NoError(t, err)
+
+	text, err := Render(profile, prefs, "Invoice", "bob@example.com")
+	require.NoError(t, err)
+
+	assert.Contains(t, text, "Write the summary as prose.")
+	assert.Contains(t, text, "Keep it to a single line.")
+	assert.Contains(t, text, "Start with the action items")
+	assert.Contains(t, text, "Write in German.")
+
+	prefs.Profiles = []model.PromptProfile{{Name: "custom", Template: "About {{.Subject}} from {{.Sender}}"}}
+	profile, err = FindProfile(prefs, "custom")
+	require.NoError(t, err)
+
+	text, err = Render(profile, prefs, "Invoice", "bob@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "About Invoice from bob@example.com", text)
+
+	_, err = FindProfile(prefs, "nope")
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+}
@@ -0,0 +1,58 @@
+package summarizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reply tones and lengths accepted by ReplyInstructions.
+var (
+	ReplyTones   = []string{"neutral", "friendly", "formal", "concise"}
+	ReplyLengths = map[string]int{"short": 120, "medium": 250, "long": 500}
+)
+
+// ReplyOptions shape a proposed reply.
+type ReplyOptions struct {
+	Tone   string `json:"tone"`
+	Length string `json:"length"`
+	// Notes are extra points the user wants the reply to make.
+	Notes string `json:"notes"`
+}
+
+// Validate fills in defaults and rejects unknown tones or lengths.
+func (o *ReplyOptions) Validate() error {
+	if o.Tone == "" {
+		o.Tone = "neutral"
+	}
+	if o.Length == "" {
+		o.Length = "short"
+	}
+
+	known := false
+	for _, t := range ReplyTones {
+		known = known || t == o.Tone
+	}
+	if !known {
+		return fmt.Errorf("unknown tone %q", o.Tone)
+	}
+	if _, ok := ReplyLengths[o.Length]; !ok {
+		return fmt.Errorf("unknown length %q", o.Length)
+	}
+	return nil
+}
+
+// ReplyRequest builds the summarizer request proposing a reply to email.
+func ReplyRequest(opts ReplyOptions, sender, email string) Request {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You draft email replies on behalf of the user. Write a %s, %s reply to the email from %s. ", opts.Tone, opts.Length, sender)
+	b.WriteString("Reply with the body only, in plain text, without a subject line or placeholders.")
+	if opts.Notes != "" {
+		fmt.Fprintf(&b, " Make sure the reply covers: %s", opts.Notes)
+	}
+
+	return Request{
+		Instructions: b.String(),
+		Content:      email,
+		MaxTokens:    ReplyLengths[opts.Length],
+	}
+}
@@ -0,0 +1,138 @@
+package summarizer
+
+import (
+	"strings"
+)
+
+// SplitMarkdown splits markdown into chunks of roughly maxTokens. It cuts
+// at headings first, then at paragraph boundaries, and only splits inside
+// a paragraph when a single paragraph is too large. Each chunk after the
+// first starts with the trailing overlapTokens of the previous chunk.
+func SplitMarkdown(content string, maxTokens, overlapTokens int) []string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	if overlapTokens < 0 || overlapTokens >= maxTokens {
+		overlapTokens = 0
+	}
+	budget := maxTokens - overlapTokens
+
+	var chunks []string
+	var current []string
+	size := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+			current = nil
+			size = 0
+		}
+	}
+
+	for _, block := range blocks(content) {
+		tokens := EstimateTokens(block)
+
+		// A heading starts a new chunk unless the current one is still small.
+		if isHeading(block) && size > budget/2 {
+			flush()
+		}
+
+		if tokens > budget {
+			flush()
+			chunks = append(chunks, splitWords(block, budget)...)
+			continue
+		}
+
+		if size+tokens > budget {
+			flush()
+		}
+		current = append(current, block)
+		size += tokens + 1
+	}
+	flush()
+
+	if overlapTokens == 0 {
+		return chunks
+	}
+
+	out := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		if i == 0 {
+			out[i] = chunk
+			continue
+		}
+		out[i] = tail(chunks[i-1], overlapTokens) + "\n\n" + chunk
+	}
+	return out
+}
+
+// blocks splits content into paragraphs, keeping each heading as its own
+// block.
+func blocks(content string) []string {
+	var out []string
+	var para []string
+
+	flush := func() {
+		if len(para) > 0 {
+			out = append(out, strings.Join(para, "\n"))
+			para = nil
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case isHeading(trimmed):
+			flush()
+			out = append(out, trimmed)
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+
+	return out
+}
+
+func isHeading(block string) bool {
+	return strings.HasPrefix(block, "#")
+}
+
+// splitWords cuts an oversized block into pieces of at most maxTokens.
+func splitWords(block string, maxTokens int) []string {
+	var out []string
+	var b strings.Builder
+
+	for _, word := range strings.Fields(block) {
+		if b.Len() > 0 && EstimateTokens(b.String()+" "+word) > maxTokens {
+			out = append(out, b.String())
+			b.Reset()
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+	}
+	if b.Len() > 0 {
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// tail returns the trailing words of text worth about tokens.
+func tail(text string, tokens int) string {
+	words := strings.Fields(text)
+	size := 0
+	i := len(words)
+	for i > 0 {
+		next := size + EstimateTokens(words[i-1]) + 1
+		if next > tokens {
+			break
+		}
+		size = next
+		i--
+	}
+	return strings.Join(words[i:], " ")
+}
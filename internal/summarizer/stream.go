@@ -0,0 +1,54 @@
+package summarizer
+
+import (
+	"context"
+	"strings"
+)
+
+// TokenFunc receives output as it is produced. Returning an error stops
+// the stream.
+type TokenFunc func(token string) error
+
+// Streamer is implemented by summarizers that can emit their output
+// incrementally.
+type Streamer interface {
+	SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error)
+}
+
+// Stream summarizes req, calling onToken as output arrives. Summarizers that
+// do not implement Streamer emit their whole output as a single token.
+func Stream(ctx context.Context, s Summarizer, req Request, onToken TokenFunc) (*Response, error) {
+	if st, ok := s.(Streamer); ok {
+		return st.SummarizeStream(ctx, req, onToken)
+	}
+
+	res, err := s.Summarize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onToken(res.Text); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SummarizeStream emits the offline summary word by word.
+func (o *Offline) SummarizeStream(ctx context.Context, req Request, onToken TokenFunc) (*Response, error) {
+	res, err := o.Summarize(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, word := range strings.Fields(res.Text) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			word = " " + word
+		}
+		if err := onToken(word); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrEmptyContent = errors.New("nothing to summarize")
+)
+
+// Summarizer turns content into shorter text following the given instructions.
+type Summarizer interface {
+	Summarize(ctx context.Context, req Request) (*Response, error)
+	// Model identifies the backend and model producing the output.
+	Model() string
+}
+
+// Request is a single summarization call.
+type Request struct {
+	// Instructions is the system prompt describing the expected output.
+	Instructions string
+	// Content is the markdown to work on.
+	Content string
+	// MaxTokens caps the length of the output. Zero means backend default.
+	MaxTokens int
+}
+
+// Response is the output of a summarization call.
+type Response struct {
+	Text  string `json:"text"`
+	Usage Usage  `json:"usage"`
+}
+
+// Usage reports the tokens consumed by a call.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// DefaultInstructions is the prompt used for plain email summaries.
+const DefaultInstructions = "You summarize emails. Reply with a concise summary of the email in markdown, keeping names, dates, amounts and action items."
@@ -0,0 +1,123 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffline_Summarize(t *testing.T) {
+	content := "# Weekly update\n\nThe **launch** moved to [Friday](https://example.com). Budget is approved! Any questions? More text here."
+
+	res, err := NewOffline(2).Summarize(context.Background(), Request{Content: content})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Weekly update The launch moved to Friday. Budget is approved!", res.Text)
+	assert.Positive(t, res.Usage.PromptTokens)
+
+	_, err = NewOffline(2).Summarize(context.Background(), Request{Content: "  "})
+	assert.ErrorIs(t, err, ErrEmptyContent)
+}
+
+func TestOpenAI_Summarize(t *testing.T) {
+	var got chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" A summary. "}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer srv.Close()
+
+	s := NewOpenAI(srv.URL+"/v1/", "secret", "test-model", srv.Client())
+	res, err := s.Summarize(context.Background(), Request{Content: "Long email", MaxTokens: 50})
+	require.NoError(t, err)
+
+	assert.Equal(t, "A summary.", res.Text)
+	assert.Equal(t, Usage{PromptTokens: 12, CompletionTokens: 3}, res.Usage)
+	assert.Equal(t, "openai:test-model", s.Model())
+	assert.Equal(t, "test-model", got.Model)
+	assert.Equal(t, 50, got.MaxTokens)
+	assert.Equal(t, DefaultInstructions, got.Messages[0].Content)
+}
+
+func TestOpenAI_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := NewOpenAI(srv.URL, "secret", "", srv.Client()).Summarize(context.Background(), Request{Content: "x"})
+	assert.ErrorContains(t, err, "429")
+}
+
+func TestOpenAI_Ping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "invalid key", http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, NewOpenAI(srv.URL+"/v1", "secret", "", srv.Client()).Ping(context.Background()))
+	assert.ErrorContains(t, NewOpenAI(srv.URL+"/v1", "wrong", "", srv.Client()).Ping(context.Background()), "401")
+}
+
+func TestOpenAI_SummarizeStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Pay \"}}]}\n\n" +
+			": keep-alive\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"invoice.\"}}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	var tokens []string
+	res, err := NewOpenAI(srv.URL, "secret", "", srv.Client()).SummarizeStream(context.Background(), Request{Content: "x"}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Pay ", "invoice."}, tokens)
+	assert.Equal(t, "Pay invoice.", res.Text)
+	assert.Equal(t, Usage{PromptTokens: 9, CompletionTokens: 2}, res.Usage)
+}
+
+func TestStream_NonStreamingFallback(t *testing.T) {
+	var tokens []string
+	res, err := Stream(context.Background(), summarizerFunc(func(ctx context.Context, req Request) (*Response, error) {
+		return &Response{Text: "whole"}, nil
+	}), Request{Content: "x"}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"whole"}, tokens)
+	assert.Equal(t, "whole", res.Text)
+}
+
+type summarizerFunc func(ctx context.Context, req Request) (*Response, error)
+
+func (f summarizerFunc) Summarize(ctx context.Context, req Request) (*Response, error) {
+	return f(ctx, req)
+}
+
+func (f summarizerFunc) Model() string { return "func" }
@@ -0,0 +1,30 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	var out bytes.Buffer
+	flush, err := Setup(context.Background(), ExporterStdout, &out)
+	require.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "summarize")
+	span.End()
+	require.NoError(t, flush(context.Background()))
+
+	assert.Contains(t, out.String(), `"Name":"summarize"`)
+	assert.Contains(t, out.String(), `"Value":"sumnotes"`)
+
+	_, err = Setup(context.Background(), "jaeger", &out)
+	assert.ErrorContains(t, err, `unknown exporter "jaeger"`)
+}
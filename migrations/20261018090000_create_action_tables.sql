@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS action_settings (
+    user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    add_summarized_label BOOLEAN NOT NULL DEFAULT TRUE,
+    apply_category_labels BOOLEAN NOT NULL DEFAULT FALSE,
+    mark_read BOOLEAN NOT NULL DEFAULT FALSE,
+    archive BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS action_log (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    operations JSONB NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        undone_at TIMESTAMP
+    WITH
+        TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS action_log_user_created_idx ON action_log (user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS action_log;
+DROP TABLE IF EXISTS action_settings;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    sender TEXT NOT NULL,
+    sender_name TEXT,
+    unsubscribe_url TEXT,
+    unsubscribe_mailto TEXT,
+    one_click BOOLEAN NOT NULL DEFAULT FALSE,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    read_count INTEGER NOT NULL DEFAULT 0,
+    last_seen_at TIMESTAMP
+    WITH
+        TIME ZONE,
+        unsubscribed_at TIMESTAMP
+    WITH
+        TIME ZONE,
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        UNIQUE (user_id, sender)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscriptions;
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS messages (
+    id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    thread_id TEXT,
+    subject TEXT NOT NULL DEFAULT '',
+    sender TEXT NOT NULL DEFAULT '',
+    snippet TEXT NOT NULL DEFAULT '',
+    markdown TEXT NOT NULL DEFAULT '',
+    label_ids TEXT[] NOT NULL DEFAULT '{}',
+    received_at TIMESTAMP
+    WITH
+        TIME ZONE,
+        created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        search tsvector GENERATED ALWAYS AS (
+            setweight(to_tsvector('english', coalesce(subject, '')), 'A') ||
+            setweight(to_tsvector('simple', coalesce(sender, '')), 'B') ||
+            setweight(to_tsvector('english', coalesce(markdown, '')), 'C')
+        ) STORED,
+        PRIMARY KEY (user_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS messages_search_idx ON messages USING GIN (search);
+CREATE INDEX IF NOT EXISTS messages_user_received_idx ON messages (user_id, received_at DESC);
+CREATE INDEX IF NOT EXISTS messages_label_ids_idx ON messages USING GIN (label_ids);
+
+CREATE TABLE IF NOT EXISTS summaries (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    text TEXT NOT NULL,
+    model TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        search tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(text, ''))) STORED,
+        FOREIGN KEY (user_id, message_id) REFERENCES messages (user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS summaries_search_idx ON summaries USING GIN (search);
+CREATE INDEX IF NOT EXISTS summaries_message_idx ON summaries (user_id, message_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS summaries;
+DROP TABLE IF EXISTS messages;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- pgvector is optional; similarity search falls back to Go when it is missing.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS vector;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'pgvector is not available, using the in-process fallback';
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS message_chunks (
+    user_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    chunk_index INTEGER NOT NULL,
+    model TEXT NOT NULL,
+    content TEXT NOT NULL,
+    embedding REAL[] NOT NULL,
+    created_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW(),
+        PRIMARY KEY (user_id, message_id, model, chunk_index),
+        FOREIGN KEY (user_id, message_id) REFERENCES messages (user_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS message_chunks_user_model_idx ON message_chunks (user_id, model);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_chunks;
+-- +goose StatementEnd
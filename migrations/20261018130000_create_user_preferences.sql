@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    format TEXT NOT NULL DEFAULT 'bullets',
+    length TEXT NOT NULL DEFAULT 'paragraph',
+    focus_action_items BOOLEAN NOT NULL DEFAULT FALSE,
+    language TEXT NOT NULL DEFAULT 'English',
+    default_profile TEXT NOT NULL DEFAULT 'default',
+    profiles JSONB NOT NULL DEFAULT '[]',
+    updated_at TIMESTAMP
+    WITH
+        TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE summaries ADD COLUMN profile TEXT NOT NULL DEFAULT 'default';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE summaries DROP COLUMN profile;
+DROP TABLE IF EXISTS user_preferences;
+-- +goose StatementEnd
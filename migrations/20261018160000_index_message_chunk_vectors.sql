@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- With pgvector, chunks embedded with text-embedding-3-small are searched
+-- through an HNSW index; other sizes keep casting the REAL[] column.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector') THEN
+        ALTER TABLE message_chunks ADD COLUMN IF NOT EXISTS embedding_vector vector(1536);
+        UPDATE message_chunks SET embedding_vector = embedding::vector WHERE cardinality(embedding) = 1536;
+        CREATE INDEX IF NOT EXISTS message_chunks_embedding_vector_idx ON message_chunks USING hnsw (embedding_vector vector_cosine_ops);
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS message_chunks_embedding_vector_idx;
+ALTER TABLE message_chunks DROP COLUMN IF EXISTS embedding_vector;
+-- +goose StatementEnd